@@ -5,6 +5,10 @@ import (
 	"errors"
 	"io"
 	"reflect"
+	"strconv"
+
+	"github.com/mkrautz/plist/binaryplist"
+	"github.com/mkrautz/plist/internal/plistreflect"
 )
 
 func Unmarshal(buf []byte, v interface{}) error {
@@ -17,7 +21,8 @@ func Unmarshal(buf []byte, v interface{}) error {
 }
 
 type Decoder struct {
-	s *scanner
+	s       *scanner
+	ordered bool
 }
 
 func NewDecoder(r io.Reader) *Decoder {
@@ -26,6 +31,13 @@ func NewDecoder(r io.Reader) *Decoder {
 	return dec
 }
 
+// UseOrderedDicts makes Decode return dicts as OrderedDict values,
+// with their entries in the order of the input, when v points to an
+// empty interface.
+func (d *Decoder) UseOrderedDicts() {
+	d.ordered = true
+}
+
 func (d *Decoder) Decode(v interface{}) error {
 	tok, err := d.s.Token()
 	if err != nil {
@@ -33,13 +45,37 @@ func (d *Decoder) Decode(v interface{}) error {
 	}
 	switch tok.(type) {
 	case tokenParenOpen:
-		return d.readArray(v)
+		err = d.readArray(v)
 	case tokenCurlyOpen:
-		return d.readDict(v)
+		err = d.readDict(v)
+	case tokenString:
+		err = d.readStringRoot(v, string(tok.(tokenString)))
 	default:
 		return errors.New("plist: bad root token found in stream")
 	}
-	return nil
+	if err == io.EOF {
+		return errors.New("plist: unexpected end of input")
+	}
+	return err
+}
+
+// readStringRoot reads a plist that starts with the string str. A lone
+// string is a plist of its own, as CoreFoundation reads it; otherwise
+// str is the first key of a dict without braces, as in strings files.
+func (d *Decoder) readStringRoot(v interface{}, str string) error {
+	next, err := d.s.Token()
+	if err == io.EOF {
+		if reflect.TypeOf(v).Kind() != reflect.Ptr {
+			return errors.New("plist: v must be ptr")
+		}
+		return plistreflect.SetText(reflect.ValueOf(v).Elem(), str, dateFormat)
+	}
+	if err != nil {
+		return err
+	}
+	d.s.Unread(next)
+	d.s.Unread(tokenString(str))
+	return d.readDictBody(v, true)
 }
 
 func (d *Decoder) readArray(v interface{}) error {
@@ -56,6 +92,9 @@ Loop:
 		}
 
 		switch tok.(type) {
+		case tokenParenClose:
+			// An empty array, or a comma after the last element.
+			break Loop
 		case tokenParenOpen:
 			var array []interface{}
 			err = d.readArray(&array)
@@ -64,11 +103,16 @@ Loop:
 			}
 			slice = append(slice, array)
 		case tokenCurlyOpen:
-			var m map[string]interface{}
+			var m interface{}
 			err = d.readDict(&m)
 			if err != nil {
 				return err
 			}
+			if uid, ok := dictUID(m); ok {
+				slice = append(slice, uid)
+			} else {
+				slice = append(slice, m)
+			}
 		case tokenString:
 			slice = append(slice, string(tok.(tokenString)))
 		case tokenData:
@@ -92,21 +136,28 @@ Loop:
 		}
 	}
 
-	rv := reflect.ValueOf(v).Elem()
-	rv.Set(reflect.ValueOf(slice))
-
-	return nil
+	return plistreflect.SetText(reflect.ValueOf(v).Elem(), slice, dateFormat)
 }
 
 func (d *Decoder) readDict(v interface{}) error {
+	return d.readDictBody(v, false)
+}
+
+// readDictBody reads the entries of a dict into v. A dict without
+// braces ends at the end of the stream instead of at a closing brace.
+func (d *Decoder) readDictBody(v interface{}, braceless bool) error {
 	if reflect.TypeOf(v).Kind() != reflect.Ptr {
 		return errors.New("plist: v must be ptr")
 	}
 
 	m := map[string]interface{}{}
+	var keys []string
 Loop:
 	for {
 		tok, err := d.s.Token()
+		if err == io.EOF && braceless {
+			break Loop
+		}
 		if err != nil {
 			return err
 		}
@@ -116,11 +167,18 @@ Loop:
 		case tokenString:
 			keyName = string(tok.(tokenString))
 		case tokenCurlyClose:
+			if braceless {
+				return errors.New("plist: bad dict key")
+			}
 			break Loop
 		default:
 			return errors.New("plist: bad dict key")
 		}
 
+		if _, dup := m[keyName]; !dup {
+			keys = append(keys, keyName)
+		}
+
 		tok, err = d.s.Token()
 		if err != nil {
 			return err
@@ -144,12 +202,16 @@ Loop:
 			}
 			m[keyName] = array
 		case tokenCurlyOpen:
-			var dict map[string]interface{}
+			var dict interface{}
 			err = d.readDict(&dict)
 			if err != nil {
 				return err
 			}
-			m[keyName] = dict
+			if uid, ok := dictUID(dict); ok {
+				m[keyName] = uid
+			} else {
+				m[keyName] = dict
+			}
 		case tokenString:
 			m[keyName] = string(tok.(tokenString))
 		case tokenData:
@@ -168,40 +230,32 @@ Loop:
 		}
 	}
 
+	// UID dicts are left as maps for the caller to convert.
 	rv := reflect.ValueOf(v).Elem()
-	mapToValue(m, rv)
-
-	return nil
+	if _, uid := dictUID(m); d.ordered && rv.Kind() == reflect.Interface && !uid {
+		od := make(binaryplist.OrderedDict, len(keys))
+		for i, k := range keys {
+			od[i] = binaryplist.DictEntry{Key: k, Value: m[k]}
+		}
+		return plistreflect.SetText(rv, od, dateFormat)
+	}
+	return plistreflect.SetText(rv, m, dateFormat)
 }
 
-// mapToStruct converts the map-representation of the dictionary in dict
-// into a struct or a map given as val. Recursive structs and maps are
-// supported.
-func mapToValue(dict map[string]interface{}, val reflect.Value) { 
-	if val.Kind() == reflect.Map {
-		val.Set(reflect.ValueOf(dict))
-	} else if val.Kind() == reflect.Struct {
-		typ := val.Type()
-		nfields := val.NumField()
-		for i := 0; i < nfields; i++ {
-			f := typ.Field(i)
-			name := f.Tag.Get("plist")
-			if name != "" {
-				if dictVal, ok := dict[name]; ok {
-					fieldVal := val.Field(i)
-					if fieldVal.Kind() == reflect.Map || fieldVal.Kind() == reflect.Struct {
-						newDict, ok := dictVal.(map[string]interface{})
-						if !ok {
-							return
-						}
-						mapToValue(newDict, fieldVal)
-					} else {
-						if fieldVal.Type() == reflect.ValueOf(dictVal).Type() {
-							fieldVal.Set(reflect.ValueOf(dictVal))
-						}
-					}
-				}
-			}
-		}
+// dictUID returns the UID represented by v, if it is a
+// dict of the form {CF$UID = n}.
+func dictUID(v interface{}) (binaryplist.UID, bool) {
+	dict, ok := v.(map[string]interface{})
+	if !ok || len(dict) != 1 {
+		return 0, false
 	}
-}
\ No newline at end of file
+	s, ok := dict["CF$UID"].(string)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return binaryplist.UID(n), true
+}
@@ -110,3 +110,21 @@ func TestReadRecursiveDict(t *testing.T) {
 		t.Fatalf("hey != 4")
 	}
 }
+
+func TestReadStringsFormat(t *testing.T) {
+	buf := []byte("/* Title of the window */\n\"title\" = \"Réglages\";\n// Button\nok = \"OK\";\n")
+
+	var m map[string]interface{}
+	err := Unmarshal(buf, &m)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(m) != 2 || m["title"] != "Réglages" || m["ok"] != "OK" {
+		t.Fatalf("unexpected dict: %v", m)
+	}
+
+	err = Unmarshal([]byte("\"a\" = \"b\";\n}"), &m)
+	if err == nil {
+		t.Fatalf("expected error for stray brace")
+	}
+}
@@ -0,0 +1,360 @@
+package asciiplist
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist/binaryplist"
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+var (
+	uidType         = reflect.TypeOf(binaryplist.UID(0))
+	orderedDictType = reflect.TypeOf(binaryplist.OrderedDict(nil))
+)
+
+// The date format used when writing dates. Old-style ASCII plists
+// have no native date type, so dates are written as strings in the
+// same format as NSDate's description.
+const dateFormat = "2006-01-02 15:04:05 -0700"
+
+// Marshal returns the ASCII plist encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// An Encoder encodes Go values into the old-style
+// (OpenStep) ASCII plist format.
+//
+// The ASCII format only knows about strings, data, arrays
+// and dictionaries. Numbers and booleans are written as
+// strings (booleans as YES and NO), and dates are written
+// as strings in NSDate's description format.
+type Encoder struct {
+	w           io.Writer
+	bw          *bytes.Buffer // the document being encoded
+	indentLevel int
+}
+
+// NewEncoder returns a new Encoder capable of encoding ASCII plists.
+func NewEncoder(w io.Writer) *Encoder {
+	enc := new(Encoder)
+	enc.w = w
+	enc.bw = new(bytes.Buffer)
+	return enc
+}
+
+// Returns a string that conforms to the current indent level.
+func (e *Encoder) indent() string {
+	return strings.Repeat("\t", e.indentLevel)
+}
+
+// Encode writes the ASCII plist encoding of v to the encoder's
+// writer. The document is built in memory first, so nothing is
+// written when v cannot be encoded.
+func (e *Encoder) Encode(v interface{}) error {
+	rv, err := plistreflect.Marshal(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.IsValid() && rv.Type() == reflect.TypeOf([]byte(nil)) {
+		return errors.New("plist: bad root element: must be dict or array")
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+	default:
+		return errors.New("plist: bad root element: must be dict or array")
+	}
+
+	e.bw.Reset()
+	e.indentLevel = 0
+	err = e.encodeAny(rv)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.bw.WriteString("\n")
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(e.bw.Bytes())
+	return err
+}
+
+// encodeAny encodes any type into its ASCII plist equivalent.
+func (e *Encoder) encodeAny(rv reflect.Value) error {
+	rv, err := plistreflect.Marshal(rv)
+	if err != nil {
+		return err
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type() == orderedDictType {
+			return e.encodeOrderedDict(rv.Interface().(binaryplist.OrderedDict))
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return e.encodeData(rv)
+		}
+		return e.encodeArray(rv)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.writeString(strconv.FormatInt(rv.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Type() == uidType {
+			return e.encodeUID(rv)
+		}
+		return e.writeString(strconv.FormatUint(rv.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		return e.writeString(strconv.FormatFloat(rv.Float(), 'g', -1, 64))
+	case reflect.Bool:
+		if rv.Bool() {
+			return e.writeString("YES")
+		}
+		return e.writeString("NO")
+	case reflect.String:
+		return e.writeString(rv.String())
+	case reflect.Map:
+		return e.encodeMap(rv)
+	case reflect.Struct:
+		if t, ok := rv.Interface().(time.Time); ok {
+			return e.writeString(t.Format(dateFormat))
+		}
+		return e.encodeStruct(rv)
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			return errors.New("plist: cannot encode nil value")
+		}
+		return e.encodeAny(rv.Elem())
+	}
+	return fmt.Errorf("plist: cannot encode %v", rv.Kind())
+}
+
+// writeString writes str as an ASCII plist string, quoting
+// and escaping it if necessary.
+func (e *Encoder) writeString(str string) error {
+	_, err := e.bw.WriteString(quoteString(str))
+	return err
+}
+
+// quoteString returns str in a form suitable for an ASCII plist.
+// Strings consisting entirely of ASCII alphanumeric characters are
+// returned as-is. Others are quoted.
+func quoteString(str string) string {
+	bare := len(str) > 0
+	for i := 0; i < len(str); i++ {
+		if !isAsciiAlphaNumeric(str[i]) {
+			bare = false
+			break
+		}
+	}
+	if bare {
+		return str
+	}
+
+	buf := []byte{'"'}
+	for _, r := range str {
+		switch r {
+		case '"', '\\':
+			buf = append(buf, '\\', byte(r))
+		case '\n':
+			buf = append(buf, '\\', 'n')
+		case '\t':
+			buf = append(buf, '\\', 't')
+		case '\r':
+			buf = append(buf, '\\', 'r')
+		default:
+			if r < 0x20 {
+				buf = append(buf, fmt.Sprintf("\\U%04x", r)...)
+			} else {
+				buf = append(buf, string(r)...)
+			}
+		}
+	}
+	buf = append(buf, '"')
+	return string(buf)
+}
+
+// encodeData encodes a byte slice as hex digits between angle brackets,
+// with a space separating each group of four bytes.
+func (e *Encoder) encodeData(rv reflect.Value) error {
+	buf := make([]byte, rv.Len())
+	reflect.Copy(reflect.ValueOf(buf), rv)
+
+	str := hex.EncodeToString(buf)
+	var groups []string
+	for len(str) > 8 {
+		groups = append(groups, str[:8])
+		str = str[8:]
+	}
+	groups = append(groups, str)
+
+	_, err := e.bw.WriteString("<" + strings.Join(groups, " ") + ">")
+	return err
+}
+
+// encodeUID encodes a UID to the ASCII plist format, as a dict
+// with the single key CF$UID.
+func (e *Encoder) encodeUID(rv reflect.Value) error {
+	_, err := e.bw.WriteString("{\n")
+	if err != nil {
+		return err
+	}
+	e.indentLevel++
+	err = e.encodeEntry("CF$UID", reflect.ValueOf(rv.Uint()))
+	if err != nil {
+		return err
+	}
+	e.indentLevel--
+	_, err = e.bw.WriteString(e.indent() + "}")
+	return err
+}
+
+// encodeArray encodes an array type to the ASCII plist format.
+func (e *Encoder) encodeArray(rv reflect.Value) error {
+	if rv.Len() == 0 {
+		_, err := e.bw.WriteString("()")
+		return err
+	}
+
+	_, err := e.bw.WriteString("(\n")
+	if err != nil {
+		return err
+	}
+
+	e.indentLevel++
+	for i := 0; i < rv.Len(); i++ {
+		_, err = e.bw.WriteString(e.indent())
+		if err != nil {
+			return err
+		}
+		err = e.encodeAny(rv.Index(i))
+		if err != nil {
+			return err
+		}
+		sep := ",\n"
+		if i == rv.Len()-1 {
+			sep = "\n"
+		}
+		_, err = e.bw.WriteString(sep)
+		if err != nil {
+			return err
+		}
+	}
+	e.indentLevel--
+
+	_, err = e.bw.WriteString(e.indent() + ")")
+	return err
+}
+
+// encodeEntry writes a single dict entry (key = value;).
+func (e *Encoder) encodeEntry(key string, rv reflect.Value) error {
+	_, err := e.bw.WriteString(e.indent() + quoteString(key) + " = ")
+	if err != nil {
+		return err
+	}
+	err = e.encodeAny(rv)
+	if err != nil {
+		return err
+	}
+	_, err = e.bw.WriteString(";\n")
+	return err
+}
+
+// encodeMap encodes a map to an ASCII plist dict. The keys
+// of the dict are written in sorted order.
+func (e *Encoder) encodeMap(rv reflect.Value) error {
+	if rv.Type().Key().Kind() != reflect.String {
+		return errors.New("plist: bad map kind (must have string keys)")
+	}
+	if rv.Len() == 0 {
+		_, err := e.bw.WriteString("{}")
+		return err
+	}
+
+	keys := make([]string, 0, rv.Len())
+	for _, kv := range rv.MapKeys() {
+		keys = append(keys, kv.String())
+	}
+	sort.Strings(keys)
+
+	_, err := e.bw.WriteString("{\n")
+	if err != nil {
+		return err
+	}
+
+	e.indentLevel++
+	for _, k := range keys {
+		err = e.encodeEntry(k, rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())))
+		if err != nil {
+			return err
+		}
+	}
+	e.indentLevel--
+
+	_, err = e.bw.WriteString(e.indent() + "}")
+	return err
+}
+
+// encodeOrderedDict encodes an ordered dict to an ASCII plist dict,
+// keeping the order of its entries.
+func (e *Encoder) encodeOrderedDict(d binaryplist.OrderedDict) error {
+	if len(d) == 0 {
+		_, err := e.bw.WriteString("{}")
+		return err
+	}
+
+	_, err := e.bw.WriteString("{\n")
+	if err != nil {
+		return err
+	}
+
+	e.indentLevel++
+	for i := range d {
+		err = e.encodeEntry(d[i].Key, reflect.ValueOf(&d[i].Value).Elem())
+		if err != nil {
+			return err
+		}
+	}
+	e.indentLevel--
+
+	_, err = e.bw.WriteString(e.indent() + "}")
+	return err
+}
+
+// encodeStruct encodes a struct to an ASCII plist dict.
+func (e *Encoder) encodeStruct(rv reflect.Value) error {
+	_, err := e.bw.WriteString("{\n")
+	if err != nil {
+		return err
+	}
+
+	e.indentLevel++
+	keys, vals := plistreflect.StructEntries(rv)
+	for i, name := range keys {
+		err = e.encodeEntry(name, vals[i])
+		if err != nil {
+			return err
+		}
+	}
+	e.indentLevel--
+
+	_, err = e.bw.WriteString(e.indent() + "}")
+	return err
+}
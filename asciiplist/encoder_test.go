@@ -0,0 +1,21 @@
+package asciiplist
+
+import (
+	"testing"
+)
+
+func TestEncodeSkipsUnexportedFields(t *testing.T) {
+	v := struct {
+		Name    string
+		private string
+	}{"hello", "secret"}
+
+	buf, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := "{\n\tName = hello;\n}\n"
+	if string(buf) != expected {
+		t.Fatalf("got %q, expected %q", buf, expected)
+	}
+}
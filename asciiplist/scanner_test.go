@@ -75,6 +75,21 @@ var scannerTests []scannerTest = []scannerTest{
 			tokenCurlyClose("}"),
 		},
 	},
+	{
+		"{ _key = /usr/bin/env; /* c */ $x.y-z:1 = a//b; }",
+		[]token{
+			tokenCurlyOpen("{"),
+			tokenString("_key"),
+			tokenEqual("="),
+			tokenString("/usr/bin/env"),
+			tokenSemi(";"),
+			tokenString("$x.y-z:1"),
+			tokenEqual("="),
+			tokenString("a//b"),
+			tokenSemi(";"),
+			tokenCurlyClose("}"),
+		},
+	},
 }
 
 func TestScanner(t *testing.T) {
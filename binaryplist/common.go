@@ -0,0 +1,51 @@
+package binaryplist
+
+import (
+	"reflect"
+)
+
+// A UID is a reference to another object in the same plist, as used
+// by NSKeyedArchiver archives. UIDs only exist in binary plists.
+type UID uint64
+
+var uidType = reflect.TypeOf(UID(0))
+
+// An OrderedDict is a dict whose entries are encoded in the order
+// given, rather than in the sorted key order used for maps. Its keys
+// should be unique. Decoders return dicts as maps.
+type OrderedDict []DictEntry
+
+// A DictEntry is a single entry of an OrderedDict.
+type DictEntry struct {
+	Key   string
+	Value interface{}
+}
+
+var orderedDictType = reflect.TypeOf(OrderedDict(nil))
+
+const (
+	// The magic and version found at the start of binary plists
+	bplistMagic   = "bplist"
+	bplistVersion = "00"
+	// The size of the trailer at the end of binary plists
+	trailerSize = 32
+)
+
+// Object markers. The low nibble of a marker byte holds
+// either a size or a count, depending on the object type.
+const (
+	markerNull  = 0x00
+	markerFalse = 0x08
+	markerTrue  = 0x09
+	markerFill  = 0x0f
+	markerInt   = 0x10
+	markerReal  = 0x20
+	markerDate  = 0x33
+	markerData  = 0x40
+	markerASCII = 0x50
+	markerUTF16 = 0x60
+	markerUID   = 0x80
+	markerArray = 0xa0
+	markerSet   = 0xc0
+	markerDict  = 0xd0
+)
@@ -0,0 +1,451 @@
+// Package binaryplist decodes and encodes binary plist files
+package binaryplist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"unicode/utf16"
+
+	"github.com/mkrautz/plist/internal/cfdate"
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+// Unmarshal parses the binary plist data and stores the result
+// in the value pointed to by v.
+func Unmarshal(data []byte, v interface{}) error {
+	dec := NewDecoder(bytes.NewBuffer(data))
+	return dec.Decode(v)
+}
+
+// A Decoder represents a plist reader that reads
+// binary plists.
+type Decoder struct {
+	r       io.Reader
+	ordered bool
+}
+
+// NewDecoder creates a new binary plist reader.
+func NewDecoder(r io.Reader) *Decoder {
+	d := new(Decoder)
+	d.r = r
+	return d
+}
+
+// UseOrderedDicts makes Decode return dicts as OrderedDict values,
+// with their entries in the order of the input, when v points to an
+// empty interface.
+func (d *Decoder) UseOrderedDicts() {
+	d.ordered = true
+}
+
+// A parser holds the state needed to read the objects
+// of a single binary plist.
+type parser struct {
+	buf        []byte
+	offsets    []uint64
+	refSize    int
+	inProgress []bool
+	objects    []interface{}
+	decoded    []bool
+	copies     int
+	ordered    bool
+}
+
+// Decode decodes a single binary plist from the decoder.
+//
+// Binary plists are not streamable: the offset table is
+// located at the end of the file, so Decode reads the
+// decoder's reader until EOF before decoding.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("plist: v must be ptr")
+	}
+
+	buf, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+
+	p, top, err := newParser(buf)
+	if err != nil {
+		return err
+	}
+	p.ordered = d.ordered && rv.Elem().Kind() == reflect.Interface
+
+	val, err := p.readObject(top)
+	if err != nil {
+		return err
+	}
+
+	return plistreflect.Set(rv.Elem(), val)
+}
+
+// newParser validates the header and trailer of the binary plist
+// in buf, reads its offset table and returns a parser along
+// with the reference of the top object.
+func newParser(buf []byte) (*parser, uint64, error) {
+	if len(buf) < len(bplistMagic)+len(bplistVersion)+trailerSize {
+		return nil, 0, errors.New("plist: binary plist too short")
+	}
+	if string(buf[:len(bplistMagic)]) != bplistMagic {
+		return nil, 0, errors.New("plist: bad binary plist magic")
+	}
+	version := string(buf[len(bplistMagic) : len(bplistMagic)+len(bplistVersion)])
+	if version != bplistVersion {
+		return nil, 0, fmt.Errorf("plist: unsupported binary plist version %q", version)
+	}
+
+	trailer := buf[len(buf)-trailerSize:]
+	offsetIntSize := int(trailer[6])
+	refSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	top := binary.BigEndian.Uint64(trailer[16:])
+	offsetTableOffset := binary.BigEndian.Uint64(trailer[24:])
+
+	if offsetIntSize < 1 || offsetIntSize > 8 || refSize < 1 || refSize > 8 {
+		return nil, 0, errors.New("plist: bad binary plist trailer")
+	}
+	if top >= numObjects {
+		return nil, 0, errors.New("plist: top object out of range")
+	}
+	tableEnd := uint64(len(buf) - trailerSize)
+	if offsetTableOffset > tableEnd || numObjects > (tableEnd-offsetTableOffset)/uint64(offsetIntSize) {
+		return nil, 0, errors.New("plist: offset table out of range")
+	}
+
+	p := &parser{
+		buf:        buf,
+		offsets:    make([]uint64, numObjects),
+		refSize:    refSize,
+		inProgress: make([]bool, numObjects),
+		objects:    make([]interface{}, numObjects),
+		decoded:    make([]bool, numObjects),
+		copies:     len(buf),
+	}
+	for i := range p.offsets {
+		start := offsetTableOffset + uint64(i*offsetIntSize)
+		off := readUint(buf[start : start+uint64(offsetIntSize)])
+		if off >= offsetTableOffset {
+			return nil, 0, fmt.Errorf("plist: offset of object %v out of range", i)
+		}
+		p.offsets[i] = off
+	}
+
+	return p, top, nil
+}
+
+// readUint reads a big-endian unsigned integer of len(b) bytes.
+func readUint(b []byte) uint64 {
+	var n uint64
+	for _, c := range b {
+		n = n<<8 | uint64(c)
+	}
+	return n
+}
+
+// bytesAt returns the n bytes found at offset off in the plist,
+// or an error if those bytes extend beyond the object area.
+func (p *parser) bytesAt(off, n uint64) ([]byte, error) {
+	end := off + n
+	if end < off || end > uint64(len(p.buf)-trailerSize) {
+		return nil, errors.New("plist: object extends beyond end of data")
+	}
+	return p.buf[off:end], nil
+}
+
+// readCount reads the count found in the low nibble of the marker
+// at offset off. If the nibble is 0xf, the count is stored in an
+// integer object following the marker. readCount returns the count
+// along with the offset of the first byte following it.
+func (p *parser) readCount(off uint64) (uint64, uint64, error) {
+	count := uint64(p.buf[off] & 0x0f)
+	off++
+	if count != 0x0f {
+		return count, off, nil
+	}
+
+	b, err := p.bytesAt(off, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if b[0]&0xf0 != markerInt {
+		return 0, 0, errors.New("plist: bad count")
+	}
+	size := uint64(1) << (b[0] & 0x0f)
+	if size > 8 {
+		return 0, 0, errors.New("plist: count too large")
+	}
+	b, err = p.bytesAt(off+1, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	return readUint(b), off + 1 + size, nil
+}
+
+// readRefs reads n object references starting at offset off.
+func (p *parser) readRefs(off, n uint64) ([]uint64, error) {
+	size := uint64(p.refSize)
+	if n > uint64(len(p.buf))/size {
+		return nil, errors.New("plist: object extends beyond end of data")
+	}
+	b, err := p.bytesAt(off, n*size)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, n)
+	for i := range refs {
+		refs[i] = readUint(b[uint64(i)*size : uint64(i+1)*size])
+	}
+	return refs, nil
+}
+
+// readObject reads the object with reference ref. Each object is
+// decoded only once. An object referenced again is copied, so that
+// changing the value at one place leaves the others alone.
+func (p *parser) readObject(ref uint64) (interface{}, error) {
+	if ref >= uint64(len(p.offsets)) {
+		return nil, fmt.Errorf("plist: object reference %v out of range", ref)
+	}
+	if p.decoded[ref] {
+		return p.copyValue(p.objects[ref])
+	}
+	if p.inProgress[ref] {
+		return nil, errors.New("plist: cyclic object reference")
+	}
+	p.inProgress[ref] = true
+	val, err := p.decodeObject(ref)
+	p.inProgress[ref] = false
+	if err != nil {
+		return nil, err
+	}
+	p.objects[ref] = val
+	p.decoded[ref] = true
+	return val, nil
+}
+
+// copyValue returns a copy of the decoded value v. Encoders share only
+// scalars, so the containers copied are bounded by the size of the
+// input, which keeps a plist whose containers reference each other
+// repeatedly from expanding exponentially.
+func (p *parser) copyValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case []byte:
+		return append([]byte(nil), v...), nil
+	case []interface{}:
+		if p.copies -= len(v) + 1; p.copies < 0 {
+			return nil, errors.New("plist: too many shared object references")
+		}
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			c, err := p.copyValue(e)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = c
+		}
+		return a, nil
+	case map[string]interface{}:
+		if p.copies -= len(v) + 1; p.copies < 0 {
+			return nil, errors.New("plist: too many shared object references")
+		}
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c, err := p.copyValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = c
+		}
+		return m, nil
+	case OrderedDict:
+		if p.copies -= len(v) + 1; p.copies < 0 {
+			return nil, errors.New("plist: too many shared object references")
+		}
+		d := make(OrderedDict, len(v))
+		for i, e := range v {
+			c, err := p.copyValue(e.Value)
+			if err != nil {
+				return nil, err
+			}
+			d[i] = DictEntry{Key: e.Key, Value: c}
+		}
+		return d, nil
+	}
+	return v, nil
+}
+
+// decodeObject decodes the object with reference ref, reading
+// the objects it contains with readObject.
+func (p *parser) decodeObject(ref uint64) (interface{}, error) {
+	off := p.offsets[ref]
+	marker := p.buf[off]
+	switch marker & 0xf0 {
+	case 0x00:
+		switch marker {
+		case markerFalse:
+			return false, nil
+		case markerTrue:
+			return true, nil
+		}
+		return nil, fmt.Errorf("plist: unsupported object marker %#02x", marker)
+	case markerInt:
+		size := uint64(1) << (marker & 0x0f)
+		b, err := p.bytesAt(off+1, size)
+		if err != nil {
+			return nil, err
+		}
+		switch size {
+		case 1, 2, 4, 8:
+			// Integers of 8 bytes are signed, smaller ones are not.
+			return int64(readUint(b)), nil
+		case 16:
+			if readUint(b[:8]) != 0 {
+				return nil, errors.New("plist: 128-bit integers are not supported")
+			}
+			n := readUint(b[8:])
+			if n <= math.MaxInt64 {
+				return int64(n), nil
+			}
+			return n, nil
+		}
+		return nil, fmt.Errorf("plist: bad integer size %v", size)
+	case markerReal:
+		size := uint64(1) << (marker & 0x0f)
+		b, err := p.bytesAt(off+1, size)
+		if err != nil {
+			return nil, err
+		}
+		switch size {
+		case 4:
+			return float64(math.Float32frombits(uint32(readUint(b)))), nil
+		case 8:
+			return math.Float64frombits(readUint(b)), nil
+		}
+		return nil, fmt.Errorf("plist: bad real size %v", size)
+	case markerDate & 0xf0:
+		if marker != markerDate {
+			return nil, fmt.Errorf("plist: bad date marker %#02x", marker)
+		}
+		b, err := p.bytesAt(off+1, 8)
+		if err != nil {
+			return nil, err
+		}
+		return cfdate.Time(math.Float64frombits(readUint(b))), nil
+	case markerData:
+		n, start, err := p.readCount(off)
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.bytesAt(start, n)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, n)
+		copy(data, b)
+		return data, nil
+	case markerASCII:
+		n, start, err := p.readCount(off)
+		if err != nil {
+			return nil, err
+		}
+		b, err := p.bytesAt(start, n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case markerUTF16:
+		n, start, err := p.readCount(off)
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(len(p.buf))/2 {
+			return nil, errors.New("plist: object extends beyond end of data")
+		}
+		b, err := p.bytesAt(start, 2*n)
+		if err != nil {
+			return nil, err
+		}
+		units := make([]uint16, n)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		}
+		return string(utf16.Decode(units)), nil
+	case markerUID:
+		b, err := p.bytesAt(off+1, uint64(marker&0xf)+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 8 {
+			return nil, errors.New("plist: UID too large")
+		}
+		return UID(readUint(b)), nil
+	case markerArray, markerSet:
+		n, start, err := p.readCount(off)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.readRefs(start, n)
+		if err != nil {
+			return nil, err
+		}
+		slice := make([]interface{}, 0, n)
+		for _, r := range refs {
+			val, err := p.readObject(r)
+			if err != nil {
+				return nil, err
+			}
+			slice = append(slice, val)
+		}
+		return slice, nil
+	case markerDict:
+		n, start, err := p.readCount(off)
+		if err != nil {
+			return nil, err
+		}
+		keyRefs, err := p.readRefs(start, n)
+		if err != nil {
+			return nil, err
+		}
+		valRefs, err := p.readRefs(start+n*uint64(p.refSize), n)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]interface{}, n)
+		var keys []string
+		for i := range keyRefs {
+			key, err := p.readObject(keyRefs[i])
+			if err != nil {
+				return nil, err
+			}
+			keyStr, ok := key.(string)
+			if !ok {
+				return nil, errors.New("plist: dict key is not a string")
+			}
+			val, err := p.readObject(valRefs[i])
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := dict[keyStr]; !dup {
+				keys = append(keys, keyStr)
+			}
+			dict[keyStr] = val
+		}
+		if p.ordered {
+			od := make(OrderedDict, len(keys))
+			for i, k := range keys {
+				od[i] = DictEntry{Key: k, Value: dict[k]}
+			}
+			return od, nil
+		}
+		return dict, nil
+	}
+
+	return nil, fmt.Errorf("plist: unsupported object marker %#02x", marker)
+}
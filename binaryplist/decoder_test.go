@@ -0,0 +1,139 @@
+package binaryplist
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Entitlements struct {
+	GetTaskAllow bool `plist:"get-task-allow"`
+}
+
+func TestDecodeEntitlements(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Entitlements.bplist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var e Entitlements
+	err = Unmarshal(buf, &e)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !e.GetTaskAllow {
+		t.Fatalf("get-task-allow not true")
+	}
+
+	var m map[string]interface{}
+	err = Unmarshal(buf, &m)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if m["get-task-allow"] != true {
+		t.Fatalf("get-task-allow not true in map")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	when := time.Date(2012, time.January, 29, 13, 7, 25, 0, time.UTC)
+	long := make([]interface{}, 20)
+	for i := range long {
+		long[i] = int64(i * 1000)
+	}
+	expected := map[string]interface{}{
+		"integer":  int64(42),
+		"negative": int64(-42),
+		"big":      uint64(1 << 63),
+		"real":     float64(3.14159265),
+		"date":     when,
+		"data":     []byte{0xff, 0xff, 0xff},
+		"ascii":    "hello",
+		"unicode":  "héllo ☃",
+		"bool":     false,
+		"array":    long,
+		"dict": map[string]interface{}{
+			"hello": "hello",
+		},
+	}
+
+	buf, err := Marshal(expected)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var actual interface{}
+	err = Unmarshal(buf, &actual)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	m := actual.(map[string]interface{})
+	if !m["date"].(time.Time).Equal(when) {
+		t.Fatalf("date mismatch: %v", m["date"])
+	}
+	m["date"] = when
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("got %#v, expected %#v", m, expected)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Entitlements.bplist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var m map[string]interface{}
+	for i := 0; i < len(buf); i++ {
+		if Unmarshal(buf[:i], &m) == nil {
+			t.Fatalf("truncated plist of length %v decoded without error", i)
+		}
+	}
+}
+
+// sharedArrays returns a binary plist in which object 0 is an empty
+// array and every later object is an array holding the one before it
+// twice.
+func sharedArrays(depth int) []byte {
+	buf := []byte("bplist00")
+	offsets := []byte{byte(len(buf))}
+	buf = append(buf, 0xa0)
+	for i := 1; i < depth; i++ {
+		offsets = append(offsets, byte(len(buf)))
+		buf = append(buf, 0xa2, byte(i-1), byte(i-1))
+	}
+	tableOffset := len(buf)
+	buf = append(buf, offsets...)
+	trailer := make([]byte, 32)
+	trailer[6] = 1
+	trailer[7] = 1
+	trailer[15] = byte(depth)
+	trailer[23] = byte(depth - 1)
+	trailer[31] = byte(tableOffset)
+	return append(buf, trailer...)
+}
+
+func TestDecodeSharedObjects(t *testing.T) {
+	// Copying each reference would take 2^64 steps.
+	var v interface{}
+	err := Unmarshal(sharedArrays(64), &v)
+	if err == nil {
+		t.Fatalf("expected error for exponentially shared objects")
+	}
+}
+
+func TestDecodeSharedObjectsAreCopied(t *testing.T) {
+	var v interface{}
+	err := Unmarshal(sharedArrays(3), &v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	a := v.([]interface{})
+	a[0].([]interface{})[0] = "changed"
+	expected := []interface{}{[]interface{}{}, []interface{}{}}
+	if !reflect.DeepEqual(a[1], expected) {
+		t.Fatalf("changing one reference changed another: %#v", a[1])
+	}
+}
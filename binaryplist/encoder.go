@@ -0,0 +1,348 @@
+package binaryplist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+	"unicode/utf16"
+
+	"github.com/mkrautz/plist/internal/cfdate"
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+// Marshal returns the binary plist encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// An Encoder encodes Go values into
+// the binary plist format.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new Encoder capable of encoding binary plists.
+func NewEncoder(w io.Writer) *Encoder {
+	enc := new(Encoder)
+	enc.w = w
+	return enc
+}
+
+// An arrayObject is an array waiting to be written,
+// holding references to its elements.
+type arrayObject []int
+
+// A dictObject is a dict waiting to be written,
+// holding references to its keys and values.
+type dictObject struct {
+	keys []int
+	vals []int
+}
+
+// A flattener flattens a tree of Go values into the list
+// of objects making up a binary plist. Scalar objects that
+// compare equal are only stored once.
+type flattener struct {
+	objects []interface{}
+	unique  map[interface{}]int
+}
+
+// Encode writes the binary plist encoding of v to the encoder's
+// writer.
+func (e *Encoder) Encode(v interface{}) error {
+	f := &flattener{unique: map[interface{}]int{}}
+	top, err := f.flatten(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+
+	refSize := intSize(uint64(len(f.objects)))
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(bplistMagic + bplistVersion)
+	offsets := make([]uint64, len(f.objects))
+	for i, obj := range f.objects {
+		offsets[i] = uint64(buf.Len())
+		writeObject(buf, obj, refSize)
+	}
+
+	offsetTableOffset := uint64(buf.Len())
+	offsetIntSize := intSize(offsetTableOffset)
+	for _, off := range offsets {
+		writeUint(buf, off, offsetIntSize)
+	}
+
+	var trailer [trailerSize]byte
+	trailer[6] = byte(offsetIntSize)
+	trailer[7] = byte(refSize)
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(f.objects)))
+	binary.BigEndian.PutUint64(trailer[16:], uint64(top))
+	binary.BigEndian.PutUint64(trailer[24:], offsetTableOffset)
+	buf.Write(trailer[:])
+
+	_, err = e.w.Write(buf.Bytes())
+	return err
+}
+
+// add adds a scalar object, reusing an existing reference
+// if an equal object has already been added.
+func (f *flattener) add(obj interface{}) int {
+	key := obj
+	switch o := obj.(type) {
+	case []byte:
+		key = dataKey(o)
+	case time.Time:
+		key = dateKey(cfdate.Seconds(o))
+	}
+	if ref, ok := f.unique[key]; ok {
+		return ref
+	}
+	ref := len(f.objects)
+	f.objects = append(f.objects, obj)
+	f.unique[key] = ref
+	return ref
+}
+
+// Types used as keys of flattener.unique for objects
+// that are not comparable, or whose equality differs
+// from that of their Go representation.
+type dataKey string
+type dateKey float64
+
+// reserve reserves a reference for a collection. Collections
+// are written before their elements, as Core Foundation does.
+func (f *flattener) reserve() int {
+	f.objects = append(f.objects, nil)
+	return len(f.objects) - 1
+}
+
+// flatten adds the object represented by rv (and any objects
+// it refers to) and returns its reference.
+func (f *flattener) flatten(rv reflect.Value) (int, error) {
+	rv, err := plistreflect.Marshal(rv)
+	if err != nil {
+		return 0, err
+	}
+	if rv.IsValid() && rv.Type() == uidType {
+		return f.add(UID(rv.Uint())), nil
+	}
+	if rv.IsValid() && rv.Type() == orderedDictType {
+		d := rv.Interface().(OrderedDict)
+		keys := make([]string, len(d))
+		vals := make([]reflect.Value, len(d))
+		for i := range d {
+			keys[i] = d[i].Key
+			vals[i] = reflect.ValueOf(&d[i].Value).Elem()
+		}
+		return f.flattenDict(keys, vals)
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return f.add(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.add(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		if u <= math.MaxInt64 {
+			return f.add(int64(u)), nil
+		}
+		return f.add(u), nil
+	case reflect.Float32, reflect.Float64:
+		return f.add(rv.Float()), nil
+	case reflect.String:
+		return f.add(rv.String()), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			buf := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(buf), rv)
+			return f.add(buf), nil
+		}
+		ref := f.reserve()
+		arr := make(arrayObject, rv.Len())
+		for i := range arr {
+			elem, err := f.flatten(rv.Index(i))
+			if err != nil {
+				return 0, err
+			}
+			arr[i] = elem
+		}
+		f.objects[ref] = arr
+		return ref, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return 0, errors.New("plist: bad map kind (must have string keys)")
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, kv := range rv.MapKeys() {
+			keys = append(keys, kv.String())
+		}
+		sort.Strings(keys)
+		vals := make([]reflect.Value, len(keys))
+		for i, k := range keys {
+			vals[i] = rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()))
+		}
+		return f.flattenDict(keys, vals)
+	case reflect.Struct:
+		if t, ok := rv.Interface().(time.Time); ok {
+			return f.add(t), nil
+		}
+		return f.flattenDict(plistreflect.StructEntries(rv))
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			return 0, errors.New("plist: cannot encode nil value")
+		}
+		return f.flatten(rv.Elem())
+	}
+	return 0, fmt.Errorf("plist: cannot encode %v", rv.Kind())
+}
+
+// flattenDict adds a dict with the given keys and values.
+func (f *flattener) flattenDict(keys []string, vals []reflect.Value) (int, error) {
+	ref := f.reserve()
+	dict := dictObject{
+		keys: make([]int, len(keys)),
+		vals: make([]int, len(vals)),
+	}
+	for i, k := range keys {
+		dict.keys[i] = f.add(k)
+	}
+	for i, v := range vals {
+		elem, err := f.flatten(v)
+		if err != nil {
+			return 0, err
+		}
+		dict.vals[i] = elem
+	}
+	f.objects[ref] = dict
+	return ref, nil
+}
+
+// intSize returns the number of bytes (1, 2, 4 or 8) needed
+// to represent n.
+func intSize(n uint64) int {
+	switch {
+	case n <= math.MaxUint8:
+		return 1
+	case n <= math.MaxUint16:
+		return 2
+	case n <= math.MaxUint32:
+		return 4
+	}
+	return 8
+}
+
+// writeUint writes n as a big-endian integer of size bytes.
+func writeUint(buf *bytes.Buffer, n uint64, size int) {
+	for i := size - 1; i >= 0; i-- {
+		buf.WriteByte(byte(n >> (uint(i) * 8)))
+	}
+}
+
+// writeInt writes an integer object.
+func writeInt(buf *bytes.Buffer, n int64) {
+	size := 8
+	if n >= 0 {
+		size = intSize(uint64(n))
+	}
+	buf.WriteByte(markerInt | byte(bitsLog2(size)))
+	writeUint(buf, uint64(n), size)
+}
+
+// bitsLog2 returns log2 of the object sizes 1, 2, 4, 8 and 16.
+func bitsLog2(size int) int {
+	n := 0
+	for size > 1 {
+		size >>= 1
+		n++
+	}
+	return n
+}
+
+// writeMarker writes a marker with the given count, using a
+// trailing integer object when it doesn't fit in the low nibble.
+func writeMarker(buf *bytes.Buffer, marker byte, count int) {
+	if count < 0x0f {
+		buf.WriteByte(marker | byte(count))
+		return
+	}
+	buf.WriteByte(marker | 0x0f)
+	writeInt(buf, int64(count))
+}
+
+// isASCII reports whether s consists only of 7-bit characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// writeObject writes a single flattened object.
+func writeObject(buf *bytes.Buffer, obj interface{}, refSize int) {
+	switch o := obj.(type) {
+	case bool:
+		if o {
+			buf.WriteByte(markerTrue)
+		} else {
+			buf.WriteByte(markerFalse)
+		}
+	case int64:
+		writeInt(buf, o)
+	case uint64:
+		buf.WriteByte(markerInt | 4)
+		writeUint(buf, 0, 8)
+		writeUint(buf, o, 8)
+	case float64:
+		buf.WriteByte(markerReal | 3)
+		writeUint(buf, math.Float64bits(o), 8)
+	case time.Time:
+		buf.WriteByte(markerDate)
+		writeUint(buf, math.Float64bits(cfdate.Seconds(o)), 8)
+	case []byte:
+		writeMarker(buf, markerData, len(o))
+		buf.Write(o)
+	case string:
+		if isASCII(o) {
+			writeMarker(buf, markerASCII, len(o))
+			buf.WriteString(o)
+		} else {
+			units := utf16.Encode([]rune(o))
+			writeMarker(buf, markerUTF16, len(units))
+			for _, u := range units {
+				writeUint(buf, uint64(u), 2)
+			}
+		}
+	case UID:
+		size := intSize(uint64(o))
+		buf.WriteByte(markerUID | byte(size-1))
+		writeUint(buf, uint64(o), size)
+	case arrayObject:
+		writeMarker(buf, markerArray, len(o))
+		for _, ref := range o {
+			writeUint(buf, uint64(ref), refSize)
+		}
+	case dictObject:
+		writeMarker(buf, markerDict, len(o.keys))
+		for _, ref := range o.keys {
+			writeUint(buf, uint64(ref), refSize)
+		}
+		for _, ref := range o.vals {
+			writeUint(buf, uint64(ref), refSize)
+		}
+	}
+}
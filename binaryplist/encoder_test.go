@@ -0,0 +1,70 @@
+package binaryplist
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestEncodeEntitlements(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Entitlements.bplist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	actual, err := Marshal(Entitlements{GetTaskAllow: true})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if !bytes.Equal(actual, buf) {
+		t.Fatalf("golden mismatch: % x", actual)
+	}
+}
+
+func TestEncodeUniquesScalars(t *testing.T) {
+	buf, err := Marshal([]string{"hello", "hello", "hello"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// header, array of 3 refs, string, offset table, trailer
+	expected := 8 + 4 + 6 + 2 + 32
+	if len(buf) != expected {
+		t.Fatalf("expected %v bytes, got %v", expected, len(buf))
+	}
+}
+
+func TestEncodeUID(t *testing.T) {
+	v := map[string]interface{}{
+		"small": UID(5),
+		"large": UID(0x12345),
+	}
+	buf, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Contains(buf, []byte{markerUID | 3, 0, 1, 0x23, 0x45}) {
+		t.Fatalf("UID not encoded in 4 bytes: % x", buf)
+	}
+
+	var actual map[string]interface{}
+	err = Unmarshal(buf, &actual)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if actual["small"] != UID(5) || actual["large"] != UID(0x12345) {
+		t.Fatalf("unexpected UIDs %#v", actual)
+	}
+
+	var s struct {
+		Small UID `plist:"small"`
+	}
+	err = Unmarshal(buf, &s)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if s.Small != 5 {
+		t.Fatalf("unexpected UID %v", s.Small)
+	}
+}
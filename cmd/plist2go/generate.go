@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A generator collects the type declarations for a node hierarchy.
+type generator struct {
+	decls   []string
+	used    map[string]bool
+	imports map[string]bool
+}
+
+// generate returns the formatted source of a file declaring the root
+// type name for n, and the types it refers to.
+func generate(n *node, name, pkg string, samples []string) ([]byte, error) {
+	g := &generator{
+		used:    make(map[string]bool),
+		imports: make(map[string]bool),
+	}
+	g.structType(n, name, "")
+
+	names := make([]string, len(samples))
+	for i, s := range samples {
+		names[i] = filepath.ToSlash(s)
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Code generated by plist2go from %s; DO NOT EDIT.\n\n", strings.Join(names, ", "))
+	fmt.Fprintf(buf, "package %s\n\n", pkg)
+	if len(g.imports) > 0 {
+		var paths []string
+		for path := range g.imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		buf.WriteString("import (\n")
+		for _, path := range paths {
+			fmt.Fprintf(buf, "\t%q\n", path)
+		}
+		buf.WriteString(")\n\n")
+	}
+	for _, decl := range g.decls {
+		buf.WriteString(decl)
+		buf.WriteString("\n")
+	}
+	return format.Source(buf.Bytes())
+}
+
+// typeOf returns the Go type for the values of n, declaring struct
+// types as needed. The name is used for those types, and for the
+// types of elements of arrays and maps, in singular form.
+func (g *generator) typeOf(n *node, name, parent string) string {
+	switch n.kind {
+	case kindString:
+		return "string"
+	case kindInteger:
+		return "int64"
+	case kindReal:
+		return "float64"
+	case kindBool:
+		return "bool"
+	case kindDate:
+		g.imports["time"] = true
+		return "time.Time"
+	case kindData:
+		return "[]byte"
+	case kindUID:
+		g.imports["github.com/mkrautz/plist"] = true
+		return "plist.UID"
+	case kindDict:
+		return g.structType(n, name, parent)
+	case kindMap:
+		if n.elem.kind == kindNone || n.elem.kind == kindAny {
+			return "map[string]interface{}"
+		}
+		return "map[string]" + g.typeOf(n.elem, singular(name), parent)
+	case kindArray:
+		if n.elem.kind == kindNone || n.elem.kind == kindAny {
+			return "[]interface{}"
+		}
+		return "[]" + g.typeOf(n.elem, singular(name), parent)
+	}
+	return "interface{}"
+}
+
+// structType declares a struct type for the dict node n and returns
+// its name. Types are declared in the order they are first referred to.
+func (g *generator) structType(n *node, name, parent string) string {
+	name = g.newName(name, parent)
+	idx := len(g.decls)
+	g.decls = append(g.decls, "")
+
+	keys := make([]string, 0, len(n.fields))
+	for key := range n.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "type %s struct {\n", name)
+	names := make(map[string]bool)
+	for _, key := range keys {
+		f := n.fields[key]
+		fieldName := goName(key)
+		for i := 2; names[fieldName]; i++ {
+			fieldName = goName(key) + strconv.Itoa(i)
+		}
+		names[fieldName] = true
+
+		tag := fmt.Sprintf("plist:%q", key)
+		if strings.Contains(tag, "`") {
+			tag = strconv.Quote(tag)
+		} else {
+			tag = "`" + tag + "`"
+		}
+		fmt.Fprintf(buf, "\t%s %s %s", fieldName, g.typeOf(f.node, fieldName, name), tag)
+		if n.optional(f) {
+			buf.WriteString(" // optional")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	g.decls[idx] = buf.String()
+	return name
+}
+
+// newName returns an unused type name based on name, prefixed with
+// the name of its parent type if needed.
+func (g *generator) newName(name, parent string) string {
+	if g.used[name] {
+		name = parent + name
+	}
+	base := name
+	for i := 2; g.used[name]; i++ {
+		name = base + strconv.Itoa(i)
+	}
+	g.used[name] = true
+	return name
+}
+
+// initialisms are written in upper case in Go names.
+var initialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true,
+	"JSON": true, "UID": true, "URI": true, "URL": true, "UUID": true,
+	"XML": true,
+}
+
+// goName returns an exported Go name for the plist key.
+func goName(key string) string {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var sb strings.Builder
+	for _, w := range words {
+		if initialisms[strings.ToUpper(w)] {
+			sb.WriteString(strings.ToUpper(w))
+			continue
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		sb.WriteString(string(r))
+	}
+	name := sb.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// singular returns the singular form of the English plural name.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 4:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 3:
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
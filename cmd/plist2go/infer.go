@@ -0,0 +1,136 @@
+package main
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/mkrautz/plist"
+)
+
+// A kind is the inferred type of the values seen in one place of the
+// samples.
+type kind int
+
+const (
+	kindNone kind = iota // no values seen yet
+	kindString
+	kindInteger
+	kindReal
+	kindBool
+	kindDate
+	kindData
+	kindUID
+	kindDict
+	kindMap
+	kindArray
+	kindAny // values of differing types
+)
+
+// A node accumulates the values seen in one place of the samples.
+type node struct {
+	kind   kind
+	fields map[string]*field // for kindDict
+	dicts  int               // number of dicts seen, for kindDict
+	elem   *node             // for kindArray and kindMap
+}
+
+// A field is a key of a dict node.
+type field struct {
+	node  *node
+	count int // number of dicts the key was seen in
+}
+
+// optional reports whether the key of f was missing from some of
+// the dicts of n.
+func (n *node) optional(f *field) bool {
+	return f.count < n.dicts
+}
+
+// dynamicKey matches dict keys that are data rather than names.
+var dynamicKey = regexp.MustCompile(`^(?i:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|[0-9]+)$`)
+
+// kindOf returns the kind of a decoded plist value.
+func kindOf(v interface{}) kind {
+	switch v := v.(type) {
+	case string:
+		return kindString
+	case int64, uint64:
+		return kindInteger
+	case float64:
+		return kindReal
+	case bool:
+		return kindBool
+	case time.Time:
+		return kindDate
+	case []byte:
+		return kindData
+	case plist.UID:
+		return kindUID
+	case []interface{}:
+		return kindArray
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return kindDict
+		}
+		for k := range v {
+			if !dynamicKey.MatchString(k) {
+				return kindDict
+			}
+		}
+		return kindMap
+	}
+	return kindAny
+}
+
+// observe merges the value v into n.
+func (n *node) observe(v interface{}) {
+	k := kindOf(v)
+	switch {
+	case n.kind == kindNone || n.kind == k:
+		n.kind = k
+	case n.kind == kindInteger && k == kindReal, n.kind == kindReal && k == kindInteger:
+		n.kind = kindReal
+	case n.kind == kindMap && k == kindDict && len(v.(map[string]interface{})) == 0:
+		// An empty dict fits any map.
+		return
+	case n.kind == kindDict && k == kindMap && len(n.fields) == 0:
+		// So far, only empty dicts were seen.
+		n.kind = kindMap
+	default:
+		n.kind = kindAny
+		n.fields = nil
+		n.elem = nil
+	}
+
+	switch n.kind {
+	case kindDict:
+		dict := v.(map[string]interface{})
+		if n.fields == nil {
+			n.fields = make(map[string]*field)
+		}
+		n.dicts++
+		for key, val := range dict {
+			f, ok := n.fields[key]
+			if !ok {
+				f = &field{node: new(node)}
+				n.fields[key] = f
+			}
+			f.count++
+			f.node.observe(val)
+		}
+	case kindMap:
+		if n.elem == nil {
+			n.elem = new(node)
+		}
+		for _, val := range v.(map[string]interface{}) {
+			n.elem.observe(val)
+		}
+	case kindArray:
+		if n.elem == nil {
+			n.elem = new(node)
+		}
+		for _, val := range v.([]interface{}) {
+			n.elem.observe(val)
+		}
+	}
+}
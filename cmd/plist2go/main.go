@@ -0,0 +1,109 @@
+// Command plist2go generates Go struct types from sample property lists.
+//
+// Usage:
+//
+//	plist2go [-type name] [-package name] [-o file] <sample>...
+//
+// The samples are read as any kind of plist and must have a dict at
+// their root. Their structure is merged into a single hierarchy of
+// struct types, whose fields carry plist tags and can be decoded into
+// with plist.Unmarshal from any kind of plist:
+//
+//   - Keys missing from some of the dicts they could appear in are
+//     marked optional.
+//   - Arrays whose elements all have the same type become slices of
+//     that type, others become []interface{}.
+//   - Dicts keyed by UUIDs or numbers become maps.
+//   - Integers become int64, reals float64, dates time.Time, data
+//     []byte and UIDs plist.UID. Integers and reals found in the same
+//     place become float64. Values of differing types become
+//     interface{}.
+//
+// The root type is named after -type, or the first sample. The package
+// defaults to $GOPACKAGE, so plist2go can be run by go generate:
+//
+//	//go:generate plist2go -type Workflow -o workflow.go testdata/info.plist
+//
+// Without -o, the generated code is written to standard output.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mkrautz/plist"
+)
+
+const usage = "Usage: plist2go [-type name] [-package name] [-o file] <sample>...\n"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs plist2go with the given arguments and returns its exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("plist2go", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	typeName := flags.String("type", "", "name of the root type")
+	pkg := flags.String("package", os.Getenv("GOPACKAGE"), "package of the generated file")
+	out := flags.String("o", "", "output file")
+	if flags.Parse(args) != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	if *pkg == "" {
+		*pkg = "main"
+	}
+	if *typeName == "" {
+		base := filepath.Base(flags.Arg(0))
+		*typeName = goName(strings.TrimSuffix(base, filepath.Ext(base)))
+	}
+
+	root := new(node)
+	for _, name := range flags.Args() {
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "plist2go: %v\n", err)
+			return 1
+		}
+		var v interface{}
+		err = plist.Unmarshal(buf, &v)
+		if err != nil {
+			fmt.Fprintf(stderr, "plist2go: %s: %v\n", name, err)
+			return 1
+		}
+		if _, ok := v.(map[string]interface{}); !ok {
+			fmt.Fprintf(stderr, "plist2go: %s: root is not a dict\n", name)
+			return 1
+		}
+		root.observe(v)
+	}
+
+	src, err := generate(root, *typeName, *pkg, flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "plist2go: %v\n", err)
+		return 1
+	}
+	if *out == "" {
+		_, err = stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(*out, src, 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "plist2go: %v\n", err)
+		return 1
+	}
+	return 0
+}
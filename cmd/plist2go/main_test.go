@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mkrautz/plist"
+)
+
+const sampleA = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>bundle-id</key>
+	<string>com.example.a</string>
+	<key>created</key>
+	<date>2012-01-29T13:07:25Z</date>
+	<key>items</key>
+	<array>
+		<dict>
+			<key>name</key>
+			<string>a</string>
+			<key>weight</key>
+			<integer>1</integer>
+		</dict>
+	</array>
+	<key>positions</key>
+	<dict>
+		<key>02659E2A-7ABB-4AFC-A9B9-62ACE375A522</key>
+		<dict>
+			<key>x</key>
+			<real>1.5</real>
+		</dict>
+	</dict>
+	<key>tags</key>
+	<array>
+		<string>a</string>
+	</array>
+</dict>
+</plist>
+`
+
+const sampleB = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>bundle-id</key>
+	<string>com.example.b</string>
+	<key>items</key>
+	<array>
+		<dict>
+			<key>icon</key>
+			<data>AP8=</data>
+			<key>name</key>
+			<string>b</string>
+			<key>weight</key>
+			<real>2.5</real>
+		</dict>
+	</array>
+	<key>misc</key>
+	<array>
+		<string>a</string>
+		<dict/>
+	</array>
+	<key>positions</key>
+	<dict/>
+	<key>tags</key>
+	<array/>
+</dict>
+</plist>
+`
+
+const expected = "// Code generated by plist2go from a.plist, b.plist; DO NOT EDIT.\n" + `
+package sample
+
+import (
+	"time"
+)
+
+type Sample struct {
+	BundleID  string              ` + "`plist:\"bundle-id\"`" + `
+	Created   time.Time           ` + "`plist:\"created\"`" + ` // optional
+	Items     []Item              ` + "`plist:\"items\"`" + `
+	Misc      []interface{}       ` + "`plist:\"misc\"`" + ` // optional
+	Positions map[string]Position ` + "`plist:\"positions\"`" + `
+	Tags      []string            ` + "`plist:\"tags\"`" + `
+}
+
+type Item struct {
+	Icon   []byte  ` + "`plist:\"icon\"`" + ` // optional
+	Name   string  ` + "`plist:\"name\"`" + `
+	Weight float64 ` + "`plist:\"weight\"`" + `
+}
+
+type Position struct {
+	X float64 ` + "`plist:\"x\"`" + `
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	for name, sample := range map[string]string{"a.plist": sampleA, "b.plist": sampleB} {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(sample), 0644)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	code := run([]string{"-type", "Sample", "-package", "sample", filepath.Join(dir, "a.plist"), filepath.Join(dir, "b.plist")}, stdout, stderr)
+	if code != 0 {
+		t.Fatalf("unexpected exit code %v: %s", code, stderr)
+	}
+	got := bytes.Replace(stdout.Bytes(), []byte(filepath.ToSlash(dir)+"/"), nil, -1)
+	if string(got) != expected {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+}
+
+// Sample, Item and Position are the types in expected.
+type Sample struct {
+	BundleID  string              `plist:"bundle-id"`
+	Created   time.Time           `plist:"created"` // optional
+	Items     []Item              `plist:"items"`
+	Misc      []interface{}       `plist:"misc"` // optional
+	Positions map[string]Position `plist:"positions"`
+	Tags      []string            `plist:"tags"`
+}
+
+type Item struct {
+	Icon   []byte  `plist:"icon"` // optional
+	Name   string  `plist:"name"`
+	Weight float64 `plist:"weight"`
+}
+
+type Position struct {
+	X float64 `plist:"x"`
+}
+
+func TestDecodeGenerated(t *testing.T) {
+	expected := map[string]Sample{
+		sampleA: {
+			BundleID:  "com.example.a",
+			Created:   time.Date(2012, 1, 29, 13, 7, 25, 0, time.UTC),
+			Items:     []Item{{Name: "a", Weight: 1}},
+			Positions: map[string]Position{"02659E2A-7ABB-4AFC-A9B9-62ACE375A522": {X: 1.5}},
+			Tags:      []string{"a"},
+		},
+		sampleB: {
+			BundleID:  "com.example.b",
+			Items:     []Item{{Icon: []byte{0x00, 0xff}, Name: "b", Weight: 2.5}},
+			Misc:      []interface{}{"a", map[string]interface{}{}},
+			Positions: map[string]Position{},
+			Tags:      []string{},
+		},
+	}
+
+	for sample, want := range expected {
+		var v interface{}
+		err := plist.Unmarshal([]byte(sample), &v)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		for _, kind := range []plist.Kind{plist.XML, plist.Binary, plist.ASCII} {
+			buf := new(bytes.Buffer)
+			err = plist.NewSpecificEncoder(buf, kind).Encode(v)
+			if err != nil {
+				t.Fatalf("%v: %v", kind, err)
+			}
+			var got Sample
+			err = plist.Unmarshal(buf.Bytes(), &got)
+			if err != nil {
+				t.Fatalf("%v: %v", kind, err)
+			}
+			got.Created = got.Created.UTC()
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("%v: got %#v, expected %#v", kind, got, want)
+			}
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for key, name := range map[string]string{
+		"CFBundleIdentifier":  "CFBundleIdentifier",
+		"bundleid":            "Bundleid",
+		"uid":                 "UID",
+		"web-url":             "WebURL",
+		"UIDeviceFamily~ipad": "UIDeviceFamilyIpad",
+		"2x":                  "X2x",
+	} {
+		if got := goName(key); got != name {
+			t.Errorf("goName(%q) = %q, expected %q", key, got, name)
+		}
+	}
+}
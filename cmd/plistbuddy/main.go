@@ -0,0 +1,129 @@
+// Command plistbuddy reads and modifies property list files using
+// the command language of Apple's PlistBuddy.
+//
+// Usage:
+//
+//	plistbuddy [-cxh] <file.plist>
+//
+// Each -c option runs a single command, after which the file is saved
+// if it was modified. Without -c, commands are read interactively from
+// standard input. Run plistbuddy -h for the list of commands.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mkrautz/plist/plistbuddy"
+)
+
+const usage = `Usage: plistbuddy [-cxh] <file.plist>
+    -c "<command>" execute command, otherwise run in interactive mode
+    -x output will be in the form of an xml plist where appropriate
+    -h print the complete help info, with command guide
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs plistbuddy with the given arguments and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var commands []string
+	var file string
+	xml := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-c":
+			if i+1 >= len(args) {
+				fmt.Fprint(stderr, usage)
+				return 1
+			}
+			i++
+			commands = append(commands, args[i])
+		case "-x":
+			xml = true
+		case "-h":
+			fmt.Fprint(stdout, usage)
+			b := &plistbuddy.Buddy{Out: stdout}
+			b.Exec("Help")
+			return 0
+		default:
+			if strings.HasPrefix(args[i], "-") || file != "" {
+				fmt.Fprint(stderr, usage)
+				return 1
+			}
+			file = args[i]
+		}
+	}
+	if file == "" {
+		fmt.Fprint(stderr, usage)
+		return 1
+	}
+
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		fmt.Fprintf(stdout, "File Doesn't Exist, Will Create: %s\n", file)
+	}
+	b, err := plistbuddy.Open(file)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error Reading File: %s\n", file)
+		return 1
+	}
+	b.Out = stdout
+	b.XML = xml
+
+	if len(commands) > 0 {
+		return runCommands(b, commands, stderr)
+	}
+	return interact(b, stdin, stdout, stderr)
+}
+
+// runCommands runs the commands given with -c, stopping at the first
+// one that fails. The file is saved if all commands succeeded.
+func runCommands(b *plistbuddy.Buddy, commands []string, stderr io.Writer) int {
+	for _, cmd := range commands {
+		err := b.Exec(cmd)
+		if err == plistbuddy.ErrExit {
+			break
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	if b.Modified() {
+		err := b.Save()
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// interact reads commands from stdin until Exit, Quit or EOF.
+// Changes are only written by an explicit Save command.
+func interact(b *plistbuddy.Buddy, stdin io.Reader, stdout, stderr io.Writer) int {
+	s := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "Command: ")
+		if !s.Scan() {
+			fmt.Fprintln(stdout)
+			return 0
+		}
+		line := s.Text()
+		if strings.EqualFold(strings.TrimSpace(line), "save") {
+			fmt.Fprintln(stdout, "Saving...")
+		}
+		err := b.Exec(line)
+		if err == plistbuddy.ErrExit {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+	}
+}
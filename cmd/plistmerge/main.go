@@ -0,0 +1,81 @@
+// Command plistmerge is a git merge driver for property list files.
+//
+// Usage:
+//
+//	plistmerge <base> <ours> <theirs> [<path>]
+//
+// The three files are merged key by key and the result is written
+// back to ours, in the kind of plist ours was. If the same key path
+// was changed differently on both sides, each conflict is reported
+// on standard error, ours keeps our value and plistmerge exits with
+// status 1.
+//
+// To use it, add the following to .git/config or ~/.gitconfig:
+//
+//	[merge "plist"]
+//		name = plist merge driver
+//		driver = plistmerge %O %A %B %P
+//
+// and mark plists in .gitattributes:
+//
+//	*.plist merge=plist
+//	*.entitlements merge=plist
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/plistmerge"
+)
+
+const usage = "Usage: plistmerge <base> <ours> <theirs> [<path>]\n"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run runs plistmerge with the given arguments and returns its exit code.
+func run(args []string, stderr io.Writer) int {
+	if len(args) != 3 && len(args) != 4 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	name := args[1]
+	if len(args) == 4 {
+		name = args[3]
+	}
+
+	var bufs [3][]byte
+	for i := range bufs {
+		buf, err := ioutil.ReadFile(args[i])
+		if err != nil {
+			fmt.Fprintf(stderr, "plistmerge: %v\n", err)
+			return 2
+		}
+		bufs[i] = buf
+	}
+
+	merged, conflicts, err := plistmerge.MergeData(bufs[0], bufs[1], bufs[2])
+	if err != nil {
+		fmt.Fprintf(stderr, "plistmerge: %s: %v\n", name, err)
+		return 2
+	}
+
+	err = plist.ReplaceFile(args[1], merged)
+	if err != nil {
+		fmt.Fprintf(stderr, "plistmerge: %v\n", err)
+		return 2
+	}
+
+	if len(conflicts) > 0 {
+		for _, c := range conflicts {
+			fmt.Fprintf(stderr, "CONFLICT (plist): %s: %v\n", name, c)
+		}
+		return 1
+	}
+	return 0
+}
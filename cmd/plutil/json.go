@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/mkrautz/plist"
+)
+
+var errInvalidObject = errors.New("invalid object in plist for destination format")
+
+// toJSON encodes v as JSON, the way plutil -convert json does.
+// Dates and data have no JSON representation and are rejected.
+func toJSON(v interface{}, readable bool) ([]byte, error) {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		return nil, errInvalidObject
+	}
+	buf, err := plist.ToJSON(v, plist.PlainJSON)
+	if err != nil {
+		return nil, errInvalidObject
+	}
+	if !readable {
+		return buf, nil
+	}
+
+	indented := new(bytes.Buffer)
+	err = json.Indent(indented, buf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// fromJSON decodes JSON into plist values. Numbers without
+// a fraction or exponent become integers.
+func fromJSON(buf []byte) (interface{}, error) {
+	var v interface{}
+	err := plist.FromJSON(buf, &v, plist.PlainJSON)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
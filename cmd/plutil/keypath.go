@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mkrautz/plist"
+)
+
+// insertValue stores val at keyPath in root. It is an error for a
+// value to exist at the key path already, except when the key path
+// ends in an array index, in which case val is inserted into the
+// array at that index.
+func insertValue(root *interface{}, keyPath string, val interface{}) error {
+	comps, err := plist.SplitKeyPath(keyPath)
+	if err != nil || len(comps) == 0 {
+		return fmt.Errorf("Key path not found %s", keyPath)
+	}
+	parentPath := plist.JoinKeyPath(comps[:len(comps)-1]...)
+	parent, err := plist.Get(*root, parentPath)
+	if err != nil {
+		return fmt.Errorf("Key path not found %s", keyPath)
+	}
+
+	if a, ok := parent.([]interface{}); ok {
+		i, err := strconv.Atoi(comps[len(comps)-1])
+		if err != nil || i < 0 || i > len(a) {
+			return fmt.Errorf("Key path not found %s", keyPath)
+		}
+		a = append(a, nil)
+		copy(a[i+1:], a[i:])
+		a[i] = val
+		return plist.Set(root, parentPath, a)
+	}
+
+	if plist.Exists(*root, keyPath) {
+		return fmt.Errorf("Value already exists at key path %s", keyPath)
+	}
+	return replaceValue(root, keyPath, val)
+}
+
+// replaceValue stores val at keyPath in root, replacing any
+// existing value.
+func replaceValue(root *interface{}, keyPath string, val interface{}) error {
+	err := plist.Set(root, keyPath, val)
+	if errors.Is(err, plist.ErrNotFound) {
+		return fmt.Errorf("Key path not found %s", keyPath)
+	}
+	return err
+}
+
+// appendValue appends val to the array found at keyPath in root.
+func appendValue(root *interface{}, keyPath string, val interface{}) error {
+	a, err := plist.GetArray(*root, keyPath)
+	if errors.Is(err, plist.ErrNotFound) {
+		return fmt.Errorf("Key path not found %s", keyPath)
+	}
+	if err != nil {
+		return fmt.Errorf("Appending to a non-array at key path %s", keyPath)
+	}
+	return plist.Set(root, keyPath, append(a, val))
+}
+
+// removeValue removes the value at keyPath from root.
+func removeValue(root *interface{}, keyPath string) error {
+	err := plist.Delete(root, keyPath)
+	if err != nil {
+		return fmt.Errorf("No value to remove at key path %s", keyPath)
+	}
+	return nil
+}
@@ -0,0 +1,528 @@
+// Command plutil checks, converts and edits property list files.
+//
+// It mimics the command line interface of the plutil tool that
+// ships with macOS, including its messages and exit codes, so
+// that it can be used as a drop-in replacement on other systems.
+// Run plutil -help for usage information.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist"
+)
+
+const usage = `plutil: [command_option] [other_options] file...
+The file '-' means stdin
+Command options are (-lint is the default):
+ -help                         show this message and exit
+ -lint                         check the property list files for syntax errors
+ -convert fmt                  rewrite property list files in format
+                               fmt is one of: xml1 binary1 json openstep
+ -p                            print property list in a human-readable fashion
+                               (not for machine parsing! this 'format' is not stable)
+ -insert keypath -type value   insert a value into the property list before writing it out
+                               keypath is a key-value coding key path, with one extension:
+                               a numerical path component applied to an array will act on the object at that index in the array
+                               or insert it into the array if the numerical path component is the last one in the key path
+                               array indices may also be written as 'key[index]', and a literal '.', '[', ']' or '\'
+                               in a key may be escaped with a backslash
+                               type is one of: bool, integer, float, date, string, data, xml, json, array, dictionary
+                               -append may be specified as option for -insert to append value to array at keypath
+ -replace keypath -type value  same as -insert, but it will overwrite an existing value
+ -remove keypath               removes the value at 'keypath' from the property list before writing it out
+ -extract keypath fmt          outputs a portion of the property list at 'keypath' in format 'fmt'
+                               fmt is one of: xml1 binary1 json openstep raw
+There are some additional optional arguments that apply to the -convert, -insert, -remove, -replace, and -extract verbs:
+ -s                            be silent on success
+ -o path                       specify alternate file path name for result;
+                               the -o option is used with -convert, and is only
+                               useful with one file argument (last file overwrites);
+                               the path '-' means stdout
+ -e extension                  specify alternate extension for converted files
+ -r                            if writing JSON, output in human-readable form
+ -n                            prevent printing a terminating newline if it is not part of the format, such as with raw
+ --                            specifies that all further arguments are file names
+`
+
+// The commands understood by plutil.
+const (
+	cmdLint    = "lint"
+	cmdConvert = "convert"
+	cmdPrint   = "print"
+	cmdInsert  = "insert"
+	cmdReplace = "replace"
+	cmdRemove  = "remove"
+	cmdExtract = "extract"
+)
+
+// The value types accepted by -insert and -replace.
+var valueTypes = map[string]bool{
+	"bool":       true,
+	"integer":    true,
+	"float":      true,
+	"date":       true,
+	"string":     true,
+	"data":       true,
+	"xml":        true,
+	"json":       true,
+	"array":      true,
+	"dictionary": true,
+}
+
+// options holds the parsed command line of a plutil invocation.
+type options struct {
+	command   string
+	format    string
+	keyPath   string
+	valueType string
+	value     string
+	append    bool
+	output    string
+	extension string
+	silent    bool
+	readable  bool
+	noNewline bool
+	files     []string
+}
+
+// A usageError is returned by parseArgs for malformed command lines.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs plutil with the given arguments and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	opts, err := parseArgs(args)
+	if err == errHelp {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		fmt.Fprint(stderr, usage)
+		return 1
+	}
+
+	t := &tool{
+		opts:   opts,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	status := 0
+	for _, file := range opts.files {
+		if !t.process(file) {
+			status = 1
+		}
+	}
+	return status
+}
+
+var errHelp = errors.New("help requested")
+
+// parseArgs parses a plutil command line.
+func parseArgs(args []string) (*options, error) {
+	opts := &options{}
+
+	// next returns the argument following the option at index i.
+	next := func(i *int, opt string) (string, error) {
+		if *i+1 >= len(args) {
+			return "", usageError("Missing argument for " + opt + ".")
+		}
+		*i++
+		return args[*i], nil
+	}
+
+	setCommand := func(cmd string) error {
+		if opts.command != "" {
+			return usageError("Only one command option may be specified.")
+		}
+		opts.command = cmd
+		return nil
+	}
+
+	var err error
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			opts.files = append(opts.files, args[i+1:]...)
+			break
+		}
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			opts.files = append(opts.files, arg)
+			continue
+		}
+
+		switch arg {
+		case "-help", "--help", "-h":
+			return nil, errHelp
+		case "-lint":
+			err = setCommand(cmdLint)
+		case "-p":
+			err = setCommand(cmdPrint)
+		case "-convert":
+			err = setCommand(cmdConvert)
+			if err == nil {
+				opts.format, err = next(&i, arg)
+			}
+		case "-extract":
+			err = setCommand(cmdExtract)
+			if err == nil {
+				opts.keyPath, err = next(&i, arg)
+			}
+			if err == nil {
+				opts.format, err = next(&i, arg)
+			}
+		case "-insert", "-replace":
+			err = setCommand(arg[1:])
+			if err == nil {
+				opts.keyPath, err = next(&i, arg)
+			}
+			if err == nil {
+				var typ string
+				typ, err = next(&i, arg)
+				if err == nil && (!strings.HasPrefix(typ, "-") || !valueTypes[typ[1:]]) {
+					err = usageError("Invalid type for " + arg + ": " + typ)
+				}
+				if err == nil {
+					opts.valueType = typ[1:]
+					if opts.valueType != "array" && opts.valueType != "dictionary" {
+						opts.value, err = next(&i, arg)
+					}
+				}
+			}
+		case "-remove":
+			err = setCommand(cmdRemove)
+			if err == nil {
+				opts.keyPath, err = next(&i, arg)
+			}
+		case "-append":
+			opts.append = true
+		case "-o":
+			opts.output, err = next(&i, arg)
+		case "-e":
+			opts.extension, err = next(&i, arg)
+		case "-s":
+			opts.silent = true
+		case "-r":
+			opts.readable = true
+		case "-n":
+			opts.noNewline = true
+		default:
+			err = usageError("unrecognized option: " + arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.command == "" {
+		opts.command = cmdLint
+	}
+	if len(opts.files) == 0 {
+		return nil, usageError("No files specified.")
+	}
+	if opts.append && opts.command != cmdInsert {
+		return nil, usageError("-append may only be used with -insert.")
+	}
+
+	switch opts.command {
+	case cmdConvert:
+		switch opts.format {
+		case "xml1", "binary1", "json", "openstep":
+		default:
+			return nil, usageError("Unknown format specifier: " + opts.format)
+		}
+	case cmdExtract:
+		switch opts.format {
+		case "xml1", "binary1", "json", "openstep", "raw":
+		default:
+			return nil, usageError("Unknown format specifier: " + opts.format)
+		}
+	}
+
+	return opts, nil
+}
+
+// A tool processes the files given on the command line
+// according to its options.
+type tool struct {
+	opts   *options
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// process runs the tool's command on a single file. Errors are
+// reported to stderr, prefixed with the file name. It returns
+// false if the command failed.
+func (t *tool) process(file string) bool {
+	err := t.processFile(file)
+	if err != nil {
+		fmt.Fprintf(t.stderr, "%s: %v\n", file, err)
+		return false
+	}
+	return true
+}
+
+func (t *tool) processFile(file string) error {
+	buf, err := t.readFile(file)
+	if err != nil {
+		return err
+	}
+
+	var v interface{}
+	kind, err := plist.UnmarshalWithKind(buf, &v)
+	if err != nil {
+		return err
+	}
+
+	switch t.opts.command {
+	case cmdLint:
+		if !t.opts.silent {
+			fmt.Fprintf(t.stdout, "%s: OK\n", file)
+		}
+		return nil
+	case cmdPrint:
+		_, err = io.WriteString(t.stdout, prettyPrint(v))
+		return err
+	case cmdConvert:
+		out, err := encode(v, t.opts.format, t.opts.readable)
+		if err != nil {
+			return err
+		}
+		return t.writeResult(file, out, false)
+	case cmdExtract:
+		v, err := plist.Get(v, t.opts.keyPath)
+		if err != nil {
+			return fmt.Errorf("Could not extract value, error: No value at that key path or invalid key path: %s", t.opts.keyPath)
+		}
+		var out []byte
+		if t.opts.format == "raw" {
+			out, err = formatRaw(v, t.opts.keyPath)
+			if err == nil && !t.opts.noNewline {
+				out = append(out, '\n')
+			}
+		} else {
+			out, err = encode(v, t.opts.format, t.opts.readable)
+		}
+		if err != nil {
+			return fmt.Errorf("Could not extract value, error: %v", err)
+		}
+		return t.writeResult(file, out, true)
+	case cmdInsert, cmdReplace, cmdRemove:
+		err = t.modify(&v)
+		if err != nil {
+			return fmt.Errorf("Could not modify plist, error: %v", err)
+		}
+		out, err := encodeKind(v, kind)
+		if err != nil {
+			return err
+		}
+		return t.writeResult(file, out, false)
+	}
+
+	panic("unreachable")
+}
+
+// modify applies the tool's -insert, -replace or -remove command to root.
+func (t *tool) modify(root *interface{}) error {
+	if t.opts.command == cmdRemove {
+		return removeValue(root, t.opts.keyPath)
+	}
+
+	val, err := parseValue(t.opts.valueType, t.opts.value)
+	if err != nil {
+		return err
+	}
+	switch {
+	case t.opts.append:
+		return appendValue(root, t.opts.keyPath, val)
+	case t.opts.command == cmdReplace:
+		return replaceValue(root, t.opts.keyPath, val)
+	}
+	return insertValue(root, t.opts.keyPath, val)
+}
+
+// readFile reads the named file. The file "-" is stdin.
+func (t *tool) readFile(file string) ([]byte, error) {
+	if file == "-" {
+		return ioutil.ReadAll(t.stdin)
+	}
+	fi, err := os.Stat(file)
+	if err != nil || !fi.Mode().IsRegular() {
+		return nil, errors.New("file does not exist or is not readable or is not a regular file")
+	}
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.New("file does not exist or is not readable or is not a regular file")
+	}
+	return buf, nil
+}
+
+// writeResult writes the result of processing file to the location
+// given by the -o and -e options. Without those, the result replaces
+// file, unless toStdout is set or the input was read from stdin.
+func (t *tool) writeResult(file string, out []byte, toStdout bool) error {
+	path := file
+	if t.opts.output != "" {
+		path = t.opts.output
+	} else if t.opts.extension != "" && file != "-" {
+		path = strings.TrimSuffix(file, filepath.Ext(file)) + "." + t.opts.extension
+	} else if toStdout {
+		path = "-"
+	}
+
+	if path == "-" {
+		_, err := t.stdout.Write(out)
+		return err
+	}
+
+	return plist.ReplaceFile(path, out)
+}
+
+// encodeKind encodes v as a plist of the given kind.
+func encodeKind(v interface{}, kind plist.Kind) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := plist.NewSpecificEncoder(buf, kind)
+	if enc == nil {
+		return nil, errors.New("invalid object in plist for destination format")
+	}
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encode encodes v in the format named by format, as given to
+// the -convert and -extract options.
+func encode(v interface{}, format string, readable bool) ([]byte, error) {
+	switch format {
+	case "xml1":
+		return encodeKind(v, plist.XML)
+	case "binary1":
+		return encodeKind(v, plist.Binary)
+	case "openstep":
+		return encodeKind(v, plist.ASCII)
+	case "json":
+		return toJSON(v, readable)
+	}
+	return nil, errors.New("Unknown format specifier: " + format)
+}
+
+// formatRaw formats a scalar value for -extract's raw format.
+func formatRaw(v interface{}, keyPath string) ([]byte, error) {
+	switch val := v.(type) {
+	case string:
+		return []byte(val), nil
+	case bool:
+		return []byte(strconv.FormatBool(val)), nil
+	case int64:
+		return []byte(strconv.FormatInt(val, 10)), nil
+	case uint64:
+		return []byte(strconv.FormatUint(val, 10)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(val, 'f', -1, 64)), nil
+	case time.Time:
+		return []byte(val.UTC().Format(time.RFC3339)), nil
+	case []byte:
+		return []byte(base64.StdEncoding.EncodeToString(val)), nil
+	}
+	return nil, fmt.Errorf("Value at [%s] is a %s type and cannot be extracted in raw format", keyPath, typeName(v))
+}
+
+// typeName returns the plist type name of v.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "dictionary"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "float"
+	case time.Time:
+		return "date"
+	case []byte:
+		return "data"
+	case plist.UID:
+		return "uid"
+	}
+	return "unknown"
+}
+
+// parseValue parses the value given to -insert or -replace
+// according to its type.
+func parseValue(typ, value string) (interface{}, error) {
+	switch typ {
+	case "bool":
+		switch strings.ToLower(value) {
+		case "yes", "true", "1":
+			return true, nil
+		case "no", "false", "0":
+			return false, nil
+		}
+	case "integer":
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err == nil {
+			return i, nil
+		}
+	case "float":
+		f, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			return f, nil
+		}
+	case "date":
+		d, err := time.Parse(time.RFC3339, value)
+		if err == nil {
+			return d.UTC(), nil
+		}
+	case "string":
+		return value, nil
+	case "data":
+		buf, err := base64.StdEncoding.DecodeString(value)
+		if err == nil {
+			return buf, nil
+		}
+	case "xml":
+		var v interface{}
+		err := plist.Unmarshal([]byte(value), &v)
+		if err == nil {
+			return v, nil
+		}
+	case "json":
+		v, err := fromJSON([]byte(value))
+		if err == nil {
+			return v, nil
+		}
+	case "array":
+		return []interface{}{}, nil
+	case "dictionary":
+		return map[string]interface{}{}, nil
+	}
+	return nil, fmt.Errorf("Invalid value for -%s: %s", typ, value)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// copyTestFile copies the named file into a temporary directory
+// and returns the path of the copy.
+func copyTestFile(t *testing.T, name string) string {
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	path := filepath.Join(t.TempDir(), filepath.Base(name))
+	err = ioutil.WriteFile(path, buf, 0644)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return path
+}
+
+func runPlutil(args ...string) (int, string, string) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	code := run(args, strings.NewReader(""), stdout, stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestLint(t *testing.T) {
+	path := copyTestFile(t, "../../xmlplist/testdata/Entitlements.plist")
+	code, stdout, _ := runPlutil("-lint", path)
+	if code != 0 {
+		t.Fatalf("unexpected exit code %v", code)
+	}
+	if stdout != path+": OK\n" {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+
+	code, _, stderr := runPlutil("-lint", path+".missing")
+	if code != 1 {
+		t.Fatalf("unexpected exit code %v", code)
+	}
+	if !strings.Contains(stderr, "file does not exist") {
+		t.Fatalf("unexpected error output %q", stderr)
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	path := copyTestFile(t, "../../xmlplist/testdata/Struct.plist.golden")
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, format := range []string{"binary1", "xml1"} {
+		code, _, stderr := runPlutil("-convert", format, path)
+		if code != 0 {
+			t.Fatalf("converting to %v failed: %v", format, stderr)
+		}
+	}
+
+	actual, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("round trip mismatch:\n%s", actual)
+	}
+}
+
+func TestConvertOpenStep(t *testing.T) {
+	path := copyTestFile(t, "../../xmlplist/testdata/DecodeEverything.plist")
+	code, stdout, stderr := runPlutil("-convert", "openstep", "-o", "-", path)
+	if code != 0 {
+		t.Fatalf("%v", stderr)
+	}
+
+	expected := `(
+	42,
+	50,
+	"2012-01-29 13:07:25 +0000",
+	<ffffff>,
+	hello,
+	{
+		hey = ok;
+	}
+)
+`
+	if stdout != expected {
+		t.Fatalf("unexpected output:\n%s", stdout)
+	}
+}
+
+func TestConvertJSONRejectsDates(t *testing.T) {
+	path := copyTestFile(t, "../../xmlplist/testdata/Date.plist")
+	code, _, stderr := runPlutil("-convert", "json", "-o", "-", path)
+	if code != 1 {
+		t.Fatalf("unexpected exit code %v", code)
+	}
+	if !strings.Contains(stderr, "invalid object in plist for destination format") {
+		t.Fatalf("unexpected error output %q", stderr)
+	}
+}
+
+func TestInsertReplaceRemove(t *testing.T) {
+	path := copyTestFile(t, "../../xmlplist/testdata/RecursiveEntitlements.plist")
+
+	code, _, stderr := runPlutil("-insert", "Entitlements.get-task-allow", "-bool", "NO", path)
+	if code != 1 || !strings.Contains(stderr, "Value already exists at key path Entitlements.get-task-allow") {
+		t.Fatalf("insert over existing value: %v %q", code, stderr)
+	}
+
+	steps := [][]string{
+		{"-replace", "Entitlements.get-task-allow", "-bool", "NO"},
+		{"-insert", "Groups", "-array"},
+		{"-insert", "Groups", "-string", "b", "-append"},
+		{"-insert", "Groups.0", "-string", "a"},
+		{"-insert", "com\\.example\\.key", "-integer", "42"},
+		{"-remove", "get-task-allow"},
+	}
+	for _, step := range steps {
+		code, _, stderr = runPlutil(append(step, path)...)
+		if code != 0 {
+			t.Fatalf("%v failed: %v", step, stderr)
+		}
+	}
+
+	expected := `{
+  "Entitlements" => {
+    "get-task-allow" => false
+  }
+  "Groups" => [
+    0 => "a"
+    1 => "b"
+  ]
+  "com.example.key" => 42
+}
+`
+	code, stdout, stderr := runPlutil("-p", path)
+	if code != 0 {
+		t.Fatalf("%v", stderr)
+	}
+	if stdout != expected {
+		t.Fatalf("unexpected result:\n%s", stdout)
+	}
+
+	code, _, stderr = runPlutil("-remove", "get-task-allow", path)
+	if code != 1 || !strings.Contains(stderr, "No value to remove at key path get-task-allow") {
+		t.Fatalf("remove of missing value: %v %q", code, stderr)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	path := copyTestFile(t, "../../xmlplist/testdata/DecodeEverything.plist")
+
+	code, stdout, stderr := runPlutil("-extract", "5.hey", "raw", path)
+	if code != 0 {
+		t.Fatalf("%v", stderr)
+	}
+	if stdout != "ok\n" {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+
+	code, stdout, stderr = runPlutil("-extract", "5", "json", "-o", "-", path)
+	if code != 0 {
+		t.Fatalf("%v", stderr)
+	}
+	if stdout != `{"hey":"ok"}` {
+		t.Fatalf("unexpected output %q", stdout)
+	}
+
+	code, _, stderr = runPlutil("-extract", "6", "raw", path)
+	if code != 1 || !strings.Contains(stderr, "No value at that key path or invalid key path: 6") {
+		t.Fatalf("extract of missing value: %v %q", code, stderr)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := runPlutil("-convert")
+	if code != 1 || !strings.HasPrefix(stderr, "Missing argument for -convert.") {
+		t.Fatalf("unexpected result: %v %q", code, stderr)
+	}
+
+	code, stdout, _ := runPlutil("-help")
+	if code != 0 || !strings.HasPrefix(stdout, "plutil: ") {
+		t.Fatalf("unexpected help result: %v %q", code, stdout)
+	}
+}
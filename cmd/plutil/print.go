@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/internal/dictkeys"
+)
+
+// The number of data bytes shown in full by -p. Longer
+// data is abbreviated to its first and last bytes.
+const printDataLimit = 24
+
+// prettyPrint formats v the way plutil -p does.
+func prettyPrint(v interface{}) string {
+	buf := new(bytes.Buffer)
+	printValue(buf, v, 0)
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+func printValue(buf *bytes.Buffer, v interface{}, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch val := v.(type) {
+	case map[string]interface{}:
+		buf.WriteString("{\n")
+		for _, k := range dictkeys.Sorted(val) {
+			buf.WriteString(indent + "  " + strconv.Quote(k) + " => ")
+			printValue(buf, val[k], depth+1)
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+	case []interface{}:
+		buf.WriteString("[\n")
+		for i, elem := range val {
+			fmt.Fprintf(buf, "%s  %d => ", indent, i)
+			printValue(buf, elem, depth+1)
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "]")
+	case string:
+		buf.WriteString(strconv.Quote(val))
+	case time.Time:
+		buf.WriteString(val.UTC().Format("2006-01-02 15:04:05 -0700"))
+	case []byte:
+		var str string
+		if len(val) <= printDataLimit {
+			str = hex.EncodeToString(val)
+		} else {
+			str = hex.EncodeToString(val[:8]) + " ... " + hex.EncodeToString(val[len(val)-8:])
+		}
+		fmt.Fprintf(buf, "{length = %d, bytes = 0x%s}", len(val), str)
+	case float64:
+		buf.WriteString(strconv.FormatFloat(val, 'f', -1, 64))
+	case plist.UID:
+		fmt.Fprintf(buf, "<CFKeyedArchiverUID>{value = %d}", val)
+	default:
+		fmt.Fprint(buf, val)
+	}
+}
@@ -0,0 +1,271 @@
+package plist
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"unicode/utf16"
+
+	"github.com/mkrautz/plist/internal/asciilex"
+)
+
+// binaryVersions describes the binary plist versions. Only bplist00
+// can be read; the others are written by newer versions of
+// CoreFoundation and Foundation.
+var binaryVersions = map[string]bool{
+	"00": true,
+	"15": false,
+	"16": false,
+	"17": false,
+}
+
+// An encoding is the character encoding of a text plist.
+type encoding int
+
+const (
+	encUTF8 encoding = iota
+	encUTF8BOM
+	encUTF16LE
+	encUTF16BE
+)
+
+// errShort is returned by sniff when it needs more of the input to
+// tell the kind.
+var errShort = errors.New("plist: short input")
+
+// DetectKind determines the kind of the plist read from r by looking
+// at as few bytes at its start as it can. It skips a byte order mark,
+// whitespace and comments, reads UTF-16 as well as UTF-8, and tells a
+// JSON object from an ASCII dict by the separator after its first key.
+// Strings files, which are ASCII dicts without the enclosing braces,
+// are reported as ASCII.
+//
+// For a binary plist of a version other than bplist00, DetectKind
+// returns Binary along with an error naming the version.
+//
+// DetectKind consumes the bytes it reads from r, unless r is a
+// *bufio.Reader, which it only peeks at.
+func DetectKind(r io.Reader) (Kind, error) {
+	kind, _, err := peekKind(bufio.NewReader(r))
+	return kind, err
+}
+
+// peekKind determines the kind and encoding of the plist read by br
+// without consuming any of it. It peeks at a growing prefix until it
+// can tell, or until the buffer of br is full.
+func peekKind(br *bufio.Reader) (Kind, encoding, error) {
+	for n := 64; ; n *= 2 {
+		if n > br.Size() {
+			n = br.Size()
+		}
+		head, err := br.Peek(n)
+		eof := err != nil
+		if len(head) == 0 {
+			return Unknown, encUTF8, errors.New("plist: empty input")
+		}
+		kind, enc, err := sniff(head, eof)
+		if err != errShort {
+			return kind, enc, err
+		}
+		if eof || n == br.Size() {
+			return Unknown, enc, errors.New("plist: unknown kind")
+		}
+	}
+}
+
+// sniff determines the kind and encoding of the plist that starts
+// with head. It returns errShort if it needs more input, unless eof
+// is set, in which case head is all of it.
+func sniff(head []byte, eof bool) (Kind, encoding, error) {
+	if bytes.HasPrefix(head, []byte("bplist")) {
+		if len(head) < 8 {
+			if !eof {
+				return Unknown, encUTF8, errShort
+			}
+			return Binary, encUTF8, errors.New("plist: binary plist too short")
+		}
+		version := string(head[6:8])
+		supported, known := binaryVersions[version]
+		if !known {
+			return Unknown, encUTF8, fmt.Errorf("plist: unknown binary plist version %q", version)
+		}
+		if !supported {
+			return Binary, encUTF8, fmt.Errorf("plist: unsupported binary plist version %q", version)
+		}
+		return Binary, encUTF8, nil
+	}
+
+	enc, text := decodeHead(head)
+	kind, err := sniffText(text, eof)
+	return kind, enc, err
+}
+
+// decodeHead determines the encoding of the text in head, and returns
+// the text converted to UTF-8 without its byte order mark. A trailing
+// partial UTF-16 unit is left out.
+func decodeHead(head []byte) (encoding, []byte) {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xef, 0xbb, 0xbf}):
+		return encUTF8BOM, head[3:]
+	case bytes.HasPrefix(head, []byte{0xff, 0xfe}):
+		return encUTF16LE, utf16ToUTF8(head[2:], encUTF16LE)
+	case bytes.HasPrefix(head, []byte{0xfe, 0xff}):
+		return encUTF16BE, utf16ToUTF8(head[2:], encUTF16BE)
+	case len(head) >= 2 && head[0] == 0 && head[1] != 0:
+		return encUTF16BE, utf16ToUTF8(head, encUTF16BE)
+	case len(head) >= 2 && head[0] != 0 && head[1] == 0:
+		return encUTF16LE, utf16ToUTF8(head, encUTF16LE)
+	}
+	return encUTF8, head
+}
+
+func utf16ToUTF8(buf []byte, enc encoding) []byte {
+	units := make([]uint16, len(buf)/2)
+	for i := range units {
+		if enc == encUTF16LE {
+			units[i] = uint16(buf[2*i]) | uint16(buf[2*i+1])<<8
+		} else {
+			units[i] = uint16(buf[2*i])<<8 | uint16(buf[2*i+1])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// sniffText determines the kind of the text plist that starts with
+// text.
+func sniffText(text []byte, eof bool) (Kind, error) {
+	i, err := skipSpace(text, 0, eof)
+	if err != nil {
+		return Unknown, err
+	}
+	if i == len(text) {
+		if !eof {
+			return Unknown, errShort
+		}
+		return Unknown, errors.New("plist: empty input")
+	}
+
+	switch c := text[i]; {
+	case c == '<':
+		return XML, nil
+	case c == '(' || c == '"':
+		return ASCII, nil
+	case c == '[':
+		return JSON, nil
+	case c == '{':
+		return sniffDict(text, i+1, eof)
+	case asciilex.IsUnquoted(c):
+		// The first key of a strings file.
+		return ASCII, nil
+	}
+	return Unknown, errors.New("plist: unknown kind")
+}
+
+// sniffDict tells a JSON object from an ASCII dict, given the text
+// after the opening brace: the first key of a JSON object is a quoted
+// string followed by a colon. Empty dicts are reported as ASCII.
+func sniffDict(text []byte, i int, eof bool) (Kind, error) {
+	i, err := skipSpace(text, i, eof)
+	if err != nil {
+		return Unknown, err
+	}
+	if i == len(text) {
+		if !eof {
+			return Unknown, errShort
+		}
+		return Unknown, errors.New("plist: unexpected end of input")
+	}
+	if text[i] != '"' {
+		return ASCII, nil
+	}
+
+	for i++; ; i++ {
+		if i >= len(text) {
+			if !eof {
+				return Unknown, errShort
+			}
+			return Unknown, errors.New("plist: unexpected end of input")
+		}
+		if text[i] == '\\' {
+			i++
+		} else if text[i] == '"' {
+			break
+		}
+	}
+
+	i, err = skipSpace(text, i+1, eof)
+	if err != nil {
+		return Unknown, err
+	}
+	if i == len(text) {
+		if !eof {
+			return Unknown, errShort
+		}
+		return Unknown, errors.New("plist: unexpected end of input")
+	}
+	if text[i] == ':' {
+		return JSON, nil
+	}
+	return ASCII, nil
+}
+
+// skipSpace returns the offset of the first byte at or after i that
+// is not whitespace or part of a comment. It returns errShort when a
+// comment is not closed within text.
+func skipSpace(text []byte, i int, eof bool) (int, error) {
+	for i < len(text) {
+		switch {
+		case text[i] == ' ' || text[i] == '\t' || text[i] == '\r' || text[i] == '\n':
+			i++
+		case bytes.HasPrefix(text[i:], []byte("/*")):
+			end := bytes.Index(text[i+2:], []byte("*/"))
+			if end < 0 {
+				if !eof {
+					return 0, errShort
+				}
+				return 0, errors.New("plist: unterminated comment")
+			}
+			i += 2 + end + 2
+		case bytes.HasPrefix(text[i:], []byte("//")):
+			end := bytes.IndexByte(text[i:], '\n')
+			if end < 0 {
+				if !eof {
+					return 0, errShort
+				}
+				return len(text), nil
+			}
+			i += end + 1
+		case text[i] == '/' && i+1 == len(text) && !eof:
+			return 0, errShort
+		default:
+			return i, nil
+		}
+	}
+	return i, nil
+}
+
+// textReader returns a reader for the text plist read by br, which is
+// in the encoding enc, converted to UTF-8 without a byte order mark.
+func textReader(br *bufio.Reader, enc encoding) (io.Reader, error) {
+	switch enc {
+	case encUTF8BOM:
+		_, err := br.Discard(3)
+		return br, err
+	case encUTF16LE, encUTF16BE:
+		buf, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(buf, []byte{0xff, 0xfe}) || bytes.HasPrefix(buf, []byte{0xfe, 0xff}) {
+			buf = buf[2:]
+		}
+		if len(buf)%2 != 0 {
+			return nil, errors.New("plist: odd number of bytes in UTF-16 input")
+		}
+		return bytes.NewReader(utf16ToUTF8(buf, enc)), nil
+	}
+	return br, nil
+}
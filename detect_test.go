@@ -0,0 +1,138 @@
+package plist
+
+import (
+	"bufio"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16LE returns s encoded as UTF-16LE with a byte order mark.
+func utf16LE(s string) []byte {
+	buf := []byte{0xff, 0xfe}
+	for _, u := range utf16.Encode([]rune(s)) {
+		buf = append(buf, byte(u), byte(u>>8))
+	}
+	return buf
+}
+
+func TestDetectKind(t *testing.T) {
+	tests := []struct {
+		in   string
+		kind Kind
+	}{
+		{"<?xml version=\"1.0\"?><plist/>", XML},
+		{"\xef\xbb\xbf<?xml version=\"1.0\"?>", XML},
+		{"\n\n  <!-- comment -->\n<plist version=\"1.0\">", XML},
+		{"<plist>", XML},
+		{"bplist00", Binary},
+		{"()", ASCII},
+		{"{}", ASCII},
+		{"{ a = b; }", ASCII},
+		{"{ \"a\" = \"b\"; }", ASCII},
+		{"\"key\" = \"value\";", ASCII},
+		{"/* Localizable.strings */\n\"key\" = \"value\";", ASCII},
+		{"// comment\nkey = value;", ASCII},
+		{"{\"a\": 1}", JSON},
+		{"{ \"a\\\"b\" :1}", JSON},
+		{" [1, 2]", JSON},
+		{string(utf16LE("\"key\" = \"value\";")), ASCII},
+		{string(utf16LE("<?xml version=\"1.0\" encoding=\"UTF-16\"?>")), XML},
+	}
+	for _, test := range tests {
+		kind, err := DetectKind(strings.NewReader(test.in))
+		if err != nil {
+			t.Fatalf("%q: %v", test.in, err)
+		}
+		if kind != test.kind {
+			t.Fatalf("%q: got kind %v, expected %v", test.in, kind, test.kind)
+		}
+	}
+
+	for _, version := range []string{"15", "16", "17"} {
+		kind, err := DetectKind(strings.NewReader("bplist" + version + "\x00\x00"))
+		if kind != Binary || err == nil || !strings.Contains(err.Error(), version) {
+			t.Fatalf("bplist%s: got %v, %v", version, kind, err)
+		}
+	}
+
+	for _, in := range []string{"", "   ", "#!/bin/sh", "{\"unterminated", "/* open"} {
+		_, err := DetectKind(strings.NewReader(in))
+		if err == nil {
+			t.Fatalf("%q: expected error", in)
+		}
+	}
+}
+
+func TestDetectKindPeeks(t *testing.T) {
+	br := bufio.NewReader(strings.NewReader("(a, b)"))
+	kind, err := DetectKind(br)
+	if err != nil || kind != ASCII {
+		t.Fatalf("got %v, %v", kind, err)
+	}
+	rest, err := ioutil.ReadAll(br)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(rest) != "(a, b)" {
+		t.Fatalf("DetectKind consumed input: %q left", rest)
+	}
+}
+
+func TestUnmarshalDetected(t *testing.T) {
+	xml, err := ioutil.ReadFile("xmlplist/testdata/Entitlements.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	xml16 := strings.Replace(string(xml), "UTF-8", "UTF-16", 1)
+	if xml16 == string(xml) {
+		t.Fatalf("fixture does not declare its encoding")
+	}
+
+	tests := []struct {
+		name string
+		in   []byte
+		key  string
+		val  interface{}
+	}{
+		{"xml with bom", append([]byte("\xef\xbb\xbf"), xml...), "get-task-allow", true},
+		{"utf-16 xml", utf16LE(xml16), "get-task-allow", true},
+		{"json", []byte(`{"name": "plist"}`), "name", "plist"},
+		{"strings", utf16LE("/* Greeting */\n\"hello\" = \"Grüß Gott\";\n"), "hello", "Grüß Gott"},
+		{"unquoted key", []byte("_key = \"v\";"), "_key", "v"},
+		{"unquoted path", []byte("$SRCROOT/tool = /usr/bin/env;"), "$SRCROOT/tool", "/usr/bin/env"},
+		{"xml after whitespace", append([]byte("\n\n  "), xml...), "get-task-allow", true},
+		{"xml after comment", append([]byte("<!-- generated -->\n"), xml...), "get-task-allow", true},
+		{"xml without prolog", []byte("<plist version=\"1.0\"><dict><key>a</key><true/></dict></plist>"), "a", true},
+		{"xml without version", []byte("<plist><dict><key>a</key><true/></dict></plist>"), "a", true},
+		{"comment before plist", []byte("<?xml version=\"1.0\"?>\n<!-- c -->\n<plist><dict><key>a</key><true/></dict></plist>"), "a", true},
+	}
+	for _, test := range tests {
+		var m map[string]interface{}
+		err := Unmarshal(test.in, &m)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if m[test.key] != test.val {
+			t.Fatalf("%s: got %v", test.name, m)
+		}
+	}
+}
+
+func TestUnmarshalScalarRoot(t *testing.T) {
+	var n int
+	err := Unmarshal([]byte("42"), &n)
+	if err != nil || n != 42 {
+		t.Fatalf("got %v, %v", n, err)
+	}
+	var v interface{}
+	err = Unmarshal([]byte(`"x"`), &v)
+	if err != nil || v != "x" {
+		t.Fatalf("got %v, %v", v, err)
+	}
+	err = Unmarshal([]byte("{ a = b"), &v)
+	if err == nil || err.Error() != "plist: unexpected end of input" {
+		t.Fatalf("got %v, expected unexpected end of input", err)
+	}
+}
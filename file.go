@@ -0,0 +1,65 @@
+package plist
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile encodes v as a plist of the given kind and writes it to
+// the named file with ReplaceFile, so the original is left untouched
+// when encoding or writing fails.
+func WriteFile(path string, v interface{}, kind Kind) error {
+	buf := new(bytes.Buffer)
+	enc := NewSpecificEncoder(buf, kind)
+	if enc == nil {
+		return errors.New("plist: unknown kind")
+	}
+	err := enc.Encode(v)
+	if err != nil {
+		return err
+	}
+	return ReplaceFile(path, buf.Bytes())
+}
+
+// ReplaceFile writes data to the named file. The data is written to a
+// temporary file in the same directory, which is then renamed over the
+// original, so readers never see a partially written file and the
+// original is left untouched when writing fails. An existing file keeps
+// its permissions; a new file is created with mode 0644. If path is a
+// symbolic link, the file it points to is replaced.
+func ReplaceFile(path string, data []byte) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
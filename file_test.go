@@ -0,0 +1,84 @@
+package plist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "Info.plist")
+	err = WriteFile(path, map[string]interface{}{"Version": "1"}, XML)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if fi.Mode().Perm() != 0644 {
+		t.Fatalf("new file has mode %v", fi.Mode().Perm())
+	}
+
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = WriteFile(path, map[string]interface{}{"Version": "2"}, Binary)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	fi, err = os.Stat(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("permissions not kept: %v", fi.Mode().Perm())
+	}
+
+	var v map[string]interface{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = Unmarshal(data, &v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if v["Version"] != "2" {
+		t.Fatalf("got %v", v)
+	}
+
+	// A value that cannot be encoded leaves the file as it was.
+	err = WriteFile(path, map[string]interface{}{"Bad": make(chan int)}, XML)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	after, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(after) != string(data) {
+		t.Fatalf("file changed by failed write")
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %d entries", len(entries))
+	}
+
+	err = WriteFile(path, v, Unknown)
+	if err == nil {
+		t.Fatalf("expected error for unknown kind")
+	}
+}
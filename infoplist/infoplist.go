@@ -0,0 +1,249 @@
+// Package infoplist models the Info.plist files of application and
+// framework bundles.
+//
+// Info holds the documented keys as typed fields; the plist tag of
+// each field names its key. Keys may have platform- and
+// device-specific variants, such as UISupportedInterfaceOrientations~ipad
+// or LSMinimumSystemVersion-macos; Variant resolves them the way the
+// system does.
+package infoplist
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/binaryplist"
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+// Info is the contents of an Info.plist file.
+type Info struct {
+	BundleDevelopmentRegion     string         `plist:"CFBundleDevelopmentRegion,omitempty"`
+	BundleDisplayName           string         `plist:"CFBundleDisplayName,omitempty"`
+	BundleDocumentTypes         []DocumentType `plist:"CFBundleDocumentTypes,omitempty"`
+	BundleExecutable            string         `plist:"CFBundleExecutable,omitempty"`
+	BundleIconFile              string         `plist:"CFBundleIconFile,omitempty"`
+	BundleIdentifier            string         `plist:"CFBundleIdentifier,omitempty"`
+	BundleInfoDictionaryVersion string         `plist:"CFBundleInfoDictionaryVersion,omitempty"`
+	BundleName                  string         `plist:"CFBundleName,omitempty"`
+	BundlePackageType           string         `plist:"CFBundlePackageType,omitempty"`
+	BundleShortVersionString    string         `plist:"CFBundleShortVersionString,omitempty"`
+	BundleSupportedPlatforms    []string       `plist:"CFBundleSupportedPlatforms,omitempty"`
+	BundleURLTypes              []URLType      `plist:"CFBundleURLTypes,omitempty"`
+	BundleVersion               string         `plist:"CFBundleVersion,omitempty"`
+
+	ApplicationCategoryType          string   `plist:"LSApplicationCategoryType,omitempty"`
+	ApplicationQueriesSchemes        []string `plist:"LSApplicationQueriesSchemes,omitempty"`
+	MinimumSystemVersion             string   `plist:"LSMinimumSystemVersion,omitempty"`
+	RequiresIPhoneOS                 bool     `plist:"LSRequiresIPhoneOS,omitempty"`
+	SupportsOpeningDocumentsInPlace  bool     `plist:"LSSupportsOpeningDocumentsInPlace,omitempty"`
+	UIElement                        bool     `plist:"LSUIElement,omitempty"`
+	MinimumOSVersion                 string   `plist:"MinimumOSVersion,omitempty"`
+	HumanReadableCopyright           string   `plist:"NSHumanReadableCopyright,omitempty"`
+	MainNibFile                      string   `plist:"NSMainNibFile,omitempty"`
+	PrincipalClass                   string   `plist:"NSPrincipalClass,omitempty"`
+	ApplicationSupportsIndirectInput bool     `plist:"UIApplicationSupportsIndirectInputEvents,omitempty"`
+	BackgroundModes                  []string `plist:"UIBackgroundModes,omitempty"`
+	DeviceFamily                     []int64  `plist:"UIDeviceFamily,omitempty"`
+	FileSharingEnabled               bool     `plist:"UIFileSharingEnabled,omitempty"`
+	LaunchStoryboardName             string   `plist:"UILaunchStoryboardName,omitempty"`
+	MainStoryboardFile               string   `plist:"UIMainStoryboardFile,omitempty"`
+	RequiredDeviceCapabilities       []string `plist:"UIRequiredDeviceCapabilities,omitempty"`
+	RequiresFullScreen               bool     `plist:"UIRequiresFullScreen,omitempty"`
+	StatusBarStyle                   string   `plist:"UIStatusBarStyle,omitempty"`
+	SupportedInterfaceOrientations   []string `plist:"UISupportedInterfaceOrientations,omitempty"`
+
+	ExportedTypeDeclarations []TypeDeclaration `plist:"UTExportedTypeDeclarations,omitempty"`
+	ImportedTypeDeclarations []TypeDeclaration `plist:"UTImportedTypeDeclarations,omitempty"`
+
+	// The NS...UsageDescription keys, such as NSCameraUsageDescription,
+	// which explain why the app requests access to protected resources.
+	UsageDescriptions map[string]string `plist:"-"`
+
+	// All keys of the file, including platform variants and keys not
+	// modelled above.
+	Raw map[string]interface{} `plist:"-"`
+}
+
+// A URLType declares a URL scheme the app handles.
+type URLType struct {
+	IconFile string   `plist:"CFBundleURLIconFile,omitempty"`
+	Name     string   `plist:"CFBundleURLName,omitempty"`
+	Role     string   `plist:"CFBundleTypeRole,omitempty"`
+	Schemes  []string `plist:"CFBundleURLSchemes,omitempty"`
+}
+
+// A DocumentType declares a kind of document the app opens.
+type DocumentType struct {
+	ContentTypes []string `plist:"LSItemContentTypes,omitempty"`
+	Extensions   []string `plist:"CFBundleTypeExtensions,omitempty"`
+	HandlerRank  string   `plist:"LSHandlerRank,omitempty"`
+	IconFiles    []string `plist:"CFBundleTypeIconFiles,omitempty"`
+	Name         string   `plist:"CFBundleTypeName,omitempty"`
+	Role         string   `plist:"CFBundleTypeRole,omitempty"`
+}
+
+// A TypeDeclaration declares a uniform type identifier.
+type TypeDeclaration struct {
+	ConformsTo  []string `plist:"UTTypeConformsTo,omitempty"`
+	Description string   `plist:"UTTypeDescription,omitempty"`
+	IconFile    string   `plist:"UTTypeIconFile,omitempty"`
+	Identifier  string   `plist:"UTTypeIdentifier,omitempty"`
+
+	// The filename extensions, MIME types and so on of the type,
+	// each a string or an array of strings.
+	TagSpecification map[string]interface{} `plist:"UTTypeTagSpecification,omitempty"`
+}
+
+// usageDescription matches the keys of usage descriptions.
+var usageDescription = regexp.MustCompile(`^NS[A-Za-z]+UsageDescription$`)
+
+// Unmarshal parses an Info.plist file of any kind. Values of the
+// wrong type are left out of the typed fields; Validate reports them.
+func Unmarshal(data []byte) (*Info, error) {
+	var dict map[string]interface{}
+	err := plist.Unmarshal(data, &dict)
+	if err != nil {
+		return nil, err
+	}
+	return FromDict(dict), nil
+}
+
+// FromDict returns the Info for the keys of dict. A value that does
+// not fit its field is only kept in Raw.
+func FromDict(dict map[string]interface{}) *Info {
+	info := &Info{
+		UsageDescriptions: make(map[string]string),
+		Raw:               dict,
+	}
+	rv := reflect.ValueOf(info).Elem()
+	for k, v := range dict {
+		if s, ok := v.(string); ok && usageDescription.MatchString(k) {
+			info.UsageDescriptions[k] = s
+		}
+		if index, fv, ok := fieldValue(k, v); ok {
+			rv.FieldByIndex(index).Set(fv)
+		}
+	}
+	return info
+}
+
+// fieldValue decodes v for the field of Info that holds key, and
+// returns the field's index and the value. It reports false if no
+// field holds key or v does not fit the field.
+func fieldValue(key string, v interface{}) ([]int, reflect.Value, bool) {
+	index := plistreflect.FieldIndex(infoType, key)
+	if index == nil {
+		return nil, reflect.Value{}, false
+	}
+	fv := reflect.New(infoType.FieldByIndex(index).Type)
+	if plistreflect.Set(fv, v) != nil {
+		return nil, reflect.Value{}, false
+	}
+	return index, fv.Elem(), true
+}
+
+// Dict returns the keys of info as a dict, ready for encoding. The
+// typed fields and usage descriptions replace the keys of Raw; keys
+// whose fields are empty are removed, unless their values did not fit
+// the fields, and their variants are kept.
+func (info *Info) Dict() map[string]interface{} {
+	dict := make(map[string]interface{}, len(info.Raw))
+	for k, v := range info.Raw {
+		if _, _, ok := fieldValue(k, v); ok || usageDescription.MatchString(k) {
+			continue
+		}
+		dict[k] = v
+	}
+
+	// The typed fields are encoded and decoded again, to hold the
+	// same kinds of values as Raw.
+	var fields map[string]interface{}
+	buf, err := binaryplist.Marshal(info)
+	if err == nil {
+		err = binaryplist.Unmarshal(buf, &fields)
+	}
+	if err != nil {
+		panic("plist: unable to encode Info: " + err.Error())
+	}
+	for k, v := range fields {
+		dict[k] = v
+	}
+	for k, v := range info.UsageDescriptions {
+		dict[k] = v
+	}
+	return dict
+}
+
+// Platforms and devices of key variants.
+var (
+	platforms = []string{"appletvos", "appletvsimulator", "iphoneos", "iphonesimulator", "macos", "macosx", "watchos", "watchsimulator", "xros", "xrsimulator"}
+	devices   = []string{"ipad", "iphone", "ipod"}
+)
+
+// splitKey splits a key into its base key and the platform and device
+// of its variant, if any. The platform suffix is only recognised if
+// the base key is known, as other keys may contain hyphens.
+func splitKey(key string) (base, platform, device string) {
+	base = key
+	if i := strings.LastIndexByte(base, '~'); i >= 0 {
+		base, device = base[:i], base[i+1:]
+	}
+	if i := strings.LastIndexByte(base, '-'); i >= 0 && isKnownKey(base[:i]) {
+		base, platform = base[:i], base[i+1:]
+	}
+	return base, platform, device
+}
+
+// Variant returns the Info seen on the given platform and device,
+// such as "iphoneos" and "ipad". For each key, the most specific of
+// key-platform~device, key~device, key-platform and key wins. Either
+// argument may be empty.
+func (info *Info) Variant(platform, device string) *Info {
+	type choice struct {
+		rank int
+		v    interface{}
+	}
+	chosen := make(map[string]choice)
+	for k, v := range info.Raw {
+		base, p, d := splitKey(k)
+		if p != "" && p != platform || d != "" && d != device {
+			continue
+		}
+		rank := 0
+		if d != "" {
+			rank += 2
+		}
+		if p != "" {
+			rank++
+		}
+		if c, ok := chosen[base]; !ok || rank > c.rank {
+			chosen[base] = choice{rank, v}
+		}
+	}
+	dict := make(map[string]interface{}, len(chosen))
+	for k, c := range chosen {
+		dict[k] = c.v
+	}
+	return FromDict(dict)
+}
+
+var infoType = reflect.TypeOf(Info{})
+
+// fieldType returns the type of the field of the struct type rt that
+// holds key.
+func fieldType(rt reflect.Type, key string) (reflect.Type, bool) {
+	index := plistreflect.FieldIndex(rt, key)
+	if index == nil {
+		return nil, false
+	}
+	return rt.FieldByIndex(index).Type, true
+}
+
+// isKnownKey reports whether key is one of the modelled keys.
+func isKnownKey(key string) bool {
+	_, ok := fieldType(infoType, key)
+	return ok || usageDescription.MatchString(key)
+}
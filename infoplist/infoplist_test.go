@@ -0,0 +1,145 @@
+package infoplist
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/mkrautz/plist"
+)
+
+func TestDecode(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Info.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	info, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if info.BundleIdentifier != "com.example.Hello" || !info.RequiresIPhoneOS {
+		t.Fatalf("got %#v", info)
+	}
+	urlTypes := []URLType{{Name: "com.example.Hello", Role: "Viewer", Schemes: []string{"hello"}}}
+	if !reflect.DeepEqual(info.BundleURLTypes, urlTypes) {
+		t.Fatalf("got %#v, expected %#v", info.BundleURLTypes, urlTypes)
+	}
+	if !reflect.DeepEqual(info.DeviceFamily, []int64{1, 2}) {
+		t.Fatalf("got %#v", info.DeviceFamily)
+	}
+	if info.ExportedTypeDeclarations[0].ConformsTo[0] != "public.data" {
+		t.Fatalf("got %#v", info.ExportedTypeDeclarations)
+	}
+	if info.UsageDescriptions["NSCameraUsageDescription"] == "" {
+		t.Fatalf("usage description missing")
+	}
+	if errs := info.Validate(); len(errs) != 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+}
+
+func TestVariant(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Info.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	info, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	ipad := info.Variant("iphoneos", "ipad")
+	if len(ipad.SupportedInterfaceOrientations) != 3 {
+		t.Fatalf("got %q", ipad.SupportedInterfaceOrientations)
+	}
+	if ipad.StatusBarStyle != "UIStatusBarStyleLightContent" {
+		t.Fatalf("got %q", ipad.StatusBarStyle)
+	}
+	iphone := info.Variant("iphoneos", "iphone")
+	if len(iphone.SupportedInterfaceOrientations) != 1 || iphone.StatusBarStyle != "UIStatusBarStyleDefault" {
+		t.Fatalf("got %q, %q", iphone.SupportedInterfaceOrientations, iphone.StatusBarStyle)
+	}
+	if ipad.BundleIdentifier != info.BundleIdentifier {
+		t.Fatalf("plain keys not kept")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	info := FromDict(map[string]interface{}{
+		"CFBundleIdentifier":         "com.example/Hello",
+		"CFBundleExecutable":         "$(EXECUTABLE_NAME)",
+		"CFBundleShortVersionString": "1.2.3.4",
+		"CFBundleVersion":            int64(42),
+		"CFBundleURLTypes": []interface{}{
+			map[string]interface{}{"CFBundleURLSchemes": "hello"},
+		},
+		"NSMicrophoneUsageDescription": " ",
+		"UIRequiresFullScreen~iwatch":  true,
+		"LSMinimumSystemVersion-macos": "$(MACOSX_DEPLOYMENT_TARGET)",
+	})
+	var got []string
+	for _, err := range info.Validate() {
+		got = append(got, err.Error())
+	}
+	expected := []string{
+		"plist: Info.plist key CFBundlePackageType: missing",
+		`plist: Info.plist key CFBundleIdentifier: malformed bundle identifier "com.example/Hello"`,
+		`plist: Info.plist key CFBundleShortVersionString: malformed version "1.2.3.4"`,
+		"plist: Info.plist key CFBundleURLTypes[0].CFBundleURLSchemes: expected array, got string",
+		"plist: Info.plist key CFBundleVersion: expected string, got integer",
+		"plist: Info.plist key NSMicrophoneUsageDescription: empty usage description",
+		`plist: Info.plist key UIRequiresFullScreen~iwatch: unknown device "iwatch"`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestNonConformingKeys(t *testing.T) {
+	dict := map[string]interface{}{
+		"CFBundleVersion": int64(42),
+		"UIDeviceFamily":  []interface{}{int64(1), "2"},
+		"CFBundleName":    "Hello",
+	}
+	info := FromDict(dict)
+	if info.BundleVersion != "" || info.DeviceFamily != nil || info.BundleName != "Hello" {
+		t.Fatalf("got %#v", info)
+	}
+	if !reflect.DeepEqual(info.Dict(), dict) {
+		t.Fatalf("got %#v, expected %#v", info.Dict(), dict)
+	}
+	info.BundleVersion = "43"
+	if info.Dict()["CFBundleVersion"] != "43" {
+		t.Fatalf("got %#v", info.Dict())
+	}
+}
+
+func TestDict(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Info.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	info, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	info.BundleVersion = "43"
+	info.RequiresFullScreen = true
+	info.UsageDescriptions["NSPhotoLibraryUsageDescription"] = "To pick a picture."
+	buf, err = plist.Marshal(info.Dict())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	again, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(again.Raw, info.Dict()) {
+		t.Fatalf("got %#v, expected %#v", again.Raw, info.Dict())
+	}
+	if again.BundleVersion != "43" || !again.RequiresFullScreen || len(again.UsageDescriptions) != 2 {
+		t.Fatalf("edits lost: %#v", again)
+	}
+	if _, ok := again.Raw["UISupportedInterfaceOrientations~ipad"]; !ok {
+		t.Fatalf("variant lost")
+	}
+}
@@ -0,0 +1,145 @@
+package infoplist
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/mkrautz/plist/internal/dictkeys"
+	"github.com/mkrautz/plist/internal/typename"
+)
+
+// A ValidationError describes a problem with a key of an Info.plist.
+type ValidationError struct {
+	Key string // the key, with the path to it for nested keys
+	Msg string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("plist: Info.plist key %s: %s", e.Key, e.Msg)
+}
+
+// RequiredKeys are the keys Validate requires.
+var RequiredKeys = []string{
+	"CFBundleExecutable",
+	"CFBundleIdentifier",
+	"CFBundlePackageType",
+	"CFBundleShortVersionString",
+	"CFBundleVersion",
+}
+
+var (
+	// Versions are up to three period-separated integers.
+	versionFormat    = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,2}$`)
+	identifierFormat = regexp.MustCompile(`^[A-Za-z0-9.-]+$`)
+)
+
+// The keys whose values are versions.
+var versionKeys = map[string]bool{
+	"CFBundleShortVersionString": true,
+	"CFBundleVersion":            true,
+	"LSMinimumSystemVersion":     true,
+	"MinimumOSVersion":           true,
+}
+
+// Validate checks the keys of info.Raw: required keys must be
+// present, modelled keys and their variants must have the documented
+// types, versions and the bundle identifier must be well-formed, usage
+// descriptions must not be empty, and variants must name a known
+// platform and device. Values that refer to build settings, such as
+// $(PRODUCT_BUNDLE_IDENTIFIER), are not checked for format. The
+// problems found are returned in order of key.
+func (info *Info) Validate() []ValidationError {
+	var errs []ValidationError
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{key, fmt.Sprintf(format, args...)})
+	}
+
+	for _, key := range RequiredKeys {
+		if _, ok := info.Raw[key]; !ok {
+			fail(key, "missing")
+		}
+	}
+
+	for _, key := range dictkeys.Sorted(info.Raw) {
+		v := info.Raw[key]
+		base, platform, device := splitKey(key)
+		if platform != "" && !contains(platforms, platform) {
+			fail(key, "unknown platform %q", platform)
+		}
+		if device != "" && !contains(devices, device) {
+			fail(key, "unknown device %q", device)
+		}
+
+		if usageDescription.MatchString(base) {
+			if s, ok := v.(string); !ok {
+				fail(key, "expected string, got %s", typename.Of(v))
+			} else if strings.TrimSpace(s) == "" {
+				fail(key, "empty usage description")
+			}
+			continue
+		}
+		rt, ok := fieldType(infoType, base)
+		if !ok {
+			continue
+		}
+		errs = append(errs, checkType(key, v, rt)...)
+
+		s, ok := v.(string)
+		if !ok || strings.Contains(s, "$(") {
+			continue
+		}
+		if versionKeys[base] && !versionFormat.MatchString(s) {
+			fail(key, "malformed version %q", s)
+		}
+		if base == "CFBundleIdentifier" && !identifierFormat.MatchString(s) {
+			fail(key, "malformed bundle identifier %q", s)
+		}
+	}
+	return errs
+}
+
+// checkType checks that v can be stored in a field of type rt.
+func checkType(key string, v interface{}, rt reflect.Type) []ValidationError {
+	mismatch := []ValidationError{{key, fmt.Sprintf("expected %s, got %s", typename.ForType(rt), typename.Of(v))}}
+	switch rt.Kind() {
+	case reflect.Slice:
+		array, ok := v.([]interface{})
+		if !ok {
+			return mismatch
+		}
+		var errs []ValidationError
+		for i, elem := range array {
+			errs = append(errs, checkType(fmt.Sprintf("%s[%d]", key, i), elem, rt.Elem())...)
+		}
+		return errs
+	case reflect.Struct:
+		dict, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch
+		}
+		var errs []ValidationError
+		for _, k := range dictkeys.Sorted(dict) {
+			if ft, ok := fieldType(rt, k); ok {
+				errs = append(errs, checkType(key+"."+k, dict[k], ft)...)
+			}
+		}
+		return errs
+	case reflect.Interface:
+		return nil
+	}
+	if reflect.TypeOf(v) != rt {
+		return mismatch
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+// Package asciilex splits old-style ASCII property lists, including
+// strings files, into tokens. It is shared by the ASCII plist and
+// strings file decoders.
+package asciilex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// A Kind is the kind of a token.
+type Kind int
+
+const (
+	EOF     Kind = iota // the end of the text
+	String              // a quoted or unquoted string
+	Data                // hexadecimal data between angle brackets
+	Comment             // a /* */ or // comment
+	Punct               // one of { } ( ) = ; ,
+)
+
+// A Token is a single token of an ASCII plist.
+type Token struct {
+	Kind Kind
+
+	// The value of a string, the body of a comment without its
+	// delimiters, or the punctuation character.
+	Text string
+
+	// The bytes of a data token.
+	Data []byte
+
+	// Whether a string was quoted.
+	Quoted bool
+
+	// Whether a comment is a // comment rather than a /* */ one.
+	LineComment bool
+
+	// The line the token starts on, counting from 1.
+	Line int
+}
+
+// An Error is a syntax error found by the lexer.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// IsUnquoted reports whether c may appear in an unquoted string. These
+// are the characters CoreFoundation accepts.
+func IsUnquoted(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '_' || c == '$' || c == '.' || c == '/' || c == ':' || c == '-'
+}
+
+// A Lexer reads the tokens of an ASCII plist.
+type Lexer struct {
+	text     string
+	pos      int
+	line     int
+	nonASCII bool
+}
+
+// New returns a Lexer that reads the tokens of text.
+func New(text string) *Lexer {
+	return &Lexer{text: text, line: 1}
+}
+
+// AllowNonASCII causes the Lexer to accept the bytes of multi-byte
+// UTF-8 sequences in unquoted strings, as Xcode writes non-ASCII names
+// in project files unquoted.
+func (l *Lexer) AllowNonASCII() {
+	l.nonASCII = true
+}
+
+// unquoted reports whether c may appear in an unquoted string.
+func (l *Lexer) unquoted(c byte) bool {
+	return IsUnquoted(c) || l.nonASCII && c >= utf8.RuneSelf
+}
+
+func (l *Lexer) errorf(format string, args ...interface{}) error {
+	return &Error{l.line, fmt.Sprintf(format, args...)}
+}
+
+// Next returns the next token. At the end of the text it returns
+// a token of kind EOF.
+func (l *Lexer) Next() (Token, error) {
+	for l.pos < len(l.text) {
+		c := l.text[l.pos]
+		switch c {
+		case '\n':
+			l.line++
+			l.pos++
+		case ' ', '\t', '\r':
+			l.pos++
+		case '{', '}', '(', ')', '=', ';', ',':
+			l.pos++
+			return Token{Kind: Punct, Text: string(c), Line: l.line}, nil
+		case '"':
+			return l.quoted()
+		case '<':
+			return l.data()
+		default:
+			if strings.HasPrefix(l.text[l.pos:], "/*") || strings.HasPrefix(l.text[l.pos:], "//") {
+				return l.comment()
+			}
+			if l.unquoted(c) {
+				start := l.pos
+				for l.pos < len(l.text) && l.unquoted(l.text[l.pos]) {
+					l.pos++
+				}
+				return Token{Kind: String, Text: l.text[start:l.pos], Line: l.line}, nil
+			}
+			return Token{}, l.errorf("unexpected character %q", c)
+		}
+	}
+	return Token{Kind: EOF, Line: l.line}, nil
+}
+
+// comment reads a /* */ or // comment.
+func (l *Lexer) comment() (Token, error) {
+	tok := Token{Kind: Comment, Line: l.line}
+	if strings.HasPrefix(l.text[l.pos:], "//") {
+		end := strings.IndexByte(l.text[l.pos:], '\n')
+		if end < 0 {
+			end = len(l.text) - l.pos
+		}
+		tok.Text = l.text[l.pos+2 : l.pos+end]
+		tok.LineComment = true
+		l.pos += end
+		return tok, nil
+	}
+	end := strings.Index(l.text[l.pos+2:], "*/")
+	if end < 0 {
+		return Token{}, l.errorf("unterminated comment")
+	}
+	tok.Text = l.text[l.pos+2 : l.pos+2+end]
+	l.line += strings.Count(tok.Text, "\n")
+	l.pos += end + 4
+	return tok, nil
+}
+
+// data reads hexadecimal data between angle brackets. The digits may
+// be separated by whitespace.
+func (l *Lexer) data() (Token, error) {
+	tok := Token{Kind: Data, Line: l.line, Data: []byte{}}
+	var digits []byte
+	for l.pos++; ; l.pos++ {
+		if l.pos >= len(l.text) {
+			return Token{}, l.errorf("unterminated data")
+		}
+		c := l.text[l.pos]
+		switch {
+		case c == '>':
+			l.pos++
+			if len(digits)%2 != 0 {
+				return Token{}, l.errorf("odd number of digits in data")
+			}
+			for i := 0; i < len(digits); i += 2 {
+				tok.Data = append(tok.Data, hexVal(digits[i])<<4|hexVal(digits[i+1]))
+			}
+			return tok, nil
+		case c == '\n':
+			l.line++
+		case c == ' ' || c == '\t' || c == '\r':
+		case isHexDigit(c):
+			digits = append(digits, c)
+		default:
+			return Token{}, l.errorf("unexpected character %q in data", c)
+		}
+	}
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func hexVal(c byte) byte {
+	switch {
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// quoted reads a quoted string, replacing its escape sequences.
+func (l *Lexer) quoted() (Token, error) {
+	tok := Token{Kind: String, Quoted: true, Line: l.line}
+	l.pos++
+	var sb strings.Builder
+	for {
+		if l.pos >= len(l.text) {
+			return Token{}, l.errorf("unterminated string")
+		}
+		c := l.text[l.pos]
+		l.pos++
+		switch c {
+		case '"':
+			tok.Text = sb.String()
+			return tok, nil
+		case '\n':
+			l.line++
+			sb.WriteByte(c)
+		case '\\':
+			if l.pos >= len(l.text) {
+				return Token{}, l.errorf("unterminated string")
+			}
+			esc := l.text[l.pos]
+			l.pos++
+			switch esc {
+			case 'a':
+				sb.WriteByte('\a')
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'v':
+				sb.WriteByte('\v')
+			case 'U', 'u':
+				r, err := l.unicodeEscape()
+				if err != nil {
+					return Token{}, err
+				}
+				sb.WriteRune(r)
+			default:
+				if '0' <= esc && esc <= '7' {
+					n := int(esc - '0')
+					for i := 0; i < 2 && l.pos < len(l.text) && '0' <= l.text[l.pos] && l.text[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.text[l.pos]-'0')
+						l.pos++
+					}
+					sb.WriteRune(rune(n))
+				} else {
+					// \" and \\, and any other escaped character,
+					// stand for themselves.
+					sb.WriteByte(esc)
+				}
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+}
+
+// hex4 reads a four-digit hexadecimal number.
+func (l *Lexer) hex4() (rune, bool) {
+	if l.pos+4 > len(l.text) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(l.text[l.pos:l.pos+4], 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	l.pos += 4
+	return rune(n), true
+}
+
+// unicodeEscape reads the digits of a \Uxxxx escape. Characters
+// outside the Basic Multilingual Plane are written as a pair of
+// escaped surrogates.
+func (l *Lexer) unicodeEscape() (rune, error) {
+	r, ok := l.hex4()
+	if !ok {
+		return 0, l.errorf("invalid unicode escape")
+	}
+	if !utf16.IsSurrogate(r) {
+		return r, nil
+	}
+	rest := l.text[l.pos:]
+	if !strings.HasPrefix(rest, "\\U") && !strings.HasPrefix(rest, "\\u") {
+		return utf8.RuneError, nil
+	}
+	start := l.pos
+	l.pos += 2
+	low, ok := l.hex4()
+	if !ok {
+		l.pos = start
+		return utf8.RuneError, nil
+	}
+	return utf16.DecodeRune(r, low), nil
+}
@@ -0,0 +1,23 @@
+// Package cfdate converts between times and the seconds relative to
+// the Core Foundation reference date, as binary plists and keyed
+// archives store dates. It is shared by binaryplist and keyedarchive.
+package cfdate
+
+import (
+	"math"
+	"time"
+)
+
+// referenceDate is the Core Foundation reference date.
+var referenceDate = time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Time returns the time secs seconds after the reference date.
+func Time(secs float64) time.Time {
+	whole, frac := math.Modf(secs)
+	return referenceDate.Add(time.Duration(whole) * time.Second).Add(time.Duration(frac * float64(time.Second)))
+}
+
+// Seconds returns the number of seconds from the reference date to t.
+func Seconds(t time.Time) float64 {
+	return float64(t.Sub(referenceDate)) / float64(time.Second)
+}
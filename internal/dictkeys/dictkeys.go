@@ -0,0 +1,16 @@
+// Package dictkeys lists the keys of decoded plist dicts.
+package dictkeys
+
+import (
+	"sort"
+)
+
+// Sorted returns the keys of m in sorted order.
+func Sorted(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,34 @@
+// Package keypath builds key paths, as read by plist.Get. It is shared
+// by the plist package and the decoders that report key paths.
+package keypath
+
+import (
+	"strconv"
+)
+
+// Escape escapes the dots, brackets and backslashes of key.
+func Escape(key string) string {
+	var buf []byte
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '.', '[', ']', '\\':
+			buf = append(buf, '\\')
+		}
+		buf = append(buf, key[i])
+	}
+	return string(buf)
+}
+
+// Child returns the key path of the entry key in the dict found at
+// path, which is "" for the root.
+func Child(path, key string) string {
+	if path == "" {
+		return Escape(key)
+	}
+	return path + "." + Escape(key)
+}
+
+// Index returns the key path of element i of the array found at path.
+func Index(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
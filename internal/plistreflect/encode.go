@@ -0,0 +1,97 @@
+package plistreflect
+
+import (
+	"reflect"
+	"sort"
+)
+
+// A Marshaler is a type that is encoded as another value, such as a
+// type with several forms in a plist.
+type Marshaler interface {
+	MarshalPlist() (interface{}, error)
+}
+
+// Marshal returns the value rv is encoded as: the result of its
+// MarshalPlist method if it is a Marshaler, and rv itself otherwise.
+func Marshal(rv reflect.Value) (reflect.Value, error) {
+	if !rv.IsValid() || !rv.CanInterface() {
+		return rv, nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return rv, nil
+		}
+	}
+	m, ok := rv.Interface().(Marshaler)
+	if !ok && rv.CanAddr() {
+		m, ok = rv.Addr().Interface().(Marshaler)
+	}
+	if !ok {
+		return rv, nil
+	}
+	v, err := m.MarshalPlist()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(v), nil
+}
+
+// StructEntries returns the keys and values of the dict the struct rv
+// is encoded as, in the order of its fields. The entries of a field
+// tagged unknown follow, in sorted key order, leaving out those whose
+// keys other fields use.
+func StructEntries(rv reflect.Value) ([]string, []reflect.Value) {
+	var keys []string
+	var vals []reflect.Value
+	var unknown reflect.Value
+	known := make(map[string]bool)
+	for _, f := range fields(rv.Type()) {
+		fv := rv.FieldByIndex(f.index)
+		if hasOption(f.opts, "unknown") {
+			unknown = fv
+			continue
+		}
+		known[f.name] = true
+		if hasOption(f.opts, "omitempty") && isEmpty(fv) {
+			continue
+		}
+		keys = append(keys, f.name)
+		vals = append(vals, fv)
+	}
+
+	if !unknown.IsValid() || unknown.Kind() != reflect.Map || unknown.Type().Key().Kind() != reflect.String {
+		return keys, vals
+	}
+	var rest []string
+	for _, kv := range unknown.MapKeys() {
+		if !known[kv.String()] {
+			rest = append(rest, kv.String())
+		}
+	}
+	sort.Strings(rest)
+	for _, k := range rest {
+		keys = append(keys, k)
+		vals = append(vals, unknown.MapIndex(reflect.ValueOf(k).Convert(unknown.Type().Key())))
+	}
+	return keys, vals
+}
+
+// isEmpty reports whether rv is false, 0, nil or empty.
+func isEmpty(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
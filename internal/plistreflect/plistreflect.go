@@ -0,0 +1,356 @@
+// Package plistreflect stores decoded plist values into Go values, and
+// lists the dict entries that structs are encoded as. It is shared by
+// the decoders and encoders of the plist kinds.
+//
+// The plist tag of a struct field gives its key, followed by options
+// separated by commas, as in `plist:"Label,omitempty"`. A field tagged
+// omitempty is left out when encoding if it is false, 0, nil or empty.
+// A map field tagged unknown holds the entries of a dict that no other
+// field claims. The fields of an untagged embedded struct are read and
+// written as fields of the struct embedding it.
+package plistreflect
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// FieldName returns the plist key used for the struct field f,
+// and whether the field should be skipped altogether.
+func FieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", true
+	}
+	name, _ := parseTag(f)
+	if name == "-" {
+		return "", true
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, false
+}
+
+// A field is a struct field that holds a dict entry.
+type field struct {
+	name  string
+	opts  string
+	index []int
+}
+
+// fields returns the fields of the struct type typ in order, with the
+// fields of untagged embedded structs in place of the structs.
+func fields(typ reflect.Type) []field {
+	var fs []field
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, skip := FieldName(f)
+		if skip {
+			continue
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("plist") == "" {
+			for _, ef := range fields(f.Type) {
+				ef.index = append([]int{i}, ef.index...)
+				fs = append(fs, ef)
+			}
+			continue
+		}
+		_, opts := parseTag(f)
+		fs = append(fs, field{name, opts, []int{i}})
+	}
+	return fs
+}
+
+// FieldIndex returns the index sequence of the field of the struct
+// type typ that holds the key, for reflect.Value.FieldByIndex, or nil
+// if no field does.
+func FieldIndex(typ reflect.Type, key string) []int {
+	for _, f := range fields(typ) {
+		if f.name == key && !hasOption(f.opts, "unknown") {
+			return f.index
+		}
+	}
+	return nil
+}
+
+// parseTag splits the plist tag of f into the key and the options.
+func parseTag(f reflect.StructField) (string, string) {
+	tag := f.Tag.Get("plist")
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+// HasOption reports whether the plist tag of f includes the option
+// opt, such as an option that only a validator reads.
+func HasOption(f reflect.StructField, opt string) bool {
+	_, opts := parseTag(f)
+	return hasOption(opts, opt)
+}
+
+// hasOption reports whether the tag options opts include opt.
+func hasOption(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// An Unmarshaler is a type that decodes itself, such as a type with
+// several forms in a plist. UnmarshalPlist is given a function that
+// decodes the plist value into the value v points to, which it may
+// call more than once to try the forms it accepts.
+type Unmarshaler interface {
+	UnmarshalPlist(unmarshal func(v interface{}) error) error
+}
+
+// Set stores the decoded plist value val into rv. A value whose type
+// is that of rv, such as a time.Time or a UID, is stored as it is.
+// Dicts are read into maps and structs, arrays into slices and arrays,
+// and integers into integer and real fields.
+func Set(rv reflect.Value, val interface{}) error {
+	return setter{}.set(rv, val)
+}
+
+// SetText is like Set, for plists that store all scalars as strings,
+// such as ASCII plists. A string read into a number or a boolean is
+// parsed, accepting YES and NO as booleans, and a string read into a
+// time.Time is parsed as a date in the given layout.
+func SetText(rv reflect.Value, val interface{}, dateLayout string) error {
+	return setter{text: true, dateLayout: dateLayout}.set(rv, val)
+}
+
+// A setter stores decoded plist values into Go values.
+type setter struct {
+	text       bool
+	dateLayout string
+}
+
+func (s setter) set(rv reflect.Value, val interface{}) error {
+	if rv.CanAddr() && rv.CanInterface() {
+		if u, ok := rv.Addr().Interface().(Unmarshaler); ok {
+			return u.UnmarshalPlist(func(v interface{}) error {
+				pv := reflect.ValueOf(v)
+				if pv.Kind() != reflect.Ptr || pv.IsNil() {
+					return errors.New("plist: v must be ptr")
+				}
+				return s.set(pv.Elem(), val)
+			})
+		}
+	}
+	if str, ok := val.(string); ok && s.text {
+		v, err := s.parse(rv.Type(), str)
+		if err != nil {
+			return err
+		}
+		val = v
+	}
+	vv := reflect.ValueOf(val)
+	if vv.IsValid() && vv.Type() == rv.Type() {
+		rv.Set(vv)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			break
+		}
+		rv.Set(vv)
+		return nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return s.set(rv.Elem(), val)
+	case reflect.Bool:
+		if b, ok := val.(bool); ok {
+			rv.SetBool(b)
+			return nil
+		}
+	case reflect.String:
+		if str, ok := val.(string); ok {
+			rv.SetString(str)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := val.(int64); ok {
+			if rv.OverflowInt(i) {
+				return fmt.Errorf("plist: integer %v overflows %v", i, rv.Type())
+			}
+			rv.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		switch i := val.(type) {
+		case int64:
+			if i < 0 {
+				return fmt.Errorf("plist: integer %v overflows %v", i, rv.Type())
+			}
+			u = uint64(i)
+		case uint64:
+			u = i
+		default:
+			return fmt.Errorf("plist: cannot read %T into %v", val, rv.Type())
+		}
+		if rv.OverflowUint(u) {
+			return fmt.Errorf("plist: integer %v overflows %v", u, rv.Type())
+		}
+		rv.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch f := val.(type) {
+		case float64:
+			rv.SetFloat(f)
+			return nil
+		case int64:
+			rv.SetFloat(float64(f))
+			return nil
+		case uint64:
+			rv.SetFloat(float64(f))
+			return nil
+		}
+	case reflect.Slice:
+		if b, ok := val.([]byte); ok && rv.Type().Elem().Kind() == reflect.Uint8 {
+			rv.SetBytes(b)
+			return nil
+		}
+		if a, ok := val.([]interface{}); ok {
+			sv := reflect.MakeSlice(rv.Type(), len(a), len(a))
+			for i := range a {
+				err := s.set(sv.Index(i), a[i])
+				if err != nil {
+					return err
+				}
+			}
+			rv.Set(sv)
+			return nil
+		}
+	case reflect.Array:
+		if a, ok := val.([]interface{}); ok {
+			for i := 0; i < rv.Len() && i < len(a); i++ {
+				err := s.set(rv.Index(i), a[i])
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		m, ok := val.(map[string]interface{})
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		mv := reflect.MakeMap(rv.Type())
+		for k, elem := range m {
+			ev := reflect.New(rv.Type().Elem()).Elem()
+			err := s.set(ev, elem)
+			if err != nil {
+				return err
+			}
+			mv.SetMapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()), ev)
+		}
+		rv.Set(mv)
+		return nil
+	case reflect.Struct:
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			break
+		}
+		known := make(map[string]bool)
+		var unknown []int
+		for _, f := range fields(rv.Type()) {
+			if hasOption(f.opts, "unknown") {
+				unknown = f.index
+				continue
+			}
+			known[f.name] = true
+			elem, ok := m[f.name]
+			if !ok {
+				continue
+			}
+			err := s.set(rv.FieldByIndex(f.index), elem)
+			if err != nil {
+				return err
+			}
+		}
+		if unknown != nil {
+			return s.setUnknown(rv.FieldByIndex(unknown), m, known)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("plist: cannot read %T into %v", val, rv.Type())
+}
+
+// setUnknown stores the entries of m whose keys are not known into
+// the map rv. It leaves rv alone if there are none.
+func (s setter) setUnknown(rv reflect.Value, m map[string]interface{}, known map[string]bool) error {
+	rest := make(map[string]interface{})
+	for k, v := range m {
+		if !known[k] {
+			rest[k] = v
+		}
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+	return s.set(rv, rest)
+}
+
+// parse parses str, a scalar of a text plist, into a value that can
+// be stored into a value of type typ. Strings that need no parsing
+// for typ are returned as they are.
+func (s setter) parse(typ reflect.Type, str string) (interface{}, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		switch str {
+		case "YES", "true":
+			return true, nil
+		case "NO", "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("plist: cannot read %q into %v", str, typ)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: cannot read %q into %v", str, typ)
+		}
+		return i, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(str, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: cannot read %q into %v", str, typ)
+		}
+		return u, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: cannot read %q into %v", str, typ)
+		}
+		return f, nil
+	case reflect.Struct:
+		if typ != timeType {
+			break
+		}
+		t, err := time.Parse(s.dateLayout, str)
+		if err != nil {
+			return nil, fmt.Errorf("plist: cannot read %q into %v", str, typ)
+		}
+		return t, nil
+	}
+	return str, nil
+}
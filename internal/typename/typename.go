@@ -0,0 +1,71 @@
+// Package typename names the types of plist values, as used in the
+// messages of the validators and of plistdiff.
+package typename
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/mkrautz/plist/binaryplist"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uidType  = reflect.TypeOf(binaryplist.UID(0))
+)
+
+// Of returns the plist type name of the decoded value v, such as
+// "dict" or "boolean".
+func Of(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}, binaryplist.OrderedDict:
+		return "dict"
+	case []interface{}:
+		return "array"
+	case []byte:
+		return "data"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "real"
+	case time.Time:
+		return "date"
+	case binaryplist.UID:
+		return "uid"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// ForType returns the plist type name of the values stored into Go
+// values of type rt.
+func ForType(rt reflect.Type) string {
+	switch {
+	case rt == timeType:
+		return "date"
+	case rt == uidType:
+		return "uid"
+	case rt.Kind() == reflect.Slice && rt.Elem().Kind() == reflect.Uint8:
+		return "data"
+	}
+	switch rt.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "real"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "dict"
+	}
+	return rt.String()
+}
@@ -0,0 +1,51 @@
+package plist
+
+import (
+	"bytes"
+
+	"github.com/mkrautz/plist/jsonplist"
+)
+
+// A JSONMode selects how ToJSON and FromJSON represent
+// plist values that JSON has no equivalent for.
+type JSONMode int
+
+const (
+	// PlainJSON matches plutil -convert json. Dates, data, UIDs
+	// and non-finite reals cannot be converted, and integral reals
+	// are read back as integers.
+	PlainJSON JSONMode = iota
+
+	// TypedJSON is lossless. Reals are always written with a
+	// fraction or exponent, and dates, data, non-finite reals and
+	// UIDs are wrapped in single-key objects:
+	//
+	//	{"$date": "2012-01-29T13:07:25Z"}
+	//	{"$data": "////"}
+	//	{"$real": "NaN"}
+	//	{"$uid": 5}
+	//
+	// A dict that would be mistaken for one of these is wrapped
+	// as {"$dict": {...}}.
+	TypedJSON
+)
+
+// ToJSON returns the compact JSON encoding of the plist value v.
+func ToJSON(v interface{}, mode JSONMode) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := jsonplist.NewEncoder(buf)
+	enc.SetTyped(mode == TypedJSON)
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// FromJSON parses the JSON data and stores the resulting plist
+// value in the value pointed to by v.
+func FromJSON(data []byte, v interface{}, mode JSONMode) error {
+	dec := jsonplist.NewDecoder(bytes.NewBuffer(data))
+	dec.SetTyped(mode == TypedJSON)
+	return dec.Decode(v)
+}
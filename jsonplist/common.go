@@ -0,0 +1,43 @@
+package jsonplist
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/mkrautz/plist/binaryplist"
+)
+
+// In typed mode, plist values that JSON cannot represent are wrapped
+// in a single-key object (an envelope) whose key names the type.
+const (
+	// {"$date": "2012-01-29T13:07:25Z"}
+	envelopeDate = "$date"
+	// {"$data": "////"}, standard base64 encoding
+	envelopeData = "$data"
+	// {"$real": "NaN"}, for reals JSON numbers cannot hold:
+	// "NaN", "Infinity" and "-Infinity"
+	envelopeReal = "$real"
+	// {"$uid": 5}, for the UIDs of binary plists
+	envelopeUID = "$uid"
+	// {"$dict": {...}}, for dicts that would otherwise be
+	// mistaken for an envelope
+	envelopeDict = "$dict"
+)
+
+// IsEnvelopeKey reports whether key is one of the keys of the
+// single-key objects typed mode wraps plist values in, such as $date.
+func IsEnvelopeKey(key string) bool {
+	switch key {
+	case envelopeDate, envelopeData, envelopeReal, envelopeUID, envelopeDict:
+		return true
+	}
+	return false
+}
+
+var (
+	uidType         = reflect.TypeOf(binaryplist.UID(0))
+	orderedDictType = reflect.TypeOf(binaryplist.OrderedDict(nil))
+)
+
+// The format of dates in typed mode.
+const dateFormat = time.RFC3339Nano
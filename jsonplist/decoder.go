@@ -0,0 +1,263 @@
+package jsonplist
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist/binaryplist"
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+// Unmarshal parses the plain JSON data and stores the result
+// in the value pointed to by v.
+func Unmarshal(data []byte, v interface{}) error {
+	dec := NewDecoder(bytes.NewBuffer(data))
+	return dec.Decode(v)
+}
+
+// A Decoder represents a reader that reads JSON
+// into plist values.
+type Decoder struct {
+	r       io.Reader
+	typed   bool
+	ordered bool
+}
+
+// NewDecoder creates a new JSON reader in plain mode.
+func NewDecoder(r io.Reader) *Decoder {
+	d := new(Decoder)
+	d.r = r
+	return d
+}
+
+// SetTyped selects between plain mode (the default) and
+// lossless typed mode.
+func (d *Decoder) SetTyped(typed bool) {
+	d.typed = typed
+}
+
+// UseOrderedDicts makes Decode return dicts as OrderedDict values,
+// with their entries in the order of the input, when v points to an
+// empty interface.
+func (d *Decoder) UseOrderedDicts() {
+	d.ordered = true
+}
+
+// Decode decodes a single JSON value from the decoder.
+func (d *Decoder) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("plist: v must be ptr")
+	}
+
+	jd := json.NewDecoder(d.r)
+	jd.UseNumber()
+	var raw interface{}
+	var err error
+	if d.ordered && rv.Elem().Kind() == reflect.Interface {
+		raw, err = readOrdered(jd)
+	} else {
+		err = jd.Decode(&raw)
+	}
+	if err != nil {
+		return err
+	}
+
+	val, err := d.convert(raw)
+	if err != nil {
+		return err
+	}
+	return plistreflect.Set(rv.Elem(), val)
+}
+
+// readOrdered reads a JSON value as encoding/json does, but returns
+// objects as OrderedDict values. Of duplicate members, the last value
+// is kept in the place of the first.
+func readOrdered(jd *json.Decoder) (interface{}, error) {
+	tok, err := jd.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('['):
+		a := []interface{}{}
+		for jd.More() {
+			elem, err := readOrdered(jd)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, elem)
+		}
+		_, err = jd.Token()
+		return a, err
+	case json.Delim('{'):
+		od := binaryplist.OrderedDict{}
+		index := make(map[string]int)
+		for jd.More() {
+			tok, err := jd.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := tok.(string)
+			val, err := readOrdered(jd)
+			if err != nil {
+				return nil, err
+			}
+			if i, dup := index[key]; dup {
+				od[i].Value = val
+				continue
+			}
+			index[key] = len(od)
+			od = append(od, binaryplist.DictEntry{Key: key, Value: val})
+		}
+		_, err = jd.Token()
+		return od, err
+	}
+	return tok, nil
+}
+
+// convert converts a value produced by encoding/json into
+// the equivalent plist value.
+func (d *Decoder) convert(raw interface{}) (interface{}, error) {
+	switch x := raw.(type) {
+	case nil:
+		return nil, errors.New("plist: null cannot be represented in a plist")
+	case bool, string:
+		return x, nil
+	case json.Number:
+		return convertNumber(string(x))
+	case []interface{}:
+		a := make([]interface{}, len(x))
+		for i := range x {
+			elem, err := d.convert(x[i])
+			if err != nil {
+				return nil, err
+			}
+			a[i] = elem
+		}
+		return a, nil
+	case map[string]interface{}:
+		if d.typed && len(x) == 1 {
+			for k, elem := range x {
+				if IsEnvelopeKey(k) {
+					return d.convertEnvelope(k, elem)
+				}
+			}
+		}
+		return d.convertDict(x)
+	case binaryplist.OrderedDict:
+		if d.typed && len(x) == 1 && IsEnvelopeKey(x[0].Key) {
+			return d.convertEnvelope(x[0].Key, x[0].Value)
+		}
+		return d.convertOrderedDict(x)
+	}
+	return nil, fmt.Errorf("plist: unexpected JSON value %T", raw)
+}
+
+// convertDict converts the values of a JSON object.
+func (d *Decoder) convertDict(raw map[string]interface{}) (interface{}, error) {
+	m := make(map[string]interface{}, len(raw))
+	for k, elem := range raw {
+		val, err := d.convert(elem)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = val
+	}
+	return m, nil
+}
+
+// convertOrderedDict converts the values of a JSON object read by
+// readOrdered.
+func (d *Decoder) convertOrderedDict(raw binaryplist.OrderedDict) (interface{}, error) {
+	od := make(binaryplist.OrderedDict, len(raw))
+	for i, e := range raw {
+		val, err := d.convert(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		od[i] = binaryplist.DictEntry{Key: e.Key, Value: val}
+	}
+	return od, nil
+}
+
+// convertNumber converts a JSON number into an integer if it
+// has neither a fraction nor an exponent, and into a real otherwise.
+func convertNumber(str string) (interface{}, error) {
+	if strings.ContainsAny(str, ".eE") {
+		return strconv.ParseFloat(str, 64)
+	}
+	i, err := strconv.ParseInt(str, 10, 64)
+	if err == nil {
+		return i, nil
+	}
+	u, err := strconv.ParseUint(str, 10, 64)
+	if err == nil {
+		return u, nil
+	}
+	return nil, fmt.Errorf("plist: integer %v out of range", str)
+}
+
+// convertEnvelope converts the contents of a typed mode envelope.
+func (d *Decoder) convertEnvelope(key string, raw interface{}) (interface{}, error) {
+	if key == envelopeDict {
+		switch m := raw.(type) {
+		case map[string]interface{}:
+			return d.convertDict(m)
+		case binaryplist.OrderedDict:
+			return d.convertOrderedDict(m)
+		}
+		return nil, fmt.Errorf("plist: %s must hold an object", key)
+	}
+
+	if key == envelopeUID {
+		n, ok := raw.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("plist: %s must hold an integer", key)
+		}
+		u, err := strconv.ParseUint(string(n), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid UID %v", n)
+		}
+		return binaryplist.UID(u), nil
+	}
+
+	str, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("plist: %s must hold a string", key)
+	}
+	switch key {
+	case envelopeDate:
+		t, err := time.Parse(dateFormat, str)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid date %q", str)
+		}
+		return t, nil
+	case envelopeData:
+		b, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return nil, fmt.Errorf("plist: invalid data %q", str)
+		}
+		return b, nil
+	case envelopeReal:
+		switch str {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+		return nil, fmt.Errorf("plist: invalid real %q", str)
+	}
+	return nil, fmt.Errorf("plist: unknown envelope %s", key)
+}
@@ -0,0 +1,268 @@
+// Package jsonplist converts between plist values and JSON.
+//
+// In plain mode, the conversion matches plutil -convert json: dates
+// and data have no JSON representation and are rejected, and reals
+// with an integral value are indistinguishable from integers.
+//
+// Typed mode is lossless. Reals are always written with a fraction
+// or exponent, so they can be told apart from integers, and dates,
+// data, non-finite reals and UIDs are wrapped in single-key objects
+// such as {"$date": "2012-01-29T13:07:25Z"}. Dicts that would be mistaken
+// for such an object are themselves wrapped as {"$dict": {...}}.
+// Plain JSON decodes the same way in both modes, unless it contains
+// objects that look like those wrappers.
+package jsonplist
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist/binaryplist"
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+// Marshal returns the plain JSON encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// An Encoder encodes Go values into JSON.
+type Encoder struct {
+	w      io.Writer
+	typed  bool
+	prefix string
+	indent string
+}
+
+// NewEncoder returns a new Encoder that writes plain JSON to w.
+func NewEncoder(w io.Writer) *Encoder {
+	enc := new(Encoder)
+	enc.w = w
+	return enc
+}
+
+// SetTyped selects between plain mode (the default) and
+// lossless typed mode.
+func (e *Encoder) SetTyped(typed bool) {
+	e.typed = typed
+}
+
+// SetIndent makes the encoder indent its output, in the same
+// manner as json.Indent. By default, output is compact.
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.prefix = prefix
+	e.indent = indent
+}
+
+// Encode writes the JSON encoding of v, followed by a newline,
+// to the encoder's writer.
+func (e *Encoder) Encode(v interface{}) error {
+	buf := new(bytes.Buffer)
+	err := e.encodeAny(buf, reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+
+	if e.prefix != "" || e.indent != "" {
+		indented := new(bytes.Buffer)
+		err = json.Indent(indented, buf.Bytes(), e.prefix, e.indent)
+		if err != nil {
+			return err
+		}
+		buf = indented
+	}
+	buf.WriteByte('\n')
+
+	_, err = e.w.Write(buf.Bytes())
+	return err
+}
+
+// unrepresentable returns the error used in plain mode for
+// values that JSON cannot represent.
+func unrepresentable(what string) error {
+	return fmt.Errorf("plist: %s cannot be represented in plain JSON", what)
+}
+
+// encodeAny encodes any type into its JSON equivalent.
+func (e *Encoder) encodeAny(buf *bytes.Buffer, rv reflect.Value) error {
+	rv, err := plistreflect.Marshal(rv)
+	if err != nil {
+		return err
+	}
+	if rv.IsValid() && rv.Type() == orderedDictType {
+		d := rv.Interface().(binaryplist.OrderedDict)
+		keys := make([]string, len(d))
+		vals := make([]reflect.Value, len(d))
+		for i := range d {
+			keys[i] = d[i].Key
+			vals[i] = reflect.ValueOf(&d[i].Value).Elem()
+		}
+		return e.encodeDict(buf, keys, vals)
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(rv.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(rv.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Type() == uidType {
+			if !e.typed {
+				return unrepresentable("UIDs")
+			}
+			buf.WriteByte('{')
+			writeString(buf, envelopeUID)
+			buf.WriteByte(':')
+			buf.WriteString(strconv.FormatUint(rv.Uint(), 10))
+			buf.WriteByte('}')
+			return nil
+		}
+		buf.WriteString(strconv.FormatUint(rv.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		return e.encodeReal(buf, rv.Float())
+	case reflect.String:
+		writeString(buf, rv.String())
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			if !e.typed {
+				return unrepresentable("data")
+			}
+			data := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(data), rv)
+			writeEnvelope(buf, envelopeData, base64.StdEncoding.EncodeToString(data))
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			err := e.encodeAny(buf, rv.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return errors.New("plist: bad map kind (must have string keys)")
+		}
+		keys := make([]string, 0, rv.Len())
+		for _, kv := range rv.MapKeys() {
+			keys = append(keys, kv.String())
+		}
+		sort.Strings(keys)
+		vals := make([]reflect.Value, len(keys))
+		for i, k := range keys {
+			vals[i] = rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()))
+		}
+		return e.encodeDict(buf, keys, vals)
+	case reflect.Struct:
+		if t, ok := rv.Interface().(time.Time); ok {
+			if !e.typed {
+				return unrepresentable("dates")
+			}
+			writeEnvelope(buf, envelopeDate, t.UTC().Format(dateFormat))
+			return nil
+		}
+		keys, vals := plistreflect.StructEntries(rv)
+		return e.encodeDict(buf, keys, vals)
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			return errors.New("plist: cannot encode nil value")
+		}
+		return e.encodeAny(buf, rv.Elem())
+	default:
+		return fmt.Errorf("plist: cannot encode %v", rv.Kind())
+	}
+	return nil
+}
+
+// encodeReal encodes a real number. In typed mode, reals are always
+// written with a fraction or an exponent.
+func (e *Encoder) encodeReal(buf *bytes.Buffer, f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		if !e.typed {
+			return unrepresentable("non-finite reals")
+		}
+		str := "NaN"
+		if math.IsInf(f, 1) {
+			str = "Infinity"
+		} else if math.IsInf(f, -1) {
+			str = "-Infinity"
+		}
+		writeEnvelope(buf, envelopeReal, str)
+		return nil
+	}
+
+	str := strconv.FormatFloat(f, 'g', -1, 64)
+	if e.typed && !strings.ContainsAny(str, ".e") {
+		str += ".0"
+	}
+	buf.WriteString(str)
+	return nil
+}
+
+// encodeDict encodes a dict with the given keys and values.
+func (e *Encoder) encodeDict(buf *bytes.Buffer, keys []string, vals []reflect.Value) error {
+	wrap := e.typed && len(keys) == 1 && IsEnvelopeKey(keys[0])
+	if wrap {
+		buf.WriteByte('{')
+		writeString(buf, envelopeDict)
+		buf.WriteByte(':')
+	}
+
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeString(buf, k)
+		buf.WriteByte(':')
+		err := e.encodeAny(buf, vals[i])
+		if err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+
+	if wrap {
+		buf.WriteByte('}')
+	}
+	return nil
+}
+
+// writeEnvelope writes a typed mode envelope holding a string.
+func writeEnvelope(buf *bytes.Buffer, key, val string) {
+	buf.WriteByte('{')
+	writeString(buf, key)
+	buf.WriteByte(':')
+	writeString(buf, val)
+	buf.WriteByte('}')
+}
+
+// writeString writes str as a JSON string. Unlike json.Marshal,
+// it leaves <, > and & unescaped.
+func writeString(buf *bytes.Buffer, str string) {
+	var sb bytes.Buffer
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	enc.Encode(str)
+	buf.Write(bytes.TrimSuffix(sb.Bytes(), []byte("\n")))
+}
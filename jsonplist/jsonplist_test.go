@@ -0,0 +1,127 @@
+package jsonplist
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mkrautz/plist/binaryplist"
+)
+
+func everything() map[string]interface{} {
+	return map[string]interface{}{
+		"int":   int64(42),
+		"big":   uint64(math.MaxUint64),
+		"real":  float64(50),
+		"nan":   math.Inf(-1),
+		"date":  time.Date(2012, 1, 29, 13, 7, 25, 0, time.UTC),
+		"data":  []byte{0xff, 0xff, 0xff},
+		"str":   "<a & b>",
+		"bool":  true,
+		"array": []interface{}{"x", float64(1.5)},
+		"dict":  map[string]interface{}{"$date": "not a date"},
+		"uid":   binaryplist.UID(7),
+	}
+}
+
+func TestTypedEncode(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetTyped(true)
+	err := enc.Encode(everything())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := `{"array":["x",1.5],"big":18446744073709551615,"bool":true,` +
+		`"data":{"$data":"////"},"date":{"$date":"2012-01-29T13:07:25Z"},` +
+		`"dict":{"$dict":{"$date":"not a date"}},"int":42,` +
+		`"nan":{"$real":"-Infinity"},"real":50.0,"str":"<a & b>","uid":{"$uid":7}}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected output:\n%s", buf)
+	}
+}
+
+func TestTypedRoundTrip(t *testing.T) {
+	buf := new(bytes.Buffer)
+	enc := NewEncoder(buf)
+	enc.SetTyped(true)
+	err := enc.Encode(everything())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var actual map[string]interface{}
+	dec := NewDecoder(buf)
+	dec.SetTyped(true)
+	err = dec.Decode(&actual)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(actual, everything()) {
+		t.Fatalf("got %#v, expected %#v", actual, everything())
+	}
+}
+
+func TestPlainEncode(t *testing.T) {
+	buf, err := Marshal(map[string]interface{}{"real": float64(50), "int": 7})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(buf) != `{"int":7,"real":50}`+"\n" {
+		t.Fatalf("unexpected output %q", buf)
+	}
+
+	for _, v := range []interface{}{
+		time.Now(),
+		[]byte{1},
+		math.NaN(),
+		[]interface{}{map[string]interface{}{"a": []byte{}}},
+	} {
+		_, err := Marshal(v)
+		if err == nil {
+			t.Errorf("expected error encoding %#v", v)
+		}
+	}
+}
+
+func TestPlainDecode(t *testing.T) {
+	var actual interface{}
+	err := Unmarshal([]byte(`{"a":[1, 1.0, 1e3, "s", false], "b": {"$data": "////"}}`), &actual)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := map[string]interface{}{
+		"a": []interface{}{int64(1), float64(1), float64(1000), "s", false},
+		"b": map[string]interface{}{"$data": "////"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("got %#v, expected %#v", actual, expected)
+	}
+
+	err = Unmarshal([]byte(`[null]`), &actual)
+	if err == nil {
+		t.Fatalf("expected error decoding null")
+	}
+}
+
+func TestDecodeStruct(t *testing.T) {
+	type Info struct {
+		Name    string `plist:"CFBundleName"`
+		Version int
+		Icon    []byte
+	}
+	var info Info
+	dec := NewDecoder(bytes.NewBufferString(`{"CFBundleName":"Test","Version":3,"Icon":{"$data":"AQI="}}`))
+	dec.SetTyped(true)
+	err := dec.Decode(&info)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := Info{"Test", 3, []byte{1, 2}}
+	if !reflect.DeepEqual(info, expected) {
+		t.Fatalf("got %#v, expected %#v", info, expected)
+	}
+}
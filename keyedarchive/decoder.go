@@ -0,0 +1,361 @@
+// Package keyedarchive decodes and encodes NSKeyedArchiver archives.
+//
+// An archive is a plist that holds an object graph in a flattened
+// form: each object is stored once in the $objects array, and objects
+// refer to each other through UIDs. Decoding resolves those references
+// into Go values:
+//
+//	NSString             string
+//	NSNumber             int64, uint64, float64 or bool
+//	NSData               []byte
+//	NSDate               time.Time
+//	NSArray              []interface{}
+//	NSDictionary         map[string]interface{}, or
+//	                     map[interface{}]interface{} for non-string keys
+//	NSSet                Set
+//	NSURL                *url.URL
+//	NSUUID               UUID
+//	NSNull               Null
+//	other classes        *Object
+//
+// The mutable variants of these classes decode the same way. A nil
+// reference decodes to nil.
+package keyedarchive
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/internal/cfdate"
+)
+
+// The archiver and version that identify keyed archives.
+const (
+	archiverName    = "NSKeyedArchiver"
+	archiverVersion = 100000
+)
+
+// An Archive is a decoded NSKeyedArchiver archive.
+type Archive struct {
+	objects    []interface{}
+	top        map[string]interface{}
+	cache      map[uint64]interface{}
+	inProgress map[uint64]bool
+}
+
+// Unarchive decodes the archive in data, which may be a plist of any
+// kind, and returns its root object.
+func Unarchive(data []byte) (interface{}, error) {
+	var v interface{}
+	err := plist.Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+	a, err := NewArchive(v)
+	if err != nil {
+		return nil, err
+	}
+	return a.Object("root")
+}
+
+// NewArchive returns the archive held by v, a plist value as produced
+// by unmarshaling into an interface{}.
+func NewArchive(v interface{}) (*Archive, error) {
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("plist: keyed archive is not a dict")
+	}
+	if dict["$archiver"] != archiverName {
+		return nil, fmt.Errorf("plist: unsupported archiver %v", dict["$archiver"])
+	}
+	if version, ok := dict["$version"].(int64); !ok || version != archiverVersion {
+		return nil, fmt.Errorf("plist: unsupported archive version %v", dict["$version"])
+	}
+	a := new(Archive)
+	a.objects, ok = dict["$objects"].([]interface{})
+	if !ok {
+		return nil, errors.New("plist: keyed archive has no $objects array")
+	}
+	a.top, ok = dict["$top"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("plist: keyed archive has no $top dict")
+	}
+	a.cache = make(map[uint64]interface{})
+	a.inProgress = make(map[uint64]bool)
+	return a, nil
+}
+
+// Keys returns the keys of the archive's top-level objects,
+// in sorted order. Most archives have a single key, "root".
+func (a *Archive) Keys() []string {
+	keys := make([]string, 0, len(a.top))
+	for k := range a.top {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Object returns the top-level object archived under key.
+func (a *Archive) Object(key string) (interface{}, error) {
+	ref, ok := a.top[key]
+	if !ok {
+		return nil, fmt.Errorf("plist: keyed archive has no top-level object %q", key)
+	}
+	return a.resolve(ref)
+}
+
+// uidOf returns the object reference held by v.
+func uidOf(v interface{}) (uint64, bool) {
+	uid, ok := v.(plist.UID)
+	return uint64(uid), ok
+}
+
+// resolve resolves a value that should be an object reference.
+func (a *Archive) resolve(v interface{}) (interface{}, error) {
+	ref, ok := uidOf(v)
+	if !ok {
+		return nil, fmt.Errorf("plist: expected object reference, found %T", v)
+	}
+	return a.object(ref)
+}
+
+// object returns the decoded object with reference ref.
+func (a *Archive) object(ref uint64) (interface{}, error) {
+	if v, ok := a.cache[ref]; ok {
+		return v, nil
+	}
+	if ref >= uint64(len(a.objects)) {
+		return nil, fmt.Errorf("plist: object reference %v out of range", ref)
+	}
+	if a.inProgress[ref] {
+		return nil, fmt.Errorf("plist: object %v refers to itself through a collection", ref)
+	}
+	a.inProgress[ref] = true
+	defer delete(a.inProgress, ref)
+
+	raw := a.objects[ref]
+	if s, ok := raw.(string); ok && s == "$null" {
+		return nil, nil
+	}
+	dict, ok := raw.(map[string]interface{})
+	if !ok {
+		// Strings, numbers, data and the like are stored inline.
+		a.cache[ref] = raw
+		return raw, nil
+	}
+	classes, err := a.classes(dict)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	switch classes[0] {
+	case "NSString", "NSMutableString":
+		v, ok = dict["NS.string"].(string)
+		if !ok {
+			return nil, fmt.Errorf("plist: %s without NS.string", classes[0])
+		}
+	case "NSData", "NSMutableData":
+		v, ok = dict["NS.data"].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("plist: %s without NS.data", classes[0])
+		}
+	case "NSDate":
+		v, err = decodeDate(dict["NS.time"])
+	case "NSArray", "NSMutableArray":
+		v, err = a.resolveAll(dict["NS.objects"])
+	case "NSSet", "NSMutableSet":
+		var elems []interface{}
+		elems, err = a.resolveAll(dict["NS.objects"])
+		v = Set(elems)
+	case "NSDictionary", "NSMutableDictionary":
+		v, err = a.dictionary(dict)
+	case "NSURL":
+		v, err = a.url(dict)
+	case "NSUUID":
+		b, ok := dict["NS.uuidbytes"].([]byte)
+		if !ok || len(b) != 16 {
+			return nil, errors.New("plist: NSUUID without 16 NS.uuidbytes")
+		}
+		var u UUID
+		copy(u[:], b)
+		v = u
+	case "NSNull":
+		v = Null{}
+	default:
+		// Objects are cached before their fields are resolved, so
+		// that they may refer to themselves.
+		obj := &Object{Classes: classes, Fields: make(map[string]interface{})}
+		a.cache[ref] = obj
+		delete(a.inProgress, ref)
+		for k, fv := range dict {
+			if k == "$class" {
+				continue
+			}
+			fv, err = a.resolveField(fv)
+			if err != nil {
+				return nil, err
+			}
+			obj.Fields[k] = fv
+		}
+		return obj, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.cache[ref] = v
+	return v, nil
+}
+
+// resolveField resolves the object references found in a field of
+// an object of an unknown class, including those inside arrays.
+func (a *Archive) resolveField(v interface{}) (interface{}, error) {
+	if _, ok := uidOf(v); ok {
+		return a.resolve(v)
+	}
+	if arr, ok := v.([]interface{}); ok {
+		res := make([]interface{}, len(arr))
+		for i, elem := range arr {
+			r, err := a.resolveField(elem)
+			if err != nil {
+				return nil, err
+			}
+			res[i] = r
+		}
+		return res, nil
+	}
+	return v, nil
+}
+
+// classes returns the class hierarchy of an archived object.
+func (a *Archive) classes(dict map[string]interface{}) ([]string, error) {
+	ref, ok := uidOf(dict["$class"])
+	if !ok || ref >= uint64(len(a.objects)) {
+		return nil, errors.New("plist: archived object has no valid $class")
+	}
+	class, ok := a.objects[ref].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("plist: archived class is not a dict")
+	}
+	var classes []string
+	if list, ok := class["$classes"].([]interface{}); ok {
+		for _, c := range list {
+			if s, ok := c.(string); ok {
+				classes = append(classes, s)
+			}
+		}
+	}
+	if name, ok := class["$classname"].(string); ok && (len(classes) == 0 || classes[0] != name) {
+		classes = append([]string{name}, classes...)
+	}
+	if len(classes) == 0 {
+		return nil, errors.New("plist: archived class has no name")
+	}
+	return classes, nil
+}
+
+// resolveAll resolves an array of object references.
+func (a *Archive) resolveAll(v interface{}) ([]interface{}, error) {
+	refs, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("plist: expected array of object references")
+	}
+	objs := make([]interface{}, len(refs))
+	for i, r := range refs {
+		obj, err := a.resolve(r)
+		if err != nil {
+			return nil, err
+		}
+		objs[i] = obj
+	}
+	return objs, nil
+}
+
+// dictionary decodes an NSDictionary.
+func (a *Archive) dictionary(dict map[string]interface{}) (interface{}, error) {
+	keys, err := a.resolveAll(dict["NS.keys"])
+	if err != nil {
+		return nil, err
+	}
+	vals, err := a.resolveAll(dict["NS.objects"])
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != len(vals) {
+		return nil, errors.New("plist: NSDictionary has mismatched keys and objects")
+	}
+
+	stringKeys := true
+	for _, k := range keys {
+		if _, ok := k.(string); !ok {
+			stringKeys = false
+		}
+	}
+	if stringKeys {
+		m := make(map[string]interface{}, len(keys))
+		for i, k := range keys {
+			m[k.(string)] = vals[i]
+		}
+		return m, nil
+	}
+
+	m := make(map[interface{}]interface{}, len(keys))
+	for i, k := range keys {
+		switch k.(type) {
+		case []byte, []interface{}, map[string]interface{}, map[interface{}]interface{}, Set:
+			return nil, fmt.Errorf("plist: unsupported NSDictionary key of type %T", k)
+		}
+		m[k] = vals[i]
+	}
+	return m, nil
+}
+
+// url decodes an NSURL, which is stored as a string relative
+// to an optional base URL.
+func (a *Archive) url(dict map[string]interface{}) (*url.URL, error) {
+	rel, err := a.resolve(dict["NS.relative"])
+	if err != nil {
+		return nil, err
+	}
+	s, ok := rel.(string)
+	if !ok {
+		return nil, errors.New("plist: NSURL without NS.relative string")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := a.resolve(dict["NS.base"])
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		return u, nil
+	}
+	b, ok := base.(*url.URL)
+	if !ok {
+		return nil, errors.New("plist: NSURL with invalid NS.base")
+	}
+	return b.ResolveReference(u), nil
+}
+
+// decodeDate converts a number of seconds relative to the
+// reference date into a time.Time.
+func decodeDate(v interface{}) (time.Time, error) {
+	var secs float64
+	switch n := v.(type) {
+	case float64:
+		secs = n
+	case int64:
+		secs = float64(n)
+	default:
+		return time.Time{}, errors.New("plist: NSDate without NS.time")
+	}
+	return cfdate.Time(secs), nil
+}
@@ -0,0 +1,102 @@
+package keyedarchive
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/binaryplist"
+)
+
+func checkArchive(t *testing.T, root interface{}) {
+	dict, ok := root.(map[string]interface{})
+	if !ok {
+		t.Fatalf("root is %T, not a dict", root)
+	}
+
+	expected := map[string]interface{}{
+		"name": "hello",
+		"items": []interface{}{
+			int64(1),
+			float64(2.5),
+			time.Date(2012, 1, 29, 13, 7, 25, 0, time.UTC),
+			[]byte{1, 2, 3},
+		},
+		"set":  Set{"a"},
+		"uuid": UUID{0x68, 0x75, 0x3a, 0x44, 0x4d, 0x6f, 0x12, 0x26, 0x9c, 0x60, 0x00, 0x50, 0xe4, 0xc0, 0x00, 0x67},
+		"null": Null{},
+	}
+	for k, v := range expected {
+		if !reflect.DeepEqual(dict[k], v) {
+			t.Errorf("%v: got %#v, expected %#v", k, dict[k], v)
+		}
+	}
+
+	if u := dict["uuid"].(UUID).String(); u != "68753A44-4D6F-1226-9C60-0050E4C00067" {
+		t.Errorf("unexpected UUID string %v", u)
+	}
+	if u, ok := dict["url"].(interface{ String() string }); !ok || u.String() != "https://example.com/dir/file.txt" {
+		t.Errorf("unexpected URL %#v", dict["url"])
+	}
+
+	obj, ok := dict["custom"].(*Object)
+	if !ok {
+		t.Fatalf("custom is %T, not an *Object", dict["custom"])
+	}
+	if obj.ClassName() != "Document" || !obj.IsKindOf("NSObject") {
+		t.Errorf("unexpected classes %v", obj.Classes)
+	}
+	if obj.Fields["title"] != "Title" || obj.Fields["count"] != int64(3) || obj.Fields["self"] != obj {
+		t.Errorf("unexpected fields %#v", obj.Fields)
+	}
+	if !reflect.DeepEqual(obj.Fields["children"], []interface{}{int64(1), nil}) {
+		t.Errorf("unexpected children %#v", obj.Fields["children"])
+	}
+}
+
+func TestUnarchiveXML(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Archive.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	root, err := Unarchive(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	checkArchive(t, root)
+}
+
+func TestUnarchiveBinary(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Archive.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var v interface{}
+	err = plist.Unmarshal(buf, &v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	buf, err = binaryplist.Marshal(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	root, err := Unarchive(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	checkArchive(t, root)
+}
+
+func TestNotAnArchive(t *testing.T) {
+	buf, err := ioutil.ReadFile("../xmlplist/testdata/Entitlements.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, err = Unarchive(buf)
+	if err == nil || err.Error() != "plist: unsupported archiver <nil>" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package keyedarchive
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/binaryplist"
+	"github.com/mkrautz/plist/internal/cfdate"
+	"github.com/mkrautz/plist/internal/dictkeys"
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+// Marshal archives v as the root object of a new archive, and
+// returns the archive as a binary plist.
+func Marshal(v interface{}) ([]byte, error) {
+	a := NewArchiver()
+	err := a.Encode("root", v)
+	if err != nil {
+		return nil, err
+	}
+	return a.Marshal()
+}
+
+// An Archiver flattens Go values into an NSKeyedArchiver archive.
+//
+// Values are archived as the Foundation classes listed in the package
+// documentation, with *Object values archived under their own classes.
+// Other struct types can be archived once registered with Register.
+//
+// Objects reached through the same pointer, map or slice are archived
+// once and shared, so object graphs may contain cycles.
+type Archiver struct {
+	objects    []interface{}
+	top        map[string]interface{}
+	registered map[reflect.Type][]string
+	classRefs  map[string]plist.UID
+	shared     map[sharedKey]plist.UID
+	unique     map[interface{}]plist.UID
+}
+
+// A sharedKey identifies a value by its address.
+type sharedKey struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// dataKey is used as the key of Archiver.unique for data,
+// which is not comparable.
+type dataKey string
+
+// NewArchiver returns a new, empty archiver.
+func NewArchiver() *Archiver {
+	a := new(Archiver)
+	a.objects = []interface{}{"$null"}
+	a.top = make(map[string]interface{})
+	a.registered = make(map[reflect.Type][]string)
+	a.classRefs = make(map[string]plist.UID)
+	a.shared = make(map[sharedKey]plist.UID)
+	a.unique = make(map[interface{}]plist.UID)
+	return a
+}
+
+// Register makes the archiver archive values of the struct type of v
+// (or of the type v points to) as objects of the given class, followed
+// by its superclasses. If no superclass is given, NSObject is assumed,
+// and if no class is given, the name of the Go type is used.
+// Exported fields are archived under their plist tag, or their name
+// when untagged.
+func (a *Archiver) Register(v interface{}, classes ...string) {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if len(classes) == 0 {
+		classes = []string{t.Name()}
+	}
+	if len(classes) == 1 {
+		classes = append(classes, "NSObject")
+	}
+	a.registered[t] = classes
+}
+
+// Encode archives v as the top-level object named key. Most archives
+// have a single top-level object, named "root".
+func (a *Archiver) Encode(key string, v interface{}) error {
+	ref, err := a.encode(reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	a.top[key] = ref
+	return nil
+}
+
+// Value returns the archive as a plist value, which may be encoded
+// as a plist of any kind. Object references are UIDs.
+func (a *Archiver) Value() map[string]interface{} {
+	return map[string]interface{}{
+		"$archiver": archiverName,
+		"$version":  int64(archiverVersion),
+		"$top":      a.top,
+		"$objects":  a.objects,
+	}
+}
+
+// Marshal returns the archive as a binary plist.
+func (a *Archiver) Marshal() ([]byte, error) {
+	return binaryplist.Marshal(a.Value())
+}
+
+// add adds obj to the archive and returns its reference.
+func (a *Archiver) add(obj interface{}) plist.UID {
+	a.objects = append(a.objects, obj)
+	return plist.UID(len(a.objects) - 1)
+}
+
+// addUnique adds a scalar object, reusing an existing reference
+// to an equal object.
+func (a *Archiver) addUnique(obj interface{}) plist.UID {
+	key := obj
+	if b, ok := obj.([]byte); ok {
+		key = dataKey(b)
+	}
+	if f, ok := obj.(float64); ok && math.IsNaN(f) {
+		return a.add(obj)
+	}
+	if ref, ok := a.unique[key]; ok {
+		return ref
+	}
+	ref := a.add(obj)
+	a.unique[key] = ref
+	return ref
+}
+
+// class returns the reference of the class entry for classes.
+func (a *Archiver) class(classes ...string) plist.UID {
+	if ref, ok := a.classRefs[classes[0]]; ok {
+		return ref
+	}
+	list := make([]interface{}, len(classes))
+	for i, c := range classes {
+		list[i] = c
+	}
+	ref := a.add(map[string]interface{}{
+		"$classname": classes[0],
+		"$classes":   list,
+	})
+	a.classRefs[classes[0]] = ref
+	return ref
+}
+
+// reserve reserves a reference for a shareable value, or returns
+// the reference it was already archived under.
+func (a *Archiver) reserve(key sharedKey) (plist.UID, bool) {
+	if ref, ok := a.shared[key]; ok {
+		return ref, true
+	}
+	ref := a.add(nil)
+	a.shared[key] = ref
+	return ref, false
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(UUID{})
+	nullType = reflect.TypeOf(Null{})
+	setType  = reflect.TypeOf(Set{})
+	urlType  = reflect.TypeOf(&url.URL{})
+	objType  = reflect.TypeOf(&Object{})
+)
+
+// encode archives the value rv and returns its reference.
+func (a *Archiver) encode(rv reflect.Value) (plist.UID, error) {
+	if !rv.IsValid() {
+		return 0, nil
+	}
+
+	switch rv.Type() {
+	case timeType:
+		t := rv.Interface().(time.Time)
+		return a.add(map[string]interface{}{
+			"NS.time": cfdate.Seconds(t),
+			"$class":  a.class("NSDate", "NSObject"),
+		}), nil
+	case uuidType:
+		u := rv.Interface().(UUID)
+		return a.add(map[string]interface{}{
+			"NS.uuidbytes": u[:],
+			"$class":       a.class("NSUUID", "NSObject"),
+		}), nil
+	case nullType:
+		return a.add(map[string]interface{}{
+			"$class": a.class("NSNull", "NSObject"),
+		}), nil
+	case urlType:
+		if rv.IsNil() {
+			return 0, nil
+		}
+		ref, done := a.reserve(sharedKey{rv.Type(), rv.Pointer(), 0})
+		if done {
+			return ref, nil
+		}
+		rel := a.addUnique(rv.Interface().(*url.URL).String())
+		a.objects[ref] = map[string]interface{}{
+			"NS.base":     plist.UID(0),
+			"NS.relative": rel,
+			"$class":      a.class("NSURL", "NSObject"),
+		}
+		return ref, nil
+	case objType:
+		if rv.IsNil() {
+			return 0, nil
+		}
+		obj := rv.Interface().(*Object)
+		if len(obj.Classes) == 0 {
+			return 0, errors.New("plist: cannot archive an Object without classes")
+		}
+		ref, done := a.reserve(sharedKey{rv.Type(), rv.Pointer(), 0})
+		if done {
+			return ref, nil
+		}
+		keys := make([]string, 0, len(obj.Fields))
+		vals := make([]reflect.Value, 0, len(obj.Fields))
+		for _, k := range dictkeys.Sorted(obj.Fields) {
+			keys = append(keys, k)
+			vals = append(vals, reflect.ValueOf(obj.Fields[k]))
+		}
+		return ref, a.encodeObject(ref, obj.Classes, keys, vals)
+	}
+	if classes, ok := a.registered[rv.Type()]; ok {
+		return a.encodeStruct(a.add(nil), classes, rv)
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return a.addUnique(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.addUnique(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u > math.MaxInt64 {
+			return a.addUnique(u), nil
+		}
+		return a.addUnique(int64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return a.addUnique(rv.Float()), nil
+	case reflect.String:
+		return a.addUnique(rv.String()), nil
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return a.addUnique(b), nil
+		}
+		var ref plist.UID
+		if rv.Kind() == reflect.Slice {
+			if rv.IsNil() {
+				return 0, nil
+			}
+			var done bool
+			ref, done = a.reserve(sharedKey{rv.Type(), rv.Pointer(), rv.Len()})
+			if done {
+				return ref, nil
+			}
+		} else {
+			ref = a.add(nil)
+		}
+		refs, err := a.encodeAll(rv)
+		if err != nil {
+			return 0, err
+		}
+		class := a.class("NSArray", "NSObject")
+		if rv.Type() == setType {
+			class = a.class("NSSet", "NSObject")
+		}
+		a.objects[ref] = map[string]interface{}{
+			"NS.objects": refs,
+			"$class":     class,
+		}
+		return ref, nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return 0, nil
+		}
+		ref, done := a.reserve(sharedKey{rv.Type(), rv.Pointer(), 0})
+		if done {
+			return ref, nil
+		}
+		return ref, a.encodeMap(ref, rv)
+	case reflect.Struct:
+		return 0, fmt.Errorf("plist: cannot archive unregistered type %v", rv.Type())
+	case reflect.Interface:
+		if rv.IsNil() {
+			return 0, nil
+		}
+		return a.encode(rv.Elem())
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return 0, nil
+		}
+		if classes, ok := a.registered[rv.Type().Elem()]; ok {
+			ref, done := a.reserve(sharedKey{rv.Type(), rv.Pointer(), 0})
+			if done {
+				return ref, nil
+			}
+			return a.encodeStruct(ref, classes, rv.Elem())
+		}
+		return a.encode(rv.Elem())
+	}
+	return 0, fmt.Errorf("plist: cannot archive %v", rv.Type())
+}
+
+// encodeAll archives the elements of an array or slice.
+func (a *Archiver) encodeAll(rv reflect.Value) ([]interface{}, error) {
+	refs := make([]interface{}, rv.Len())
+	for i := range refs {
+		ref, err := a.encode(rv.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = ref
+	}
+	return refs, nil
+}
+
+// encodeMap archives a map as an NSDictionary at ref. String keys
+// are archived in sorted order.
+func (a *Archiver) encodeMap(ref plist.UID, rv reflect.Value) error {
+	mapKeys := rv.MapKeys()
+	if rv.Type().Key().Kind() == reflect.String {
+		sort.Slice(mapKeys, func(i, j int) bool {
+			return mapKeys[i].String() < mapKeys[j].String()
+		})
+	}
+	keys := make([]interface{}, len(mapKeys))
+	vals := make([]interface{}, len(mapKeys))
+	for i, k := range mapKeys {
+		kr, err := a.encode(k)
+		if err != nil {
+			return err
+		}
+		vr, err := a.encode(rv.MapIndex(k))
+		if err != nil {
+			return err
+		}
+		keys[i] = kr
+		vals[i] = vr
+	}
+	a.objects[ref] = map[string]interface{}{
+		"NS.keys":    keys,
+		"NS.objects": vals,
+		"$class":     a.class("NSDictionary", "NSObject"),
+	}
+	return nil
+}
+
+// encodeStruct archives a struct of a registered type at ref.
+func (a *Archiver) encodeStruct(ref plist.UID, classes []string, rv reflect.Value) (plist.UID, error) {
+	keys, vals := plistreflect.StructEntries(rv)
+	return ref, a.encodeObject(ref, classes, keys, vals)
+}
+
+// encodeObject archives an object with the given fields at ref.
+// Booleans and numbers are stored in the object itself, as
+// NSCoder's encodeBool:forKey: and the like do; all other
+// values are stored as references.
+func (a *Archiver) encodeObject(ref plist.UID, classes []string, keys []string, vals []reflect.Value) error {
+	obj := make(map[string]interface{}, len(keys)+1)
+	for i, k := range keys {
+		v := vals[i]
+		for v.Kind() == reflect.Interface && !v.IsNil() {
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Bool:
+			obj[k] = v.Bool()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			obj[k] = v.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			obj[k] = v.Uint()
+		case reflect.Float32, reflect.Float64:
+			obj[k] = v.Float()
+		default:
+			r, err := a.encode(v)
+			if err != nil {
+				return err
+			}
+			obj[k] = r
+		}
+	}
+	obj["$class"] = a.class(classes...)
+	a.objects[ref] = obj
+	return nil
+}
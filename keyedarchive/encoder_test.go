@@ -0,0 +1,114 @@
+package keyedarchive
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mkrautz/plist"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	u, _ := url.Parse("https://example.com/dir/file.txt")
+	shared := []interface{}{"x"}
+	v := map[string]interface{}{
+		"name":   "hello",
+		"items":  []interface{}{int64(1), float64(2.5), time.Date(2012, 1, 29, 13, 7, 25, 0, time.UTC), []byte{1, 2, 3}},
+		"set":    Set{"a"},
+		"url":    u,
+		"uuid":   UUID{1, 2, 3},
+		"null":   Null{},
+		"nil":    nil,
+		"first":  shared,
+		"second": shared,
+		"object": &Object{
+			Classes: []string{"Document", "NSObject"},
+			Fields:  map[string]interface{}{"title": "Title", "count": int64(3)},
+		},
+	}
+
+	buf, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(buf[:8]) != "bplist00" {
+		t.Fatalf("archive is not a binary plist")
+	}
+	root, err := Unarchive(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(root, v) {
+		t.Fatalf("got %#v, expected %#v", root, v)
+	}
+}
+
+type node struct {
+	Name   string `plist:"name"`
+	Parent *node  `plist:"parent"`
+	Count  int
+	Note   string `plist:"note,omitempty"`
+	hidden int
+}
+
+func TestArchiveRegisteredCycle(t *testing.T) {
+	root := &node{Name: "root", Count: 2}
+	root.Parent = root
+
+	a := NewArchiver()
+	a.Register(root, "Node")
+	err := a.Encode("root", root)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	objects := a.Value()["$objects"].([]interface{})
+	// $null, the node, its name and its class
+	if len(objects) != 4 {
+		t.Fatalf("unexpected objects %#v", objects)
+	}
+	n := objects[1].(map[string]interface{})
+	if n["parent"] != plist.UID(1) || n["Count"] != int64(2) || len(n) != 4 {
+		t.Fatalf("unexpected node %#v", n)
+	}
+
+	buf, err := a.Marshal()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	v, err := Unarchive(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	obj := v.(*Object)
+	if !reflect.DeepEqual(obj.Classes, []string{"Node", "NSObject"}) || obj.Fields["parent"] != obj || obj.Fields["name"] != "root" {
+		t.Fatalf("unexpected object %#v", obj)
+	}
+}
+
+func TestArchiveUnregistered(t *testing.T) {
+	_, err := Marshal(node{})
+	if err == nil || err.Error() != "plist: cannot archive unregistered type keyedarchive.node" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestArchiveXML(t *testing.T) {
+	a := NewArchiver()
+	err := a.Encode("root", []interface{}{"a", "a"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	buf, err := plist.Marshal(a.Value())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	v, err := Unarchive(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(v, []interface{}{"a", "a"}) {
+		t.Fatalf("unexpected root %#v", v)
+	}
+}
@@ -0,0 +1,65 @@
+package keyedarchive
+
+import (
+	"encoding/hex"
+)
+
+// A Set is the contents of an NSSet. Its elements are in
+// the order they were archived.
+type Set []interface{}
+
+// A UUID is the value of an NSUUID.
+type UUID [16]byte
+
+// String returns the UUID in its canonical form,
+// such as 68753A44-4D6F-1226-9C60-0050E4C00067.
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	for i, c := range buf {
+		if 'a' <= c && c <= 'f' {
+			buf[i] = c - 'a' + 'A'
+		}
+	}
+	return string(buf)
+}
+
+// Null is the value of NSNull, which is distinct from a nil
+// reference.
+type Null struct{}
+
+// An Object is an archived object of a class that is not one of
+// the common Foundation classes. Classes holds the name of its class
+// followed by those of its superclasses, and Fields holds the values
+// it archived, keyed by their archive keys.
+type Object struct {
+	Classes []string
+	Fields  map[string]interface{}
+}
+
+// ClassName returns the name of the object's class.
+func (o *Object) ClassName() string {
+	if len(o.Classes) == 0 {
+		return ""
+	}
+	return o.Classes[0]
+}
+
+// IsKindOf reports whether the object is an instance of the named
+// class, or of one of its subclasses.
+func (o *Object) IsKindOf(class string) bool {
+	for _, c := range o.Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
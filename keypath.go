@@ -0,0 +1,580 @@
+package plist
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist/internal/keypath"
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+// Key paths address values nested inside decoded plists, such as
+// "CFBundleURLTypes[0].CFBundleURLSchemes[1]".
+//
+// A key path consists of dict keys separated by dots. An array
+// element is selected either with a bracketed index ("a[2]") or,
+// as with plutil's -extract option, with a numeric component
+// ("a.2"). A backslash escapes a dot, bracket or backslash that is
+// part of a key. The empty key path refers to the value itself.
+//
+// Key paths work on the generic values produced by the decoders
+// (map[string]interface{}, []interface{} and so on) as well as on
+// maps with string keys, slices, arrays and structs. Struct fields
+// are matched by their plist tag, or by their name when untagged.
+
+// ErrNotFound is returned when no value exists at a key path.
+var ErrNotFound = errors.New("no value at key path")
+
+// A KeyPathError records a failed key path operation.
+type KeyPathError struct {
+	Path string
+	Err  error
+}
+
+func (e *KeyPathError) Error() string {
+	return fmt.Sprintf("plist: key path %q: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *KeyPathError) Unwrap() error {
+	return e.Err
+}
+
+// A pathElem is a single component of a key path.
+type pathElem struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseKeyPath parses a key path into its components.
+func parseKeyPath(path string) ([]pathElem, error) {
+	var elems []pathElem
+	var cur []byte
+	pending := path != ""
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if !pending && c != '.' && c != '[' {
+			return nil, errors.New("expected '.' or '[' after index")
+		}
+		switch c {
+		case '\\':
+			if i+1 == len(path) {
+				return nil, errors.New("trailing backslash")
+			}
+			i++
+			cur = append(cur, path[i])
+		case '.':
+			if pending {
+				elems = append(elems, pathElem{key: string(cur)})
+			}
+			cur = nil
+			pending = true
+		case '[':
+			if pending && (len(cur) > 0 || i > 0) {
+				elems = append(elems, pathElem{key: string(cur)})
+			}
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, errors.New("unterminated index")
+			}
+			n, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("bad index %q", path[i+1:i+end])
+			}
+			elems = append(elems, pathElem{index: n, isIndex: true})
+			cur = nil
+			pending = false
+			i += end
+		case ']':
+			return nil, errors.New("unexpected ']'")
+		default:
+			cur = append(cur, c)
+		}
+	}
+	if pending {
+		elems = append(elems, pathElem{key: string(cur)})
+	}
+	return elems, nil
+}
+
+// SplitKeyPath splits a key path into its components, with
+// escapes removed. Array indices are returned in decimal.
+func SplitKeyPath(path string) ([]string, error) {
+	elems, err := parseKeyPath(path)
+	if err != nil {
+		return nil, &KeyPathError{path, err}
+	}
+	comps := make([]string, len(elems))
+	for i, e := range elems {
+		if e.isIndex {
+			comps[i] = strconv.Itoa(e.index)
+		} else {
+			comps[i] = e.key
+		}
+	}
+	return comps, nil
+}
+
+// JoinKeyPath joins key path components, escaping them as needed.
+func JoinKeyPath(comps ...string) string {
+	escaped := make([]string, len(comps))
+	for i, comp := range comps {
+		escaped[i] = keypath.Escape(comp)
+	}
+	return strings.Join(escaped, ".")
+}
+
+// ChildKeyPath returns the key path of the entry key in the dict found
+// at path, which is "" for the root.
+func ChildKeyPath(path, key string) string {
+	return keypath.Child(path, key)
+}
+
+// arrayIndex returns the array index e refers to. Numeric keys
+// act as indices when applied to arrays.
+func (e pathElem) arrayIndex() (int, bool) {
+	if e.isIndex {
+		return e.index, true
+	}
+	if e.key == "" || strings.TrimLeft(e.key, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(e.key)
+	return n, err == nil
+}
+
+// indirect follows pointers and interfaces until it
+// reaches a concrete value.
+func indirect(rv reflect.Value) reflect.Value {
+	for (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// child returns the value found under e in rv.
+func child(rv reflect.Value, e pathElem) (reflect.Value, error) {
+	rv = indirect(rv)
+	switch rv.Kind() {
+	case reflect.Map:
+		if e.isIndex || rv.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, ErrNotFound
+		}
+		v := rv.MapIndex(reflect.ValueOf(e.key).Convert(rv.Type().Key()))
+		if !v.IsValid() {
+			return reflect.Value{}, ErrNotFound
+		}
+		return v, nil
+	case reflect.Slice, reflect.Array:
+		i, ok := e.arrayIndex()
+		if !ok || i >= rv.Len() || rv.Type().Elem().Kind() == reflect.Uint8 {
+			return reflect.Value{}, ErrNotFound
+		}
+		return rv.Index(i), nil
+	case reflect.Struct:
+		if e.isIndex || rv.Type() == timeType {
+			return reflect.Value{}, ErrNotFound
+		}
+		index := plistreflect.FieldIndex(rv.Type(), e.key)
+		if index == nil {
+			return reflect.Value{}, ErrNotFound
+		}
+		return rv.FieldByIndex(index), nil
+	}
+	return reflect.Value{}, ErrNotFound
+}
+
+// lookup returns the value found at path in v.
+func lookup(v interface{}, path string) (reflect.Value, error) {
+	elems, err := parseKeyPath(path)
+	if err != nil {
+		return reflect.Value{}, &KeyPathError{path, err}
+	}
+	rv := reflect.ValueOf(v)
+	for _, e := range elems {
+		rv, err = child(rv, e)
+		if err != nil {
+			return reflect.Value{}, &KeyPathError{path, err}
+		}
+	}
+	rv = indirect(rv)
+	if !rv.IsValid() || ((rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) && rv.IsNil()) {
+		return reflect.Value{}, &KeyPathError{path, ErrNotFound}
+	}
+	return rv, nil
+}
+
+// Get returns the value found at path in v.
+func Get(v interface{}, path string) (interface{}, error) {
+	rv, err := lookup(v, path)
+	if err != nil {
+		return nil, err
+	}
+	return rv.Interface(), nil
+}
+
+// Exists reports whether a value exists at path in v.
+func Exists(v interface{}, path string) bool {
+	_, err := lookup(v, path)
+	return err == nil
+}
+
+// typeError returns the error used by the typed getters
+// when the value at path is of the wrong type.
+func typeError(path string, rv reflect.Value, want string) error {
+	return &KeyPathError{path, fmt.Errorf("value of type %v is not %s", rv.Type(), want)}
+}
+
+// GetString returns the string found at path in v.
+func GetString(v interface{}, path string) (string, error) {
+	rv, err := lookup(v, path)
+	if err != nil {
+		return "", err
+	}
+	if rv.Kind() != reflect.String {
+		return "", typeError(path, rv, "a string")
+	}
+	return rv.String(), nil
+}
+
+// GetInt returns the integer found at path in v.
+func GetInt(v interface{}, path string) (int64, error) {
+	rv, err := lookup(v, path)
+	if err != nil {
+		return 0, err
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= 1<<63-1 {
+			return int64(u), nil
+		}
+		return 0, &KeyPathError{path, fmt.Errorf("integer %v overflows int64", rv.Uint())}
+	}
+	return 0, typeError(path, rv, "an integer")
+}
+
+// GetFloat returns the real number found at path in v.
+func GetFloat(v interface{}, path string) (float64, error) {
+	rv, err := lookup(v, path)
+	if err != nil {
+		return 0, err
+	}
+	if rv.Kind() != reflect.Float32 && rv.Kind() != reflect.Float64 {
+		return 0, typeError(path, rv, "a real")
+	}
+	return rv.Float(), nil
+}
+
+// GetBool returns the boolean found at path in v.
+func GetBool(v interface{}, path string) (bool, error) {
+	rv, err := lookup(v, path)
+	if err != nil {
+		return false, err
+	}
+	if rv.Kind() != reflect.Bool {
+		return false, typeError(path, rv, "a boolean")
+	}
+	return rv.Bool(), nil
+}
+
+// GetDate returns the date found at path in v.
+func GetDate(v interface{}, path string) (time.Time, error) {
+	rv, err := lookup(v, path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if rv.Type() != timeType {
+		return time.Time{}, typeError(path, rv, "a date")
+	}
+	return rv.Interface().(time.Time), nil
+}
+
+// GetData returns the data found at path in v.
+func GetData(v interface{}, path string) ([]byte, error) {
+	rv, err := lookup(v, path)
+	if err != nil {
+		return nil, err
+	}
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() != reflect.Uint8 {
+		return nil, typeError(path, rv, "data")
+	}
+	return rv.Bytes(), nil
+}
+
+// GetArray returns the array found at path in v. Slices and
+// arrays of other element types are copied into a []interface{}.
+func GetArray(v interface{}, path string) ([]interface{}, error) {
+	rv, err := lookup(v, path)
+	if err != nil {
+		return nil, err
+	}
+	if a, ok := rv.Interface().([]interface{}); ok {
+		return a, nil
+	}
+	if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, typeError(path, rv, "an array")
+	}
+	a := make([]interface{}, rv.Len())
+	for i := range a {
+		a[i] = rv.Index(i).Interface()
+	}
+	return a, nil
+}
+
+// GetDict returns the dict found at path in v. Maps with other
+// value types are copied into a map[string]interface{}.
+func GetDict(v interface{}, path string) (map[string]interface{}, error) {
+	rv, err := lookup(v, path)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := rv.Interface().(map[string]interface{}); ok {
+		return m, nil
+	}
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, typeError(path, rv, "a dict")
+	}
+	m := make(map[string]interface{}, rv.Len())
+	for _, k := range rv.MapKeys() {
+		m[k.String()] = rv.MapIndex(k).Interface()
+	}
+	return m, nil
+}
+
+// assignable converts v so it can be stored in a location of type t.
+// Integers and reals are converted between sizes, as long as the
+// value fits.
+func assignable(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n := reflect.New(t).Elem()
+			if !n.OverflowInt(v.Int()) {
+				n.SetInt(v.Int())
+				return n, nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n := reflect.New(t).Elem()
+			if v.Uint() <= 1<<63-1 && !n.OverflowInt(int64(v.Uint())) {
+				n.SetInt(int64(v.Uint()))
+				return n, nil
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n := reflect.New(t).Elem()
+			if v.Int() >= 0 && !n.OverflowUint(uint64(v.Int())) {
+				n.SetUint(uint64(v.Int()))
+				return n, nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n := reflect.New(t).Elem()
+			if !n.OverflowUint(v.Uint()) {
+				n.SetUint(v.Uint())
+				return n, nil
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			return v.Convert(t), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("cannot store %v in %v", v.Type(), t)
+}
+
+// update walks elems in rv and stores val at the location they
+// refer to, or deletes that location if val is invalid. It returns
+// the value that should replace rv in its parent; collections that
+// had to be copied or grown are returned anew.
+func update(rv reflect.Value, elems []pathElem, val reflect.Value) (reflect.Value, error) {
+	if len(elems) == 0 {
+		return val, nil
+	}
+	e := elems[0]
+	last := len(elems) == 1
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.IsNil() {
+			return reflect.Value{}, ErrNotFound
+		}
+		return update(rv.Elem(), elems, val)
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return reflect.Value{}, ErrNotFound
+		}
+		target := rv.Elem()
+		nv, err := update(target, elems, val)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		nv, err = assignable(nv, target.Type())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		target.Set(nv)
+		return rv, nil
+	case reflect.Map:
+		if e.isIndex || rv.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, ErrNotFound
+		}
+		key := reflect.ValueOf(e.key).Convert(rv.Type().Key())
+		cur := rv.MapIndex(key)
+		if last && !val.IsValid() {
+			if !cur.IsValid() {
+				return reflect.Value{}, ErrNotFound
+			}
+			rv.SetMapIndex(key, reflect.Value{})
+			return rv, nil
+		}
+		if !last && !cur.IsValid() {
+			return reflect.Value{}, ErrNotFound
+		}
+		nv, err := update(cur, elems[1:], val)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		nv, err = assignable(nv, rv.Type().Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if rv.IsNil() {
+			rv = reflect.MakeMap(rv.Type())
+		}
+		rv.SetMapIndex(key, nv)
+		return rv, nil
+	case reflect.Slice, reflect.Array:
+		i, ok := e.arrayIndex()
+		if !ok || rv.Type().Elem().Kind() == reflect.Uint8 {
+			return reflect.Value{}, ErrNotFound
+		}
+		if rv.Kind() == reflect.Array && !rv.CanAddr() {
+			cp := reflect.New(rv.Type()).Elem()
+			cp.Set(rv)
+			rv = cp
+		}
+		if last && !val.IsValid() {
+			if rv.Kind() == reflect.Array {
+				return reflect.Value{}, errors.New("cannot delete from a fixed-size array")
+			}
+			if i >= rv.Len() {
+				return reflect.Value{}, ErrNotFound
+			}
+			return reflect.AppendSlice(rv.Slice(0, i), rv.Slice(i+1, rv.Len())), nil
+		}
+		if last && i == rv.Len() && rv.Kind() == reflect.Slice {
+			nv, err := assignable(val, rv.Type().Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.Append(rv, nv), nil
+		}
+		if i >= rv.Len() {
+			return reflect.Value{}, ErrNotFound
+		}
+		nv, err := update(rv.Index(i), elems[1:], val)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		nv, err = assignable(nv, rv.Type().Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		rv.Index(i).Set(nv)
+		return rv, nil
+	case reflect.Struct:
+		if e.isIndex || rv.Type() == timeType {
+			return reflect.Value{}, ErrNotFound
+		}
+		index := plistreflect.FieldIndex(rv.Type(), e.key)
+		if index == nil {
+			return reflect.Value{}, ErrNotFound
+		}
+		if last && !val.IsValid() {
+			return reflect.Value{}, errors.New("cannot delete a struct field")
+		}
+		if !rv.CanAddr() {
+			cp := reflect.New(rv.Type()).Elem()
+			cp.Set(rv)
+			rv = cp
+		}
+		f := rv.FieldByIndex(index)
+		nv, err := update(f, elems[1:], val)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		nv, err = assignable(nv, f.Type())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		f.Set(nv)
+		return rv, nil
+	}
+	return reflect.Value{}, ErrNotFound
+}
+
+// modifyPath stores val at path in v, or deletes the value
+// at path if val is invalid.
+func modifyPath(v interface{}, path string, val reflect.Value) error {
+	elems, err := parseKeyPath(path)
+	if err != nil {
+		return &KeyPathError{path, err}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Ptr && !rv.IsNil():
+		target := rv.Elem()
+		if len(elems) == 0 && !val.IsValid() {
+			return &KeyPathError{path, errors.New("cannot delete the root value")}
+		}
+		nv, err := update(target, elems, val)
+		if err == nil {
+			nv, err = assignable(nv, target.Type())
+		}
+		if err != nil {
+			return &KeyPathError{path, err}
+		}
+		target.Set(nv)
+		return nil
+	case rv.Kind() == reflect.Map && !rv.IsNil() && len(elems) > 0:
+		_, err := update(rv, elems, val)
+		if err != nil {
+			return &KeyPathError{path, err}
+		}
+		return nil
+	}
+	return &KeyPathError{path, errors.New("value must be a non-nil pointer or map")}
+}
+
+// Set stores val at path in v, which must be a pointer or a map.
+// Missing keys of the last dict are created, and an index one past
+// the end of a slice appends to it; all other components of the key
+// path must already exist.
+func Set(v interface{}, path string, val interface{}) error {
+	if val == nil {
+		return &KeyPathError{path, errors.New("cannot store nil")}
+	}
+	return modifyPath(v, path, reflect.ValueOf(val))
+}
+
+// Delete removes the value at path from v, which must be a pointer
+// or a map. Deleting an array element shifts the following elements
+// down.
+func Delete(v interface{}, path string) error {
+	return modifyPath(v, path, reflect.Value{})
+}
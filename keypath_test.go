@@ -0,0 +1,220 @@
+package plist
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestKeyPathGet(t *testing.T) {
+	buf, err := ioutil.ReadFile("xmlplist/testdata/AlfredTimeKeeper.alfredworkflow")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var m map[string]interface{}
+	err = Unmarshal(buf, &m)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	getTests := []struct {
+		Path     string
+		Expected interface{}
+	}{
+		{"bundleid", "com.customct.AlfredTimeKeeper"},
+		{"connections.02659E2A-7ABB-4AFC-A9B9-62ACE375A522[0].destinationuid", "1739AB08-C0AD-47E2-9EE4-64CBE569D4F3"},
+		{"connections.02659E2A-7ABB-4AFC-A9B9-62ACE375A522.0.modifiers", int64(0)},
+	}
+	for _, gt := range getTests {
+		v, err := Get(m, gt.Path)
+		if err != nil {
+			t.Fatalf("%v: %v", gt.Path, err)
+		}
+		if !reflect.DeepEqual(v, gt.Expected) {
+			t.Errorf("%v: got %#v, expected %#v", gt.Path, v, gt.Expected)
+		}
+	}
+
+	_, err = Get(m, "connections.missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if Exists(m, "bundleid.0") {
+		t.Errorf("indexing into a string should not exist")
+	}
+}
+
+func TestKeyPathTypedGetters(t *testing.T) {
+	buf, err := ioutil.ReadFile("xmlplist/testdata/AlfredTimeKeeper.alfredworkflow")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var m map[string]interface{}
+	err = Unmarshal(buf, &m)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	s, err := GetString(m, "bundleid")
+	if err != nil || s != "com.customct.AlfredTimeKeeper" {
+		t.Fatalf("GetString: %q, %v", s, err)
+	}
+	_, err = GetInt(m, "bundleid")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetInt on a string: expected type error, got %v", err)
+	}
+	a, err := GetArray(m, "objects")
+	if err != nil || len(a) == 0 {
+		t.Fatalf("GetArray: %v", err)
+	}
+	_, err = GetDict(m, "objects")
+	if err == nil {
+		t.Fatalf("GetDict on an array: expected error")
+	}
+}
+
+func TestKeyPathEscaping(t *testing.T) {
+	m := map[string]interface{}{
+		"com.apple.security.app-sandbox": true,
+		"a[0]":                           "brackets",
+	}
+	b, err := GetBool(m, `com\.apple\.security\.app-sandbox`)
+	if err != nil || !b {
+		t.Fatalf("escaped dots: %v, %v", b, err)
+	}
+	s, err := GetString(m, JoinKeyPath("a[0]"))
+	if err != nil || s != "brackets" {
+		t.Fatalf("escaped brackets: %q, %v", s, err)
+	}
+
+	comps, err := SplitKeyPath(`a\.b[2].c`)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(comps, []string{"a.b", "2", "c"}) {
+		t.Fatalf("unexpected components %#v", comps)
+	}
+
+	if p := ChildKeyPath("", "a.b"); p != `a\.b` {
+		t.Fatalf("unexpected root child path %q", p)
+	}
+	if p := ChildKeyPath(`a\.b[2]`, "c"); p != `a\.b[2].c` {
+		t.Fatalf("unexpected child path %q", p)
+	}
+
+	for _, bad := range []string{"a[", "a[x]", "a[0]b", `a\`} {
+		if _, err := SplitKeyPath(bad); err == nil {
+			t.Errorf("%q: expected parse error", bad)
+		}
+	}
+}
+
+func TestKeyPathSetDelete(t *testing.T) {
+	var v interface{} = map[string]interface{}{
+		"CFBundleURLTypes": []interface{}{
+			map[string]interface{}{
+				"CFBundleURLSchemes": []interface{}{"one"},
+			},
+		},
+	}
+
+	steps := []struct {
+		Path  string
+		Value interface{}
+	}{
+		{"CFBundleURLTypes[0].CFBundleURLSchemes[1]", "two"},
+		{"CFBundleURLTypes[0].CFBundleURLName", "name"},
+		{"CFBundleVersion", "42"},
+	}
+	for _, s := range steps {
+		err := Set(&v, s.Path, s.Value)
+		if err != nil {
+			t.Fatalf("%v: %v", s.Path, err)
+		}
+	}
+	err := Delete(&v, "CFBundleURLTypes[0].CFBundleURLSchemes[0]")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := map[string]interface{}{
+		"CFBundleURLTypes": []interface{}{
+			map[string]interface{}{
+				"CFBundleURLSchemes": []interface{}{"two"},
+				"CFBundleURLName":    "name",
+			},
+		},
+		"CFBundleVersion": "42",
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("got %#v, expected %#v", v, expected)
+	}
+
+	err = Set(&v, "Missing.Key", "x")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for missing intermediate key, got %v", err)
+	}
+	err = Delete(&v, "Missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound deleting missing key, got %v", err)
+	}
+}
+
+type URLType struct {
+	Name    string   `plist:"CFBundleURLName"`
+	Schemes []string `plist:"CFBundleURLSchemes"`
+}
+
+type Info struct {
+	Version  int       `plist:"CFBundleVersion"`
+	URLTypes []URLType `plist:"CFBundleURLTypes"`
+	Extra    map[string]interface{}
+}
+
+func TestKeyPathStructs(t *testing.T) {
+	info := Info{
+		Version: 1,
+		URLTypes: []URLType{
+			{Name: "web", Schemes: []string{"http", "https"}},
+		},
+	}
+
+	s, err := GetString(info, "CFBundleURLTypes[0].CFBundleURLSchemes[1]")
+	if err != nil || s != "https" {
+		t.Fatalf("GetString: %q, %v", s, err)
+	}
+	n, err := GetInt(info, "CFBundleVersion")
+	if err != nil || n != 1 {
+		t.Fatalf("GetInt: %v, %v", n, err)
+	}
+	a, err := GetArray(info, "CFBundleURLTypes.0.CFBundleURLSchemes")
+	if err != nil || !reflect.DeepEqual(a, []interface{}{"http", "https"}) {
+		t.Fatalf("GetArray: %#v, %v", a, err)
+	}
+
+	err = Set(&info, "CFBundleVersion", int64(42))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = Set(&info, "CFBundleURLTypes[0].CFBundleURLSchemes[2]", "ftp")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = Set(&info, "Extra.key", "value")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if info.Version != 42 || len(info.URLTypes[0].Schemes) != 3 || info.Extra["key"] != "value" {
+		t.Fatalf("unexpected struct after Set: %#v", info)
+	}
+
+	err = Set(&info, "CFBundleVersion", "not a number")
+	if err == nil {
+		t.Fatalf("expected error storing a string in an int field")
+	}
+	err = Set(info, "CFBundleVersion", 1)
+	if err == nil {
+		t.Fatalf("expected error for non-pointer struct")
+	}
+}
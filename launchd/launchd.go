@@ -0,0 +1,303 @@
+// Package launchd models the job definitions of launchd, the property
+// lists found in LaunchAgents and LaunchDaemons directories.
+//
+// Decoding validates a job with the type rules launchctl applies, and
+// reports problems with the line they occur on when the job is an XML
+// plist. Jobs are encoded as XML plists, as launchd expects.
+package launchd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/xmlplist"
+)
+
+// A Job is a launchd job definition. Zero values are left out when
+// encoding.
+type Job struct {
+	Label    string `plist:",omitempty"`
+	Disabled bool   `plist:",omitempty"`
+
+	UserName  string `plist:",omitempty"`
+	GroupName string `plist:",omitempty"`
+
+	// Program is the path of the executable; if empty, the first of
+	// ProgramArguments is used.
+	Program          string   `plist:",omitempty"`
+	ProgramArguments []string `plist:",omitempty"`
+	EnableGlobbing   bool     `plist:",omitempty"`
+
+	EnvironmentVariables map[string]string `plist:",omitempty"`
+	WorkingDirectory     string            `plist:",omitempty"`
+	RootDirectory        string            `plist:",omitempty"`
+	StandardInPath       string            `plist:",omitempty"`
+	StandardOutPath      string            `plist:",omitempty"`
+	StandardErrorPath    string            `plist:",omitempty"`
+	Umask                *Mode             `plist:",omitempty"`
+
+	RunAtLoad             bool              `plist:",omitempty"`
+	KeepAlive             *KeepAlive        `plist:",omitempty"`
+	StartInterval         int               `plist:",omitempty"`
+	StartCalendarInterval CalendarIntervals `plist:",omitempty"`
+	WatchPaths            []string          `plist:",omitempty"`
+	QueueDirectories      []string          `plist:",omitempty"`
+	StartOnMount          bool              `plist:",omitempty"`
+	LaunchOnlyOnce        bool              `plist:",omitempty"`
+
+	ThrottleInterval    int    `plist:",omitempty"`
+	TimeOut             int    `plist:",omitempty"`
+	ExitTimeOut         int    `plist:",omitempty"`
+	Nice                *int   `plist:",omitempty"`
+	ProcessType         string `plist:",omitempty"`
+	AbandonProcessGroup bool   `plist:",omitempty"`
+	LowPriorityIO       bool   `plist:",omitempty"`
+	SessionCreate       bool   `plist:",omitempty"`
+	Debug               bool   `plist:",omitempty"`
+
+	LimitLoadToSessionType Strings                `plist:",omitempty"`
+	MachServices           map[string]MachService `plist:",omitempty"`
+	Sockets                map[string]SocketList  `plist:",omitempty"`
+	SoftResourceLimits     map[string]int         `plist:",omitempty"`
+	HardResourceLimits     map[string]int         `plist:",omitempty"`
+
+	// Other holds the keys not modelled above.
+	Other map[string]interface{} `plist:",unknown"`
+}
+
+// KeepAlive decides whether launchd keeps a job running. If none of
+// the conditions are set, Always decides; otherwise the job is kept
+// alive while any of the conditions holds.
+type KeepAlive struct {
+	Always bool `plist:"-"`
+
+	SuccessfulExit     *bool      `plist:",omitempty"`
+	Crashed            *bool      `plist:",omitempty"`
+	NetworkState       *bool      `plist:",omitempty"`
+	PathState          Conditions `plist:",omitempty"`
+	OtherJobEnabled    Conditions `plist:",omitempty"`
+	AfterInitialDemand Conditions `plist:",omitempty"`
+}
+
+// keepAlive is KeepAlive without its methods, for encoding the dict
+// form.
+type keepAlive KeepAlive
+
+// MarshalPlist returns Always if none of the conditions are set, and
+// the dict of conditions otherwise.
+func (k KeepAlive) MarshalPlist() (interface{}, error) {
+	if k.SuccessfulExit == nil && k.Crashed == nil && k.NetworkState == nil &&
+		len(k.PathState) == 0 && len(k.OtherJobEnabled) == 0 && len(k.AfterInitialDemand) == 0 {
+		return k.Always, nil
+	}
+	return keepAlive(k), nil
+}
+
+// UnmarshalPlist reads a boolean or a dict of conditions.
+func (k *KeepAlive) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	if unmarshal(&k.Always) == nil {
+		return nil
+	}
+	return unmarshal((*keepAlive)(k))
+}
+
+// Conditions map paths or job labels to the state that keeps a job
+// alive.
+type Conditions map[string]bool
+
+// UnmarshalPlist reads a dict. The obsolete boolean form of
+// AfterInitialDemand, which applies to no jobs, is read as nil.
+func (c *Conditions) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	var obsolete bool
+	if unmarshal(&obsolete) == nil {
+		*c = nil
+		return nil
+	}
+	return unmarshal((*map[string]bool)(c))
+}
+
+// A CalendarInterval starts a job at matching times, like a cron
+// entry. Nil fields match any value.
+type CalendarInterval struct {
+	Minute  *int `plist:",omitempty"`
+	Hour    *int `plist:",omitempty"`
+	Day     *int `plist:",omitempty"`
+	Weekday *int `plist:",omitempty"` // 0 and 7 are Sunday
+	Month   *int `plist:",omitempty"`
+}
+
+// CalendarIntervals are the intervals of StartCalendarInterval, which
+// is a single dict or an array of them.
+type CalendarIntervals []CalendarInterval
+
+// MarshalPlist returns a single interval as a dict.
+func (cs CalendarIntervals) MarshalPlist() (interface{}, error) {
+	if len(cs) == 1 {
+		return cs[0], nil
+	}
+	return []CalendarInterval(cs), nil
+}
+
+// UnmarshalPlist reads a dict or an array of dicts.
+func (cs *CalendarIntervals) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	var ci CalendarInterval
+	if unmarshal(&ci) == nil {
+		*cs = CalendarIntervals{ci}
+		return nil
+	}
+	return unmarshal((*[]CalendarInterval)(cs))
+}
+
+// Strings is a string or an array of strings.
+type Strings []string
+
+// MarshalPlist returns a single string as a string.
+func (ss Strings) MarshalPlist() (interface{}, error) {
+	if len(ss) == 1 {
+		return ss[0], nil
+	}
+	return []string(ss), nil
+}
+
+// UnmarshalPlist reads a string or an array of strings.
+func (ss *Strings) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	var s string
+	if unmarshal(&s) == nil {
+		*ss = Strings{s}
+		return nil
+	}
+	return unmarshal((*[]string)(ss))
+}
+
+// A Mode is a file mode creation mask, which may be given as an octal
+// string.
+type Mode int
+
+// UnmarshalPlist reads an integer or an octal string.
+func (m *Mode) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	if unmarshal((*int)(m)) == nil {
+		return nil
+	}
+	var s string
+	err := unmarshal(&s)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.ParseInt(s, 8, 0)
+	if err != nil {
+		return fmt.Errorf("plist: mode %q is not an octal number", s)
+	}
+	*m = Mode(n)
+	return nil
+}
+
+// A MachService is a Mach service a job advertises.
+type MachService struct {
+	ResetAtClose     bool `plist:",omitempty"`
+	HideUntilCheckIn bool `plist:",omitempty"`
+}
+
+// machService is MachService without its methods.
+type machService MachService
+
+// MarshalPlist returns true for a service without options.
+func (ms MachService) MarshalPlist() (interface{}, error) {
+	if ms == (MachService{}) {
+		return true, nil
+	}
+	return machService(ms), nil
+}
+
+// UnmarshalPlist reads true or a dict of options.
+func (ms *MachService) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	var b bool
+	if unmarshal(&b) == nil {
+		*ms = MachService{}
+		return nil
+	}
+	return unmarshal((*machService)(ms))
+}
+
+// A Socket is a socket launchd creates on behalf of a job.
+type Socket struct {
+	SockType            string      `plist:",omitempty"` // stream, dgram or seqpacket
+	SockPassive         *bool       `plist:",omitempty"`
+	SockNodeName        string      `plist:",omitempty"`
+	SockServiceName     ServiceName `plist:",omitempty"`
+	SockFamily          string      `plist:",omitempty"` // IPv4, IPv6, IPv4v6 or Unix
+	SockProtocol        string      `plist:",omitempty"` // TCP or UDP
+	SockPathName        string      `plist:",omitempty"`
+	SockPathMode        *int        `plist:",omitempty"`
+	SecureSocketWithKey string      `plist:",omitempty"`
+	MulticastGroup      string      `plist:",omitempty"`
+	Bonjour             interface{} `plist:",omitempty"` // a bool, a string or an array of strings
+}
+
+// SocketList is the sockets of one entry of Sockets, which is a single
+// dict or an array of them.
+type SocketList []Socket
+
+// MarshalPlist returns a single socket as a dict.
+func (sl SocketList) MarshalPlist() (interface{}, error) {
+	if len(sl) == 1 {
+		return sl[0], nil
+	}
+	return []Socket(sl), nil
+}
+
+// UnmarshalPlist reads a dict or an array of dicts.
+func (sl *SocketList) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	var s Socket
+	if unmarshal(&s) == nil {
+		*sl = SocketList{s}
+		return nil
+	}
+	return unmarshal((*[]Socket)(sl))
+}
+
+// A ServiceName is a service name or a port number.
+type ServiceName string
+
+// UnmarshalPlist reads a string, or a port number given as an integer.
+func (sn *ServiceName) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	var port int64
+	if unmarshal(&port) == nil {
+		*sn = ServiceName(strconv.FormatInt(port, 10))
+		return nil
+	}
+	return unmarshal((*string)(sn))
+}
+
+// Int returns a pointer to n, for the optional fields of jobs.
+func Int(n int) *int {
+	return &n
+}
+
+// Bool returns a pointer to b, for the optional fields of jobs.
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Unmarshal decodes and validates a job definition. If the job is
+// invalid, the error is a ValidationErrors.
+func Unmarshal(data []byte) (*Job, error) {
+	errs, err := Validate(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	j := new(Job)
+	err = plist.Unmarshal(data, j)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Marshal returns the XML plist encoding of j.
+func Marshal(j *Job) ([]byte, error) {
+	return xmlplist.Marshal(j)
+}
@@ -0,0 +1,141 @@
+package launchd
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/com.example.backup.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	j, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := &Job{
+		Label:                "com.example.backup",
+		ProgramArguments:     []string{"/usr/local/bin/backup", "--quiet"},
+		EnvironmentVariables: map[string]string{"BACKUP_DIR": "/Volumes/Backup"},
+		StartCalendarInterval: []CalendarInterval{
+			{Hour: Int(3), Minute: Int(15)},
+			{Weekday: Int(0)},
+		},
+		KeepAlive: &KeepAlive{
+			SuccessfulExit: Bool(false),
+			PathState:      map[string]bool{"/Volumes/Backup": true},
+		},
+		Sockets: map[string]SocketList{
+			"Listeners": {{SockServiceName: "8022", SockFamily: "IPv4"}},
+		},
+		Nice:              Int(10),
+		ProcessType:       "Background",
+		StandardErrorPath: "/var/log/backup.log",
+		Other:             map[string]interface{}{"AssociatedBundleIdentifiers": "com.example.Backup"},
+	}
+	if !reflect.DeepEqual(j, expected) {
+		t.Fatalf("got %#v, expected %#v", j, expected)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	umask := Mode(022)
+	j := &Job{
+		Label:     "com.example.agent",
+		Program:   "/usr/local/bin/agent",
+		RunAtLoad: true,
+		KeepAlive: &KeepAlive{Always: true},
+		StartCalendarInterval: []CalendarInterval{
+			{Minute: Int(0)},
+		},
+		LimitLoadToSessionType: []string{"Aqua"},
+		MachServices:           map[string]MachService{"com.example.agent.xpc": {}},
+		Umask:                  &umask,
+	}
+	if errs := j.Validate(); len(errs) != 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+	buf, err := Marshal(j)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	k, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(j, k) {
+		t.Fatalf("got %#v, expected %#v", k, j)
+	}
+}
+
+func TestDecodeForms(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/com.example.forms.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	j, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	umask := Mode(022)
+	expected := &Job{
+		Label:                  "com.example.forms",
+		Program:                "/usr/local/bin/forms",
+		Umask:                  &umask,
+		KeepAlive:              &KeepAlive{Crashed: Bool(true)},
+		LimitLoadToSessionType: []string{"Aqua", "LoginWindow"},
+		MachServices: map[string]MachService{
+			"com.example.forms.a": {},
+			"com.example.forms.b": {ResetAtClose: true},
+		},
+		Sockets: map[string]SocketList{
+			"Listeners": {{SockServiceName: "ssh"}, {SockServiceName: "80", SockType: "dgram"}},
+		},
+	}
+	if !reflect.DeepEqual(j, expected) {
+		t.Fatalf("got %#v, expected %#v", j, expected)
+	}
+
+	buf, err = Marshal(j)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	k, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(k, expected) {
+		t.Fatalf("got %#v, expected %#v", k, expected)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Invalid.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, err = Unmarshal(buf)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("got %v, expected ValidationErrors", err)
+	}
+
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	expected := []string{
+		"plist: launchd job line 15: KeepAlive: expected boolean or dict, got string",
+		"plist: launchd job line 8: ProgramArguments: expected array of string, got string",
+		"plist: launchd job line 22: Sockets.Listeners[0].SockType: \"raw\" is not one of stream, dgram, seqpacket",
+		"plist: launchd job line 12: StartCalendarInterval.Hour: 24 out of range 0-23",
+		"plist: launchd job line 4: ProgramArguments: neither Program nor ProgramArguments given",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+}
@@ -0,0 +1,356 @@
+package launchd
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/internal/dictkeys"
+	"github.com/mkrautz/plist/internal/typename"
+	"github.com/mkrautz/plist/xmlplist"
+)
+
+// A ValidationError describes a problem with a key of a job.
+type ValidationError struct {
+	Path string // the key path of the value
+	Line int    // the line of the value in an XML plist, or 0
+	Msg  string
+}
+
+func (e ValidationError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("plist: launchd job line %d: %s: %s", e.Line, e.Path, e.Msg)
+	}
+	return fmt.Sprintf("plist: launchd job: %s: %s", e.Path, e.Msg)
+}
+
+// ValidationErrors is the error returned by Unmarshal for invalid
+// jobs.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validate checks a job definition of any plist kind. The returned
+// error reports data that is not a plist at all.
+func Validate(data []byte) (ValidationErrors, error) {
+	v, positions, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return ValidationErrors{{"", positions[""].Line, "job is " + typename.Of(v) + ", not a dict"}}, nil
+	}
+	return validate(dict, positions), nil
+}
+
+// Validate checks j, as it would be encoded.
+func (j *Job) Validate() ValidationErrors {
+	var dict map[string]interface{}
+	buf, err := Marshal(j)
+	if err == nil {
+		err = plist.Unmarshal(buf, &dict)
+	}
+	if err != nil {
+		return ValidationErrors{{"", 0, err.Error()}}
+	}
+	return validate(dict, nil)
+}
+
+// decode decodes a job definition of any plist kind. For XML plists it
+// also returns the positions of the values.
+func decode(data []byte) (interface{}, map[string]xmlplist.Position, error) {
+	var v interface{}
+	kind, err := plist.DetectKind(bytes.NewReader(data))
+	if err == nil && kind == plist.XML {
+		dec := xmlplist.NewDecoder(bytes.NewReader(data))
+		dec.RecordPositions()
+		if dec.Decode(&v) == nil {
+			return v, dec.Positions(), nil
+		}
+		// plist.Unmarshal also reads the encodings xmlplist leaves
+		// to it, such as UTF-16.
+	}
+	err = plist.Unmarshal(data, &v)
+	return v, nil, err
+}
+
+// validate checks a job dict. Positions maps key paths to the
+// positions of the values in an XML plist.
+func validate(dict map[string]interface{}, positions map[string]xmlplist.Position) ValidationErrors {
+	var errs ValidationErrors
+	for _, p := range jobRule.check("", dict) {
+		errs = append(errs, ValidationError{p.path, positions[p.path].Line, p.msg})
+	}
+	if _, ok := dict["Label"]; !ok {
+		errs = append(errs, ValidationError{"Label", positions[""].Line, "missing"})
+	}
+	_, hasProgram := dict["Program"]
+	args, _ := dict["ProgramArguments"].([]interface{})
+	if !hasProgram && len(args) == 0 {
+		errs = append(errs, ValidationError{"ProgramArguments", positions[""].Line, "neither Program nor ProgramArguments given"})
+	}
+	return errs
+}
+
+// A problem is a single violation of a rule.
+type problem struct {
+	path string
+	msg  string
+}
+
+// A rule describes the values allowed for a key.
+type rule struct {
+	name    string                   // the type name, for messages
+	accepts func(v interface{}) bool // whether v has the right type
+	check   func(path string, v interface{}) []problem
+}
+
+func mismatch(path string, r rule, v interface{}) []problem {
+	return []problem{{path, fmt.Sprintf("expected %s, got %s", r.name, typename.Of(v))}}
+}
+
+// scalar returns a rule for values accepted by accepts.
+func scalar(name string, accepts func(v interface{}) bool) rule {
+	r := rule{name: name, accepts: accepts}
+	r.check = func(path string, v interface{}) []problem {
+		if !accepts(v) {
+			return mismatch(path, r, v)
+		}
+		return nil
+	}
+	return r
+}
+
+var (
+	str     = scalar("string", func(v interface{}) bool { _, ok := v.(string); return ok })
+	boolean = scalar("boolean", func(v interface{}) bool { _, ok := v.(bool); return ok })
+	integer = scalar("integer", func(v interface{}) bool { _, ok := v.(int64); return ok })
+)
+
+// intRange allows integers from lo to hi.
+func intRange(lo, hi int64) rule {
+	r := integer
+	r.check = func(path string, v interface{}) []problem {
+		n, ok := v.(int64)
+		if !ok {
+			return mismatch(path, r, v)
+		}
+		if n < lo || n > hi {
+			return []problem{{path, fmt.Sprintf("%d out of range %d-%d", n, lo, hi)}}
+		}
+		return nil
+	}
+	return r
+}
+
+// enum allows the given strings.
+func enum(values ...string) rule {
+	r := str
+	r.check = func(path string, v interface{}) []problem {
+		s, ok := v.(string)
+		if !ok {
+			return mismatch(path, r, v)
+		}
+		for _, val := range values {
+			if s == val {
+				return nil
+			}
+		}
+		return []problem{{path, fmt.Sprintf("%q is not one of %s", s, strings.Join(values, ", "))}}
+	}
+	return r
+}
+
+// arrayOf allows arrays whose elements satisfy elem.
+func arrayOf(elem rule) rule {
+	r := rule{name: "array of " + elem.name}
+	r.accepts = func(v interface{}) bool { _, ok := v.([]interface{}); return ok }
+	r.check = func(path string, v interface{}) []problem {
+		array, ok := v.([]interface{})
+		if !ok {
+			return mismatch(path, r, v)
+		}
+		var ps []problem
+		for i, e := range array {
+			ps = append(ps, elem.check(path+"["+strconv.Itoa(i)+"]", e)...)
+		}
+		return ps
+	}
+	return r
+}
+
+// dictOf allows dicts whose known keys satisfy their rules. Unknown
+// keys are ignored, as launchd ignores them.
+func dictOf(fields map[string]rule) rule {
+	r := rule{name: "dict"}
+	r.accepts = func(v interface{}) bool { _, ok := v.(map[string]interface{}); return ok }
+	r.check = func(path string, v interface{}) []problem {
+		dict, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch(path, r, v)
+		}
+		var ps []problem
+		for _, k := range dictkeys.Sorted(dict) {
+			if fr, ok := fields[k]; ok {
+				ps = append(ps, fr.check(plist.ChildKeyPath(path, k), dict[k])...)
+			}
+		}
+		return ps
+	}
+	return r
+}
+
+// mapOf allows dicts whose values all satisfy elem.
+func mapOf(elem rule) rule {
+	r := rule{name: "dict of " + elem.name}
+	r.accepts = func(v interface{}) bool { _, ok := v.(map[string]interface{}); return ok }
+	r.check = func(path string, v interface{}) []problem {
+		dict, ok := v.(map[string]interface{})
+		if !ok {
+			return mismatch(path, r, v)
+		}
+		var ps []problem
+		for _, k := range dictkeys.Sorted(dict) {
+			ps = append(ps, elem.check(plist.ChildKeyPath(path, k), dict[k])...)
+		}
+		return ps
+	}
+	return r
+}
+
+// either allows values satisfying any of rules. The first rule that
+// accepts the type of a value checks it.
+func either(rules ...rule) rule {
+	names := make([]string, len(rules))
+	for i, r := range rules {
+		names[i] = r.name
+	}
+	r := rule{name: strings.Join(names, " or ")}
+	r.accepts = func(v interface{}) bool {
+		for _, er := range rules {
+			if er.accepts(v) {
+				return true
+			}
+		}
+		return false
+	}
+	r.check = func(path string, v interface{}) []problem {
+		for _, er := range rules {
+			if er.accepts(v) {
+				return er.check(path, v)
+			}
+		}
+		return mismatch(path, r, v)
+	}
+	return r
+}
+
+var (
+	calendarInterval = dictOf(map[string]rule{
+		"Minute":  intRange(0, 59),
+		"Hour":    intRange(0, 23),
+		"Day":     intRange(1, 31),
+		"Weekday": intRange(0, 7),
+		"Month":   intRange(1, 12),
+	})
+
+	socket = dictOf(map[string]rule{
+		"SockType":            enum("stream", "dgram", "seqpacket"),
+		"SockPassive":         boolean,
+		"SockNodeName":        str,
+		"SockServiceName":     either(str, integer),
+		"SockFamily":          enum("IPv4", "IPv6", "IPv4v6", "Unix"),
+		"SockProtocol":        enum("TCP", "UDP"),
+		"SockPathName":        str,
+		"SockPathOwner":       integer,
+		"SockPathGroup":       integer,
+		"SockPathMode":        integer,
+		"SecureSocketWithKey": str,
+		"Bonjour":             either(boolean, str, arrayOf(str)),
+		"MulticastGroup":      str,
+	})
+
+	resourceLimits = dictOf(map[string]rule{
+		"Core":              integer,
+		"CPU":               integer,
+		"Data":              integer,
+		"FileSize":          integer,
+		"MemoryLock":        integer,
+		"NumberOfFiles":     integer,
+		"NumberOfProcesses": integer,
+		"ResidentSetSize":   integer,
+		"Stack":             integer,
+	})
+
+	jobRule = dictOf(map[string]rule{
+		"Label":                  str,
+		"Disabled":               boolean,
+		"UserName":               str,
+		"GroupName":              str,
+		"InitGroups":             boolean,
+		"Program":                str,
+		"ProgramArguments":       arrayOf(str),
+		"EnableGlobbing":         boolean,
+		"EnableTransactions":     boolean,
+		"EnablePressuredExit":    boolean,
+		"OnDemand":               boolean,
+		"ServiceIPC":             boolean,
+		"inetdCompatibility":     dictOf(map[string]rule{"Wait": boolean}),
+		"LimitLoadToHosts":       arrayOf(str),
+		"LimitLoadFromHosts":     arrayOf(str),
+		"LimitLoadToSessionType": either(str, arrayOf(str)),
+		"LimitLoadToHardware":    mapOf(arrayOf(str)),
+		"LimitLoadFromHardware":  mapOf(arrayOf(str)),
+		"KeepAlive": either(boolean, dictOf(map[string]rule{
+			"SuccessfulExit":     boolean,
+			"NetworkState":       boolean,
+			"Crashed":            boolean,
+			"PathState":          mapOf(boolean),
+			"OtherJobEnabled":    mapOf(boolean),
+			"AfterInitialDemand": either(boolean, mapOf(boolean)),
+		})),
+		"RunAtLoad":                   boolean,
+		"RootDirectory":               str,
+		"WorkingDirectory":            str,
+		"EnvironmentVariables":        mapOf(str),
+		"Umask":                       either(integer, str),
+		"TimeOut":                     integer,
+		"ExitTimeOut":                 integer,
+		"ThrottleInterval":            integer,
+		"StartInterval":               intRange(1, 1<<31-1),
+		"StartCalendarInterval":       either(calendarInterval, arrayOf(calendarInterval)),
+		"StandardInPath":              str,
+		"StandardOutPath":             str,
+		"StandardErrorPath":           str,
+		"Debug":                       boolean,
+		"WaitForDebugger":             boolean,
+		"SoftResourceLimits":          resourceLimits,
+		"HardResourceLimits":          resourceLimits,
+		"Nice":                        intRange(-20, 20),
+		"ProcessType":                 enum("Background", "Standard", "Adaptive", "Interactive"),
+		"AbandonProcessGroup":         boolean,
+		"LowPriorityIO":               boolean,
+		"LowPriorityBackgroundIO":     boolean,
+		"MaterializeDatalessFiles":    boolean,
+		"LaunchOnlyOnce":              boolean,
+		"SessionCreate":               boolean,
+		"WatchPaths":                  arrayOf(str),
+		"QueueDirectories":            arrayOf(str),
+		"StartOnMount":                boolean,
+		"AssociatedBundleIdentifiers": either(str, arrayOf(str)),
+		"MachServices": mapOf(either(boolean, dictOf(map[string]rule{
+			"ResetAtClose":     boolean,
+			"HideUntilCheckIn": boolean,
+		}))),
+		"Sockets": mapOf(either(socket, arrayOf(socket))),
+	})
+)
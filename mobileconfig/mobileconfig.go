@@ -0,0 +1,215 @@
+// Package mobileconfig builds and parses configuration profiles
+// (.mobileconfig files), the plists MDM servers and Apple
+// Configurator use to configure devices.
+//
+// A profile is a dict whose PayloadContent array holds payload dicts,
+// each identified by its PayloadType. Decoding dispatches on the
+// payload type to the struct registered for it, so payloads arrive as
+// *WiFi, *Passcode and so on; payloads of unregistered types arrive as
+// *Generic. Signed profiles are unwrapped without verifying their
+// signature.
+package mobileconfig
+
+import (
+	"crypto/rand"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/provisioning"
+	"github.com/mkrautz/plist/xmlplist"
+)
+
+// A Header holds the keys common to profiles and payloads.
+type Header struct {
+	PayloadType         string `plist:",omitempty,required"`
+	PayloadVersion      int    `plist:",omitempty,required"`
+	PayloadIdentifier   string `plist:",omitempty,required"`
+	PayloadUUID         string `plist:",omitempty,required"`
+	PayloadDisplayName  string `plist:",omitempty"`
+	PayloadDescription  string `plist:",omitempty"`
+	PayloadOrganization string `plist:",omitempty"`
+
+	// Other holds the keys not modelled by the payload's struct.
+	Other map[string]interface{} `plist:",unknown"`
+}
+
+// PayloadHeader returns h. It makes every struct that embeds a Header
+// a Payload.
+func (h *Header) PayloadHeader() *Header {
+	return h
+}
+
+// A Payload is a single payload of a profile: a pointer to a struct
+// that embeds a Header.
+type Payload interface {
+	PayloadHeader() *Header
+}
+
+// A Generic is a payload of an unregistered type. All of its keys
+// besides the header are kept in Other.
+type Generic struct {
+	Header
+}
+
+// A Profile is a configuration profile.
+type Profile struct {
+	Header
+	PayloadRemovalDisallowed bool                   `plist:",omitempty"`
+	PayloadScope             string                 `plist:",omitempty"`
+	ConsentText              map[string]interface{} `plist:",omitempty"`
+
+	Content Payloads `plist:"PayloadContent"`
+}
+
+// Payloads are the payloads of a profile.
+type Payloads []Payload
+
+// UnmarshalPlist reads an array of payload dicts, each into a new
+// value of the struct registered for its payload type.
+func (ps *Payloads) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	var content []payload
+	err := unmarshal(&content)
+	if err != nil {
+		return err
+	}
+	*ps = make(Payloads, len(content))
+	for i, pl := range content {
+		(*ps)[i] = pl.Payload
+	}
+	return nil
+}
+
+// payload is a Payload that decodes into the struct registered for its
+// payload type.
+type payload struct {
+	Payload
+}
+
+// UnmarshalPlist reads the payload type and then the payload.
+func (pl *payload) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	var h struct {
+		PayloadType string
+	}
+	err := unmarshal(&h)
+	if err != nil {
+		return err
+	}
+	rt, ok := types[h.PayloadType]
+	if !ok {
+		rt = reflect.TypeOf(Generic{})
+	}
+	pv := reflect.New(rt)
+	err = unmarshal(pv.Interface())
+	if err != nil {
+		return fmt.Errorf("plist: mobileconfig payload %s: %v", h.PayloadType, err)
+	}
+	pl.Payload = pv.Interface().(Payload)
+	return nil
+}
+
+// ProfileType is the PayloadType of profiles.
+const ProfileType = "Configuration"
+
+// NewProfile returns an empty profile with the given identifier, such
+// as "com.example.wifi", and a fresh UUID.
+func NewProfile(identifier, displayName string) *Profile {
+	p := new(Profile)
+	p.PayloadType = ProfileType
+	p.PayloadVersion = 1
+	p.PayloadIdentifier = identifier
+	p.PayloadUUID = NewUUID()
+	p.PayloadDisplayName = displayName
+	return p
+}
+
+// Add adds pl to the profile. Missing header keys are filled in: the
+// payload type from the registry, version 1, a fresh UUID, and an
+// identifier made of the profile's identifier and the payload type.
+func (p *Profile) Add(pl Payload) {
+	h := pl.PayloadHeader()
+	if h.PayloadType == "" {
+		h.PayloadType = typeNames[reflect.TypeOf(pl).Elem()]
+	}
+	if h.PayloadVersion == 0 {
+		h.PayloadVersion = 1
+	}
+	if h.PayloadUUID == "" {
+		h.PayloadUUID = NewUUID()
+	}
+	if h.PayloadIdentifier == "" {
+		base := p.PayloadIdentifier + "." + h.PayloadType
+		id := base
+		for n := 2; p.hasIdentifier(id); n++ {
+			id = base + "." + strconv.Itoa(n)
+		}
+		h.PayloadIdentifier = id
+	}
+	p.Content = append(p.Content, pl)
+}
+
+func (p *Profile) hasIdentifier(id string) bool {
+	for _, pl := range p.Content {
+		if pl.PayloadHeader().PayloadIdentifier == id {
+			return true
+		}
+	}
+	return false
+}
+
+// NewUUID returns a random UUID in the uppercase form profiles use.
+func NewUUID() string {
+	var u [16]byte
+	_, err := rand.Read(u[:])
+	if err != nil {
+		panic("plist: unable to generate UUID: " + err.Error())
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// The registered payload types.
+var (
+	types     = make(map[string]reflect.Type)
+	typeNames = make(map[reflect.Type]string)
+)
+
+// Register makes payloads of the given type decode into new values of
+// the type of sample, which must be a pointer to a struct embedding
+// Header. A struct may be registered for several payload types; Add
+// uses the first.
+func Register(payloadType string, sample Payload) {
+	rt := reflect.TypeOf(sample)
+	if rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Struct {
+		panic("plist: mobileconfig payload must be a pointer to a struct")
+	}
+	types[payloadType] = rt.Elem()
+	if _, ok := typeNames[rt.Elem()]; !ok {
+		typeNames[rt.Elem()] = payloadType
+	}
+}
+
+// Unmarshal parses a profile of any plist kind, signed or not.
+func Unmarshal(data []byte) (*Profile, error) {
+	if len(data) > 0 && data[0] == 0x30 {
+		// A BER or DER SEQUENCE: CMS signed data.
+		content, err := provisioning.Extract(data)
+		if err != nil {
+			return nil, err
+		}
+		data = content
+	}
+	p := new(Profile)
+	err := plist.Unmarshal(data, p)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Marshal returns the XML plist encoding of p.
+func Marshal(p *Profile) ([]byte, error) {
+	return xmlplist.Marshal(p)
+}
@@ -0,0 +1,168 @@
+package mobileconfig
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	for _, name := range []string{"WiFi.mobileconfig", "Signed.mobileconfig", "SignedIndefinite.mobileconfig"} {
+		buf, err := ioutil.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		p, err := Unmarshal(buf)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if p.PayloadIdentifier != "com.example.office" || len(p.Content) != 3 {
+			t.Fatalf("%s: got %#v", name, p)
+		}
+		wifi, ok := p.Content[0].(*WiFi)
+		if !ok {
+			t.Fatalf("%s: got %T, expected *WiFi", name, p.Content[0])
+		}
+		if wifi.SSID != "Example Office" || wifi.AutoJoin == nil || !*wifi.AutoJoin {
+			t.Fatalf("%s: got %#v", name, wifi)
+		}
+		if !reflect.DeepEqual(wifi.Other, map[string]interface{}{"IsHotspot": false}) {
+			t.Fatalf("%s: got other keys %#v", name, wifi.Other)
+		}
+		passcode, ok := p.Content[1].(*Passcode)
+		if !ok || passcode.MinLength != 6 || *passcode.AllowSimple {
+			t.Fatalf("%s: got %#v", name, p.Content[1])
+		}
+		generic, ok := p.Content[2].(*Generic)
+		if !ok || generic.Other["ServerURL"] != "https://mdm.example.com" {
+			t.Fatalf("%s: got %#v", name, p.Content[2])
+		}
+		if errs := p.Validate(); len(errs) != 0 {
+			t.Fatalf("%s: unexpected validation errors: %v", name, errs)
+		}
+	}
+}
+
+// A Settings is a custom payload type.
+type Settings struct {
+	Header
+	ServerURL string `plist:"ServerURL,required"`
+}
+
+func TestBuild(t *testing.T) {
+	Register("com.example.settings", (*Settings)(nil))
+	defer delete(types, "com.example.settings")
+
+	p := NewProfile("com.example.office", "Example Office")
+	p.Add(&WiFi{SSID: "Example Office", EncryptionType: "WPA3", Password: "secret"})
+	p.Add(&WiFi{SSID: "Example Guest", EncryptionType: "None"})
+	p.Add(&Settings{ServerURL: "https://mdm.example.com"})
+	p.Add(&DNSSettings{DNSSettings: DNSServer{DNSProtocol: "HTTPS", ServerURL: "https://dns.example.com/dns-query"}})
+	if errs := p.Validate(); len(errs) != 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+
+	var ids []string
+	for _, pl := range p.Content {
+		ids = append(ids, pl.PayloadHeader().PayloadIdentifier)
+	}
+	expected := []string{
+		"com.example.office.com.apple.wifi.managed",
+		"com.example.office.com.apple.wifi.managed.2",
+		"com.example.office.com.example.settings",
+		"com.example.office.com.apple.dnsSettings.managed",
+	}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("got %q, expected %q", ids, expected)
+	}
+
+	buf, err := Marshal(p)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	q, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(p, q) {
+		t.Fatalf("got %#v, expected %#v", q, p)
+	}
+}
+
+// A Relay is a custom payload type with nested structs.
+type Relay struct {
+	Header
+	Servers []Server `plist:",omitempty,required"`
+}
+
+// A Server is a server of a Relay.
+type Server struct {
+	Address string
+	Port    int32
+	Weight  float32 `plist:",omitempty"`
+}
+
+func TestBuildNested(t *testing.T) {
+	Register("com.example.relay", (*Relay)(nil))
+	defer delete(types, "com.example.relay")
+
+	p := NewProfile("com.example.office", "Example Office")
+	p.Add(&Relay{Servers: []Server{{"relay1.example.com", 443, 0.5}, {"relay2.example.com", 8443, 0}}})
+	buf, err := Marshal(p)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	q, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(p, q) {
+		t.Fatalf("got %#v, expected %#v", q, p)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/WiFi.mobileconfig")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	p, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	wifi := p.Content[0].(*WiFi)
+	wifi.SSID = ""
+	wifi.EncryptionType = "WPA4"
+	passcode := p.Content[1].(*Passcode)
+	passcode.PayloadUUID = wifi.PayloadUUID
+	passcode.MinLength = 20
+	p.Content[2].PayloadHeader().PayloadUUID = "not-a-uuid"
+
+	var got []string
+	for _, err := range p.Validate() {
+		got = append(got, err.Error())
+	}
+	expected := []string{
+		"plist: mobileconfig payload com.example.office.wifi: SSID_STR: missing",
+		"plist: mobileconfig payload com.example.office.wifi: EncryptionType: unknown encryption type WPA4",
+		"plist: mobileconfig payload com.example.office.passcode: PayloadUUID: duplicate 5B4E7B4A-1C2D-4E5F-8A9B-0C1D2E3F4A5B",
+		"plist: mobileconfig payload com.example.office.passcode: minLength: must be between 0 and 16",
+		"plist: mobileconfig payload com.example.office.settings: PayloadUUID: malformed UUID not-a-uuid",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestDecodeWrongType(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/WiFi.mobileconfig")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	bad := strings.Replace(string(buf), "<integer>6</integer>", "<string>6</string>", 1)
+	_, err = Unmarshal([]byte(bad))
+	if err == nil || !strings.Contains(err.Error(), "payload com.apple.mobiledevice.passwordpolicy: plist: cannot read string into int") {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package mobileconfig
+
+// WiFi configures a Wi-Fi network (com.apple.wifi.managed).
+type WiFi struct {
+	Header
+	SSID                   string                 `plist:"SSID_STR,omitempty,required"`
+	HiddenNetwork          bool                   `plist:"HIDDEN_NETWORK,omitempty"`
+	AutoJoin               *bool                  `plist:",omitempty"`
+	EncryptionType         string                 `plist:",omitempty"` // WEP, WPA, WPA2, WPA3, Any or None
+	Password               string                 `plist:",omitempty"`
+	ProxyType              string                 `plist:",omitempty"` // None, Manual or Auto
+	ProxyServer            string                 `plist:",omitempty"`
+	ProxyServerPort        int                    `plist:",omitempty"`
+	ProxyPACURL            string                 `plist:",omitempty"`
+	EAPClientConfiguration map[string]interface{} `plist:",omitempty"`
+}
+
+// Passcode sets the passcode policy (com.apple.mobiledevice.passwordpolicy).
+type Passcode struct {
+	Header
+	AllowSimple         *bool `plist:"allowSimple,omitempty"`
+	ForcePIN            bool  `plist:"forcePIN,omitempty"`
+	RequireAlphanumeric bool  `plist:"requireAlphanumeric,omitempty"`
+	MinLength           int   `plist:"minLength,omitempty"`
+	MinComplexChars     int   `plist:"minComplexChars,omitempty"`
+	MaxFailedAttempts   int   `plist:"maxFailedAttempts,omitempty"`
+	MaxInactivity       int   `plist:"maxInactivity,omitempty"` // in minutes
+	MaxPINAgeInDays     int   `plist:"maxPINAgeInDays,omitempty"`
+	PinHistory          int   `plist:"pinHistory,omitempty"`
+}
+
+// Restrictions restricts device features (com.apple.applicationaccess).
+// The features are allowed unless set to false.
+type Restrictions struct {
+	Header
+	AllowAppInstallation *bool `plist:"allowAppInstallation,omitempty"`
+	AllowCamera          *bool `plist:"allowCamera,omitempty"`
+	AllowCloudBackup     *bool `plist:"allowCloudBackup,omitempty"`
+	AllowSafari          *bool `plist:"allowSafari,omitempty"`
+	AllowScreenShot      *bool `plist:"allowScreenShot,omitempty"`
+	ForceEncryptedBackup bool  `plist:"forceEncryptedBackup,omitempty"`
+}
+
+// Certificate installs a certificate or identity
+// (com.apple.security.pkcs12, .root, .pkcs1 and .pem).
+type Certificate struct {
+	Header
+	PayloadCertificateFileName string `plist:",omitempty"`
+	PayloadContent             []byte `plist:",omitempty,required"`
+	Password                   string `plist:",omitempty"` // for PKCS #12 identities
+}
+
+// WebClip adds a web clip to the home screen (com.apple.webClip.managed).
+type WebClip struct {
+	Header
+	Label       string `plist:",omitempty,required"`
+	URL         string `plist:",omitempty,required"`
+	Icon        []byte `plist:",omitempty"`
+	IsRemovable *bool  `plist:",omitempty"`
+	FullScreen  bool   `plist:",omitempty"`
+	Precomposed bool   `plist:",omitempty"`
+}
+
+// DNSSettings configures encrypted DNS (com.apple.dnsSettings.managed).
+type DNSSettings struct {
+	Header
+	DNSSettings DNSServer `plist:",omitempty,required"`
+}
+
+// A DNSServer is an encrypted DNS server.
+type DNSServer struct {
+	DNSProtocol     string   `plist:",omitempty"` // HTTPS or TLS
+	ServerURL       string   `plist:",omitempty"` // for HTTPS
+	ServerName      string   `plist:",omitempty"` // for TLS
+	ServerAddresses []string `plist:",omitempty"`
+}
+
+func init() {
+	Register("com.apple.wifi.managed", (*WiFi)(nil))
+	Register("com.apple.mobiledevice.passwordpolicy", (*Passcode)(nil))
+	Register("com.apple.applicationaccess", (*Restrictions)(nil))
+	Register("com.apple.security.pkcs12", (*Certificate)(nil))
+	Register("com.apple.security.root", (*Certificate)(nil))
+	Register("com.apple.security.pkcs1", (*Certificate)(nil))
+	Register("com.apple.security.pem", (*Certificate)(nil))
+	Register("com.apple.webClip.managed", (*WebClip)(nil))
+	Register("com.apple.dnsSettings.managed", (*DNSSettings)(nil))
+}
+
+// Validate checks the encryption type and proxy settings.
+func (w *WiFi) Validate() []string {
+	var msgs []string
+	if !oneOf(w.EncryptionType, "", "WEP", "WPA", "WPA2", "WPA3", "Any", "None") {
+		msgs = append(msgs, "EncryptionType: unknown encryption type "+w.EncryptionType)
+	}
+	if w.ProxyType == "Manual" && w.ProxyServer == "" {
+		msgs = append(msgs, "ProxyServer: required for manual proxies")
+	}
+	return msgs
+}
+
+// Validate checks the passcode length limits.
+func (p *Passcode) Validate() []string {
+	var msgs []string
+	if p.MinLength < 0 || p.MinLength > 16 {
+		msgs = append(msgs, "minLength: must be between 0 and 16")
+	}
+	if p.MinComplexChars < 0 || p.MinComplexChars > 4 {
+		msgs = append(msgs, "minComplexChars: must be between 0 and 4")
+	}
+	if p.MaxFailedAttempts != 0 && (p.MaxFailedAttempts < 2 || p.MaxFailedAttempts > 11) {
+		msgs = append(msgs, "maxFailedAttempts: must be between 2 and 11")
+	}
+	return msgs
+}
+
+// Validate checks that the server matches the protocol.
+func (d *DNSSettings) Validate() []string {
+	switch d.DNSSettings.DNSProtocol {
+	case "HTTPS":
+		if d.DNSSettings.ServerURL == "" {
+			return []string{"DNSSettings.ServerURL: required for HTTPS"}
+		}
+	case "TLS":
+		if d.DNSSettings.ServerName == "" {
+			return []string{"DNSSettings.ServerName: required for TLS"}
+		}
+	default:
+		return []string{"DNSSettings.DNSProtocol: must be HTTPS or TLS"}
+	}
+	return nil
+}
+
+func oneOf(s string, values ...string) bool {
+	for _, v := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
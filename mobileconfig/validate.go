@@ -0,0 +1,105 @@
+package mobileconfig
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+// A ValidationError describes a problem with a profile or one of its
+// payloads.
+type ValidationError struct {
+	Payload string // the payload's identifier, or "" for the profile
+	Msg     string
+}
+
+func (e ValidationError) Error() string {
+	if e.Payload == "" {
+		return "plist: mobileconfig profile: " + e.Msg
+	}
+	return fmt.Sprintf("plist: mobileconfig payload %s: %s", e.Payload, e.Msg)
+}
+
+// A Validator is a payload that checks its own fields. Validate
+// returns a message for each problem found.
+type Validator interface {
+	Validate() []string
+}
+
+var uuidFormat = regexp.MustCompile(`^[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}$`)
+
+// Validate checks p and its payloads: required keys must be set, UUIDs
+// must be well-formed and, like identifiers, unique, and payloads
+// implementing Validator must pass their own checks.
+func (p *Profile) Validate() []ValidationError {
+	var errs []ValidationError
+	if p.PayloadType != ProfileType {
+		errs = append(errs, ValidationError{"", fmt.Sprintf("PayloadType: must be %s", ProfileType)})
+	}
+	for _, msg := range checkPayload(p) {
+		errs = append(errs, ValidationError{"", msg})
+	}
+
+	uuids := map[string]bool{strings.ToUpper(p.PayloadUUID): true}
+	ids := map[string]bool{p.PayloadIdentifier: true}
+	for i, pl := range p.Content {
+		h := pl.PayloadHeader()
+		name := h.PayloadIdentifier
+		if name == "" {
+			name = fmt.Sprintf("%d", i)
+		}
+		msgs := checkPayload(pl)
+		if h.PayloadType == ProfileType {
+			msgs = append(msgs, "PayloadType: profiles cannot be nested")
+		}
+		if uuids[strings.ToUpper(h.PayloadUUID)] && h.PayloadUUID != "" {
+			msgs = append(msgs, "PayloadUUID: duplicate "+h.PayloadUUID)
+		}
+		if ids[h.PayloadIdentifier] && h.PayloadIdentifier != "" {
+			msgs = append(msgs, "PayloadIdentifier: duplicate")
+		}
+		uuids[strings.ToUpper(h.PayloadUUID)] = true
+		ids[h.PayloadIdentifier] = true
+		if v, ok := pl.(Validator); ok {
+			msgs = append(msgs, v.Validate()...)
+		}
+		for _, msg := range msgs {
+			errs = append(errs, ValidationError{name, msg})
+		}
+	}
+	return errs
+}
+
+// checkPayload checks the header and required fields of pl.
+func checkPayload(pl Payload) []string {
+	var msgs []string
+	h := pl.PayloadHeader()
+	if h.PayloadUUID != "" && !uuidFormat.MatchString(h.PayloadUUID) {
+		msgs = append(msgs, "PayloadUUID: malformed UUID "+h.PayloadUUID)
+	}
+	var required func(rv reflect.Value)
+	required = func(rv reflect.Value) {
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			f := rt.Field(i)
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				required(rv.Field(i))
+				continue
+			}
+			key, skip := plistreflect.FieldName(f)
+			if !skip && plistreflect.HasOption(f, "required") && isZero(rv.Field(i)) {
+				msgs = append(msgs, key+": missing")
+			}
+		}
+	}
+	required(reflect.ValueOf(pl).Elem())
+	return msgs
+}
+
+// isZero reports whether rv holds the zero value of its type.
+func isZero(rv reflect.Value) bool {
+	return reflect.DeepEqual(rv.Interface(), reflect.Zero(rv.Type()).Interface())
+}
@@ -0,0 +1,188 @@
+package pbxproj
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/mkrautz/plist/internal/asciilex"
+)
+
+// A parser holds the state of parsing a single project file.
+type parser struct {
+	lex *asciilex.Lexer
+}
+
+func errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("plist: pbxproj line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// projectComment matches the comment Xcode writes after references
+// to the project's build configuration list.
+var projectComment = regexp.MustCompile(`/\* Build configuration list for PBXProject "((?:[^"*]|\*[^/])*)" \*/`)
+
+// parse parses the text of a project file.
+func parse(text string) (*File, error) {
+	p := &parser{lex: asciilex.New(text)}
+	p.lex.AllowNonASCII()
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.value(tok)
+	if err != nil {
+		return nil, err
+	}
+	tok, err = p.next()
+	if err != nil {
+		return nil, err
+	}
+	if tok.Kind != asciilex.EOF {
+		return nil, errorf(tok.Line, "unexpected %s after root dict", describe(tok))
+	}
+	root, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errorf(1, "root is not a dict")
+	}
+
+	f := &File{Objects: make(map[string]*Object)}
+	f.ArchiveVersion, _ = root["archiveVersion"].(string)
+	f.ObjectVersion, _ = root["objectVersion"].(string)
+	f.Classes, _ = root["classes"].(map[string]interface{})
+	f.RootObject, _ = root["rootObject"].(string)
+	objects, ok := root["objects"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("plist: pbxproj has no objects dict")
+	}
+	for id, v := range objects {
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("plist: pbxproj object %s is not a dict", id)
+		}
+		isa, ok := fields["isa"].(string)
+		if !ok {
+			return nil, fmt.Errorf("plist: pbxproj object %s has no isa", id)
+		}
+		delete(fields, "isa")
+		f.Objects[id] = &Object{ID: id, Isa: isa, Fields: fields, file: f}
+	}
+	if f.Object(f.RootObject) == nil {
+		return nil, fmt.Errorf("plist: pbxproj root object %q not found", f.RootObject)
+	}
+	if m := projectComment.FindStringSubmatch(text); m != nil {
+		f.Name = m[1]
+	}
+	return f, nil
+}
+
+// next returns the next token that is not a comment.
+func (p *parser) next() (asciilex.Token, error) {
+	for {
+		tok, err := p.lex.Next()
+		if err != nil {
+			if e, ok := err.(*asciilex.Error); ok {
+				return tok, errorf(e.Line, "%s", e.Msg)
+			}
+			return tok, err
+		}
+		if tok.Kind != asciilex.Comment {
+			return tok, nil
+		}
+	}
+}
+
+// describe describes tok for an error message.
+func describe(tok asciilex.Token) string {
+	switch tok.Kind {
+	case asciilex.EOF:
+		return "end of file"
+	case asciilex.String:
+		return strconv.Quote(tok.Text)
+	case asciilex.Data:
+		return "data"
+	}
+	return "'" + tok.Text + "'"
+}
+
+// isPunct reports whether tok is the punctuation character c.
+func isPunct(tok asciilex.Token, c string) bool {
+	return tok.Kind == asciilex.Punct && tok.Text == c
+}
+
+// expect reads the next token, which must be c.
+func (p *parser) expect(c string, context string) error {
+	tok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if !isPunct(tok, c) {
+		return errorf(tok.Line, "expected '%s' %s", c, context)
+	}
+	return nil
+}
+
+// value reads the string, array or dict that starts with tok.
+func (p *parser) value(tok asciilex.Token) (interface{}, error) {
+	switch {
+	case isPunct(tok, "{"):
+		dict := make(map[string]interface{})
+		for {
+			tok, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if isPunct(tok, "}") {
+				return dict, nil
+			}
+			if tok.Kind != asciilex.String {
+				return nil, errorf(tok.Line, "unexpected %s as dict key", describe(tok))
+			}
+			key := tok.Text
+			err = p.expect("=", fmt.Sprintf("after key %q", key))
+			if err != nil {
+				return nil, err
+			}
+			tok, err = p.next()
+			if err != nil {
+				return nil, err
+			}
+			dict[key], err = p.value(tok)
+			if err != nil {
+				return nil, err
+			}
+			err = p.expect(";", fmt.Sprintf("after value of %q", key))
+			if err != nil {
+				return nil, err
+			}
+		}
+	case isPunct(tok, "("):
+		array := []interface{}{}
+		for {
+			tok, err := p.next()
+			if err != nil {
+				return nil, err
+			}
+			if isPunct(tok, ")") {
+				return array, nil
+			}
+			v, err := p.value(tok)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, v)
+			tok, err = p.next()
+			if err != nil {
+				return nil, err
+			}
+			if isPunct(tok, ")") {
+				return array, nil
+			}
+			if !isPunct(tok, ",") {
+				return nil, errorf(tok.Line, "expected ',' or ')' in array")
+			}
+		}
+	case tok.Kind == asciilex.String:
+		return tok.Text, nil
+	}
+	return nil, errorf(tok.Line, "unexpected %s", describe(tok))
+}
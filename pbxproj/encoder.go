@@ -0,0 +1,304 @@
+package pbxproj
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mkrautz/plist/internal/dictkeys"
+)
+
+// The classes Xcode writes on a single line.
+var inlineClasses = map[string]bool{
+	"PBXBuildFile":     true,
+	"PBXFileReference": true,
+}
+
+// Encode writes f in Xcode's format.
+func (e *Encoder) Encode(f *File) error {
+	w := &writer{
+		f:         f,
+		phaseOf:   make(map[string]*Object),
+		listOwner: make(map[string]*Object),
+	}
+	for _, o := range f.Objects {
+		if strings.HasSuffix(o.Isa, "BuildPhase") {
+			for _, bf := range o.Refs("files") {
+				w.phaseOf[bf.ID] = o
+			}
+		}
+		if list := o.String("buildConfigurationList"); list != "" {
+			w.listOwner[list] = o
+		}
+	}
+
+	w.sb.WriteString("// !$*UTF8*$!\n{\n")
+	classes := f.Classes
+	if classes == nil {
+		classes = map[string]interface{}{}
+	}
+	top := []struct {
+		key string
+		val interface{}
+	}{
+		{"archiveVersion", f.ArchiveVersion},
+		{"classes", classes},
+		{"objectVersion", f.ObjectVersion},
+	}
+	for _, kv := range top {
+		err := w.entry(1, kv.key, kv.val, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.sb.WriteString("\tobjects = {\n")
+	sections := make(map[string][]*Object)
+	var classNames []string
+	for _, o := range f.Objects {
+		if sections[o.Isa] == nil {
+			classNames = append(classNames, o.Isa)
+		}
+		sections[o.Isa] = append(sections[o.Isa], o)
+	}
+	sort.Strings(classNames)
+	for _, isa := range classNames {
+		objs := sections[isa]
+		sort.Slice(objs, func(i, j int) bool { return objs[i].ID < objs[j].ID })
+		fmt.Fprintf(&w.sb, "\n/* Begin %s section */\n", isa)
+		for _, o := range objs {
+			fields := make(map[string]interface{}, len(o.Fields)+1)
+			for k, v := range o.Fields {
+				fields[k] = v
+			}
+			fields["isa"] = o.Isa
+			w.sb.WriteString("\t\t")
+			w.ref(o.ID)
+			w.sb.WriteString(" = ")
+			err := w.value(2, "", fields, inlineClasses[o.Isa])
+			if err != nil {
+				return fmt.Errorf("plist: pbxproj object %s: %v", o.ID, err)
+			}
+			w.sb.WriteString(";\n")
+		}
+		fmt.Fprintf(&w.sb, "/* End %s section */\n", isa)
+	}
+	w.sb.WriteString("\t};\n")
+
+	err := w.entry(1, "rootObject", f.RootObject, false)
+	if err != nil {
+		return err
+	}
+	w.sb.WriteString("}\n")
+
+	_, err = io.WriteString(e.w, w.sb.String())
+	return err
+}
+
+// A writer holds the state of encoding a single project file.
+type writer struct {
+	sb strings.Builder
+	f  *File
+
+	// The build phase of each build file, and the owner of each
+	// build configuration list, for comments.
+	phaseOf   map[string]*Object
+	listOwner map[string]*Object
+}
+
+// entry writes a key-value pair of a multi-line dict.
+func (w *writer) entry(indent int, key string, v interface{}, inline bool) error {
+	w.sb.WriteString(strings.Repeat("\t", indent))
+	w.sb.WriteString(quote(key))
+	w.sb.WriteString(" = ")
+	err := w.value(indent, key, v, inline)
+	if err != nil {
+		return err
+	}
+	w.sb.WriteString(";\n")
+	return nil
+}
+
+// value writes v. Strings that refer to objects are followed by
+// a comment; key is the dict key v belongs to.
+func (w *writer) value(indent int, key string, v interface{}, inline bool) error {
+	tabs := strings.Repeat("\t", indent)
+	switch v := v.(type) {
+	case string:
+		if key == "remoteGlobalIDString" {
+			// Xcode writes no comment for these.
+			w.sb.WriteString(quote(v))
+		} else {
+			w.ref(v)
+		}
+	case []interface{}:
+		w.sb.WriteString("(")
+		if !inline {
+			w.sb.WriteString("\n")
+		}
+		for _, elem := range v {
+			if !inline {
+				w.sb.WriteString(tabs + "\t")
+			}
+			err := w.value(indent+1, key, elem, inline)
+			if err != nil {
+				return err
+			}
+			if inline {
+				w.sb.WriteString(", ")
+			} else {
+				w.sb.WriteString(",\n")
+			}
+		}
+		if !inline {
+			w.sb.WriteString(tabs)
+		}
+		w.sb.WriteString(")")
+	case map[string]interface{}:
+		keys := dictkeys.Sorted(v)
+		if _, ok := v["isa"]; ok {
+			// isa comes first.
+			for i, k := range keys {
+				if k == "isa" {
+					keys = append([]string{"isa"}, append(keys[:i:i], keys[i+1:]...)...)
+					break
+				}
+			}
+		}
+		w.sb.WriteString("{")
+		if !inline {
+			w.sb.WriteString("\n")
+		}
+		for _, k := range keys {
+			if inline {
+				w.sb.WriteString(quote(k) + " = ")
+				err := w.value(indent+1, k, v[k], inline)
+				if err != nil {
+					return err
+				}
+				w.sb.WriteString("; ")
+				continue
+			}
+			err := w.entry(indent+1, k, v[k], inline)
+			if err != nil {
+				return err
+			}
+		}
+		if !inline {
+			w.sb.WriteString(tabs)
+		}
+		w.sb.WriteString("}")
+	default:
+		return fmt.Errorf("cannot encode %T", v)
+	}
+	return nil
+}
+
+// ref writes s, followed by a comment if it refers to an object.
+func (w *writer) ref(s string) {
+	w.sb.WriteString(quote(s))
+	if c := w.comment(s); c != "" {
+		w.sb.WriteString(" /* " + c + " */")
+	}
+}
+
+// comment returns the comment Xcode writes after references to the
+// object id, or "" if there is none.
+func (w *writer) comment(id string) string {
+	o := w.f.Object(id)
+	if o == nil {
+		return ""
+	}
+	switch {
+	case o.Isa == "PBXBuildFile":
+		var name string
+		if ref := o.Ref("fileRef"); ref != nil {
+			name = displayName(ref)
+		} else if product := o.Ref("productRef"); product != nil {
+			name = product.String("productName")
+		}
+		if phase := w.phaseOf[id]; phase != nil {
+			name += " in " + phaseName(phase)
+		}
+		return name
+	case strings.HasSuffix(o.Isa, "BuildPhase"):
+		return phaseName(o)
+	case o.Isa == "PBXProject":
+		return "Project object"
+	case o.Isa == "XCConfigurationList":
+		owner := w.listOwner[id]
+		if owner == nil {
+			return "Build configuration list"
+		}
+		name := owner.String("name")
+		if owner.Isa == "PBXProject" {
+			name = w.f.Name
+		}
+		return fmt.Sprintf("Build configuration list for %s \"%s\"", owner.Isa, name)
+	case o.Isa == "PBXTargetDependency" || o.Isa == "PBXContainerItemProxy":
+		return o.Isa
+	case o.Isa == "XCRemoteSwiftPackageReference":
+		repo := strings.TrimSuffix(path.Base(o.String("repositoryURL")), ".git")
+		return fmt.Sprintf("%s \"%s\"", o.Isa, repo)
+	case o.Isa == "XCSwiftPackageProductDependency":
+		return o.String("productName")
+	}
+	return displayName(o)
+}
+
+// displayName returns the name of o, or its path if it has none.
+func displayName(o *Object) string {
+	if name := o.String("name"); name != "" {
+		return name
+	}
+	return o.String("path")
+}
+
+// phaseName returns the name Xcode shows for a build phase, such as
+// "Sources" for a PBXSourcesBuildPhase.
+func phaseName(o *Object) string {
+	if name := o.String("name"); name != "" {
+		return name
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(o.Isa, "PBX"), "BuildPhase")
+}
+
+// quote returns s as Xcode writes it: unquoted if it consists only
+// of letters, digits and _$/:. and contains neither ___ nor //.
+func quote(s string) string {
+	bare := s != "" && !strings.Contains(s, "___") && !strings.Contains(s, "//")
+	for _, r := range s {
+		if !bare {
+			break
+		}
+		bare = unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_$/:.", r)
+	}
+	if bare {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\n':
+			sb.WriteString("\\n")
+		case '\t':
+			sb.WriteString("\\t")
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&sb, "\\U%04x", r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,320 @@
+package pbxproj
+
+import (
+	"path"
+	"strings"
+)
+
+// A Project is the root PBXProject object.
+type Project struct{ *Object }
+
+// A Target is a PBXNativeTarget, PBXAggregateTarget or
+// PBXLegacyTarget.
+type Target struct{ *Object }
+
+// A BuildPhase is a build phase of a target, such as a
+// PBXSourcesBuildPhase.
+type BuildPhase struct{ *Object }
+
+// A BuildFile is a PBXBuildFile, the membership of a file in a
+// build phase.
+type BuildFile struct{ *Object }
+
+// A FileReference is a PBXFileReference.
+type FileReference struct{ *Object }
+
+// A Group is a PBXGroup or PBXVariantGroup.
+type Group struct{ *Object }
+
+// A ConfigurationList is an XCConfigurationList.
+type ConfigurationList struct{ *Object }
+
+// A BuildConfiguration is an XCBuildConfiguration.
+type BuildConfiguration struct{ *Object }
+
+// Project returns the root object of f.
+func (f *File) Project() *Project {
+	return &Project{f.Object(f.RootObject)}
+}
+
+// Targets returns the targets of the project.
+func (p *Project) Targets() []*Target {
+	var targets []*Target
+	for _, o := range p.Refs("targets") {
+		targets = append(targets, &Target{o})
+	}
+	return targets
+}
+
+// Target returns the target with the given name, or nil.
+func (p *Project) Target(name string) *Target {
+	for _, t := range p.Targets() {
+		if t.Name() == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// MainGroup returns the top-level group of the project.
+func (p *Project) MainGroup() *Group {
+	o := p.Ref("mainGroup")
+	if o == nil {
+		return nil
+	}
+	return &Group{o}
+}
+
+// ConfigurationList returns the project's build configuration list.
+func (p *Project) ConfigurationList() *ConfigurationList {
+	return configurationList(p.Object)
+}
+
+// Name returns the name of the target.
+func (t *Target) Name() string {
+	return t.String("name")
+}
+
+// BuildPhases returns the build phases of the target, in order.
+func (t *Target) BuildPhases() []*BuildPhase {
+	var phases []*BuildPhase
+	for _, o := range t.Refs("buildPhases") {
+		phases = append(phases, &BuildPhase{o})
+	}
+	return phases
+}
+
+// BuildPhase returns the target's first build phase of class isa,
+// such as "PBXSourcesBuildPhase", adding one if there is none.
+func (t *Target) BuildPhase(isa string) *BuildPhase {
+	for _, phase := range t.BuildPhases() {
+		if phase.Isa == isa {
+			return phase
+		}
+	}
+	o := t.file.NewObject(isa)
+	o.Set("buildActionMask", "2147483647")
+	o.Set("files", []interface{}{})
+	o.Set("runOnlyForDeploymentPostprocessing", "0")
+	t.appendRef("buildPhases", o)
+	return &BuildPhase{o}
+}
+
+// ConfigurationList returns the target's build configuration list.
+func (t *Target) ConfigurationList() *ConfigurationList {
+	return configurationList(t.Object)
+}
+
+// SetBuildSetting sets a build setting in every configuration of the
+// target. See BuildConfiguration.SetBuildSetting.
+func (t *Target) SetBuildSetting(key string, v interface{}) {
+	if list := t.ConfigurationList(); list != nil {
+		for _, c := range list.Configurations() {
+			c.SetBuildSetting(key, v)
+		}
+	}
+}
+
+// AddFile adds ref to the build phase that suits its type: sources to
+// the sources phase, frameworks and libraries to the frameworks phase,
+// headers to the headers phase and anything else to the resources
+// phase.
+func (t *Target) AddFile(ref *FileReference) *BuildFile {
+	isa := "PBXResourcesBuildPhase"
+	typ := ref.String("lastKnownFileType")
+	switch {
+	case strings.HasPrefix(typ, "sourcecode.") && strings.HasSuffix(typ, ".h"):
+		isa = "PBXHeadersBuildPhase"
+	case strings.HasPrefix(typ, "sourcecode."):
+		isa = "PBXSourcesBuildPhase"
+	case typ == "wrapper.framework" || strings.HasPrefix(typ, "archive.") || strings.HasPrefix(typ, "compiled.mach-o"):
+		isa = "PBXFrameworksBuildPhase"
+	}
+	return t.BuildPhase(isa).AddFile(ref)
+}
+
+// Files returns the build files of the phase.
+func (b *BuildPhase) Files() []*BuildFile {
+	var files []*BuildFile
+	for _, o := range b.Refs("files") {
+		files = append(files, &BuildFile{o})
+	}
+	return files
+}
+
+// AddFile adds ref to the phase, unless it is already part of it.
+func (b *BuildPhase) AddFile(ref *FileReference) *BuildFile {
+	for _, bf := range b.Files() {
+		if bf.String("fileRef") == ref.ID {
+			return bf
+		}
+	}
+	o := b.file.NewObject("PBXBuildFile")
+	o.Set("fileRef", ref.ID)
+	b.appendRef("files", o)
+	return &BuildFile{o}
+}
+
+// FileRef returns the file the build file refers to, or nil.
+func (b *BuildFile) FileRef() *FileReference {
+	o := b.Ref("fileRef")
+	if o == nil {
+		return nil
+	}
+	return &FileReference{o}
+}
+
+// Path returns the path of the file, relative to its source tree.
+func (r *FileReference) Path() string {
+	return r.String("path")
+}
+
+// Name returns the name of the group, or its path if it has none.
+func (g *Group) Name() string {
+	return displayName(g.Object)
+}
+
+// Children returns the files and groups of the group.
+func (g *Group) Children() []*Object {
+	return g.Refs("children")
+}
+
+// Group returns the child group with the given name, or nil.
+func (g *Group) Group(name string) *Group {
+	for _, o := range g.Children() {
+		if (o.Isa == "PBXGroup" || o.Isa == "PBXVariantGroup") && displayName(o) == name {
+			return &Group{o}
+		}
+	}
+	return nil
+}
+
+// AddGroup adds a child group with the given path.
+func (g *Group) AddGroup(p string) *Group {
+	o := g.file.NewObject("PBXGroup")
+	o.Set("children", []interface{}{})
+	o.Set("path", p)
+	o.Set("sourceTree", "<group>")
+	g.appendRef("children", o)
+	return &Group{o}
+}
+
+// AddFile adds a reference to the file at path p, relative to the
+// group, unless the group already has one. Its type is derived from
+// the file name extension.
+func (g *Group) AddFile(p string) *FileReference {
+	for _, o := range g.Children() {
+		if o.Isa == "PBXFileReference" && o.String("path") == p {
+			return &FileReference{o}
+		}
+	}
+	o := g.file.NewObject("PBXFileReference")
+	o.Set("lastKnownFileType", fileType(p))
+	o.Set("path", p)
+	o.Set("sourceTree", "<group>")
+	g.appendRef("children", o)
+	return &FileReference{o}
+}
+
+// Configurations returns the build configurations of the list.
+func (l *ConfigurationList) Configurations() []*BuildConfiguration {
+	var configs []*BuildConfiguration
+	for _, o := range l.Refs("buildConfigurations") {
+		configs = append(configs, &BuildConfiguration{o})
+	}
+	return configs
+}
+
+// Configuration returns the configuration with the given name, such
+// as "Debug", or nil.
+func (l *ConfigurationList) Configuration(name string) *BuildConfiguration {
+	for _, c := range l.Configurations() {
+		if c.Name() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Name returns the name of the configuration.
+func (c *BuildConfiguration) Name() string {
+	return c.String("name")
+}
+
+// BuildSettings returns the build settings of the configuration.
+// Changes to the returned dict change the configuration.
+func (c *BuildConfiguration) BuildSettings() map[string]interface{} {
+	settings, ok := c.Fields["buildSettings"].(map[string]interface{})
+	if !ok {
+		settings = make(map[string]interface{})
+		c.Fields["buildSettings"] = settings
+	}
+	return settings
+}
+
+// BuildSetting returns the value of a build setting: a string, an
+// array of strings, or nil if it is not set.
+func (c *BuildConfiguration) BuildSetting(key string) interface{} {
+	return c.BuildSettings()[key]
+}
+
+// SetBuildSetting sets a build setting to a string or an array of
+// strings ([]string or []interface{}), or removes it if v is nil.
+func (c *BuildConfiguration) SetBuildSetting(key string, v interface{}) {
+	settings := c.BuildSettings()
+	switch val := v.(type) {
+	case nil:
+		delete(settings, key)
+	case []string:
+		array := make([]interface{}, len(val))
+		for i, s := range val {
+			array[i] = s
+		}
+		settings[key] = array
+	default:
+		settings[key] = v
+	}
+}
+
+func configurationList(o *Object) *ConfigurationList {
+	list := o.Ref("buildConfigurationList")
+	if list == nil {
+		return nil
+	}
+	return &ConfigurationList{list}
+}
+
+// The lastKnownFileType of common file name extensions.
+var fileTypes = map[string]string{
+	".a":            "archive.ar",
+	".c":            "sourcecode.c.c",
+	".cpp":          "sourcecode.cpp.cpp",
+	".dylib":        "compiled.mach-o.dylib",
+	".entitlements": "text.plist.entitlements",
+	".framework":    "wrapper.framework",
+	".h":            "sourcecode.c.h",
+	".json":         "text.json",
+	".m":            "sourcecode.c.objc",
+	".md":           "net.daringfireball.markdown",
+	".metal":        "sourcecode.metal",
+	".mm":           "sourcecode.cpp.objcpp",
+	".plist":        "text.plist.xml",
+	".png":          "image.png",
+	".storyboard":   "file.storyboard",
+	".strings":      "text.plist.strings",
+	".stringsdict":  "text.plist.stringsdict",
+	".swift":        "sourcecode.swift",
+	".xcassets":     "folder.assetcatalog",
+	".xcconfig":     "text.xcconfig",
+	".xcframework":  "wrapper.xcframework",
+	".xcstrings":    "text.json.xcstrings",
+	".xib":          "file.xib",
+}
+
+// fileType returns the lastKnownFileType of the file at path p.
+func fileType(p string) string {
+	if t, ok := fileTypes[strings.ToLower(path.Ext(p))]; ok {
+		return t
+	}
+	return "file"
+}
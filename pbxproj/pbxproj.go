@@ -0,0 +1,170 @@
+// Package pbxproj reads and writes Xcode project files
+// (project.pbxproj).
+//
+// A project file is an ASCII plist whose "objects" dict maps 24-digit
+// hexadecimal object IDs to objects, each of which names its class in
+// an "isa" field and refers to other objects by ID. The package keeps
+// every object with all of its fields, resolves references through the
+// File it belongs to, and offers typed views (Project, Target,
+// BuildPhase, BuildFile, FileReference, Group, ConfigurationList and
+// BuildConfiguration) for the common edits.
+//
+// The encoder writes files the way Xcode does: objects grouped into
+// sections by class, one-line PBXBuildFile and PBXFileReference
+// objects, and a generated comment after each object reference, so
+// that a file that is read and written again is unchanged.
+package pbxproj
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// A File is the contents of a project file.
+type File struct {
+	ArchiveVersion string
+	ObjectVersion  string
+	Classes        map[string]interface{}
+	Objects        map[string]*Object
+	RootObject     string
+
+	// The name of the project, as used in the comment of its build
+	// configuration list. Decoding takes it from that comment.
+	Name string
+
+	seq int
+}
+
+// An Object is a single object of a project file. Its fields hold
+// strings, []interface{} arrays and map[string]interface{} dicts of
+// those; references to other objects are their IDs.
+type Object struct {
+	ID     string
+	Isa    string
+	Fields map[string]interface{}
+
+	file *File
+}
+
+// Object returns the object with the given ID, or nil if there is none.
+func (f *File) Object(id string) *Object {
+	return f.Objects[id]
+}
+
+// NewObject adds a new object of class isa to f, with a fresh ID.
+func (f *File) NewObject(isa string) *Object {
+	o := &Object{
+		ID:     f.newID(),
+		Isa:    isa,
+		Fields: make(map[string]interface{}),
+		file:   f,
+	}
+	f.Objects[o.ID] = o
+	return o
+}
+
+// newID returns an unused object ID. IDs are derived from the root
+// object's ID and a counter, so edits are reproducible.
+func (f *File) newID() string {
+	for {
+		f.seq++
+		sum := md5.Sum([]byte(fmt.Sprintf("%s %d", f.RootObject, f.seq)))
+		id := strings.ToUpper(hex.EncodeToString(sum[:12]))
+		if _, ok := f.Objects[id]; !ok {
+			return id
+		}
+	}
+}
+
+// String returns the string field key, or "" if it is not a string.
+func (o *Object) String(key string) string {
+	s, _ := o.Fields[key].(string)
+	return s
+}
+
+// Set sets the field key to v, or removes it if v is nil.
+func (o *Object) Set(key string, v interface{}) {
+	if v == nil {
+		delete(o.Fields, key)
+		return
+	}
+	o.Fields[key] = v
+}
+
+// Ref returns the object referred to by the field key, or nil.
+func (o *Object) Ref(key string) *Object {
+	return o.file.Object(o.String(key))
+}
+
+// Refs returns the objects referred to by the array field key.
+// References to missing objects are skipped.
+func (o *Object) Refs(key string) []*Object {
+	ids, _ := o.Fields[key].([]interface{})
+	var objs []*Object
+	for _, id := range ids {
+		s, _ := id.(string)
+		if obj := o.file.Object(s); obj != nil {
+			objs = append(objs, obj)
+		}
+	}
+	return objs
+}
+
+// appendRef appends a reference to obj to the array field key.
+func (o *Object) appendRef(key string, obj *Object) {
+	ids, _ := o.Fields[key].([]interface{})
+	o.Fields[key] = append(ids, obj.ID)
+}
+
+// Unmarshal parses the project file data.
+func Unmarshal(data []byte) (*File, error) {
+	return NewDecoder(bytes.NewBuffer(data)).Decode()
+}
+
+// Marshal returns the encoding of f.
+func Marshal(f *File) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := NewEncoder(buf).Encode(f)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// A Decoder reads project files.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder creates a new project file reader.
+func NewDecoder(r io.Reader) *Decoder {
+	d := new(Decoder)
+	d.r = r
+	return d
+}
+
+// Decode reads a single project file.
+func (d *Decoder) Decode() (*File, error) {
+	buf, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return nil, err
+	}
+	return parse(string(buf))
+}
+
+// An Encoder writes project files.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	enc := new(Encoder)
+	enc.w = w
+	return enc
+}
@@ -0,0 +1,114 @@
+package pbxproj
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/project.pbxproj")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	f, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	out, err := Marshal(f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(out, buf) {
+		t.Fatalf("round trip mismatch:\n%s", out)
+	}
+}
+
+func TestObjectGraph(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/project.pbxproj")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	f, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if f.Name != "Hello" {
+		t.Fatalf("got project name %q", f.Name)
+	}
+	p := f.Project()
+	target := p.Target("Hello")
+	if target == nil {
+		t.Fatalf("target not found")
+	}
+
+	var sources []string
+	for _, bf := range target.BuildPhase("PBXSourcesBuildPhase").Files() {
+		sources = append(sources, bf.FileRef().Path())
+	}
+	expected := []string{"ContentView.swift", "HelloApp.swift", "Über.swift"}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Fatalf("got sources %q, expected %q", sources, expected)
+	}
+
+	debug := p.ConfigurationList().Configuration("Debug")
+	defs := debug.BuildSetting("GCC_PREPROCESSOR_DEFINITIONS")
+	if !reflect.DeepEqual(defs, []interface{}{"DEBUG=1", "$(inherited)"}) {
+		t.Fatalf("got %#v", defs)
+	}
+	if v := debug.BuildSetting("CODE_SIGN_IDENTITY[sdk=iphoneos*]"); v != "iPhone Developer" {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestEdit(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/project.pbxproj")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	f, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	p := f.Project()
+	target := p.Target("Hello")
+	group := p.MainGroup().Group("Hello")
+
+	target.AddFile(group.AddFile("Model.swift"))
+	target.AddFile(group.AddFile("Localizable.xcstrings"))
+	target.SetBuildSetting("SWIFT_VERSION", "6.0")
+	target.SetBuildSetting("OTHER_LDFLAGS", []string{"-ObjC"})
+
+	out, err := Marshal(f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, s := range []string{
+		"/* Model.swift in Sources */ = {isa = PBXBuildFile; fileRef = ",
+		" /* Model.swift */ = {isa = PBXFileReference; lastKnownFileType = sourcecode.swift; path = Model.swift; sourceTree = \"<group>\"; };",
+		" /* Localizable.xcstrings in Resources */,\n",
+		"\t\t\t\tOTHER_LDFLAGS = (\n\t\t\t\t\t\"-ObjC\",\n\t\t\t\t);\n",
+		"\t\t\t\tSWIFT_VERSION = 6.0;\n",
+	} {
+		if !strings.Contains(string(out), s) {
+			t.Fatalf("output lacks %q:\n%s", s, out)
+		}
+	}
+
+	g, err := Unmarshal(out)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(g.Objects) != len(f.Objects) {
+		t.Fatalf("got %d objects, expected %d", len(g.Objects), len(f.Objects))
+	}
+	again, err := Marshal(g)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(again, out) {
+		t.Fatalf("edited file does not round trip")
+	}
+}
@@ -2,11 +2,16 @@
 package plist
 
 import (
+	"bufio"
 	"bytes"
-	"errors"
+	"fmt"
 	"github.com/mkrautz/plist/asciiplist"
+	"github.com/mkrautz/plist/binaryplist"
+	"github.com/mkrautz/plist/internal/plistreflect"
+	"github.com/mkrautz/plist/jsonplist"
 	"github.com/mkrautz/plist/xmlplist"
 	"io"
+	"strconv"
 	"strings"
 )
 
@@ -16,19 +21,75 @@ type plistEncoder interface {
 
 type plistDecoder interface {
 	Decode(v interface{}) error
+	UseOrderedDicts()
 }
 
+// A UID is a reference to another object in the same plist, as used
+// by NSKeyedArchiver archives. Binary plists store UIDs natively; the
+// XML and ASCII kinds represent them as a dict with the single key
+// CF$UID, which their decoders turn back into a UID.
+type UID = binaryplist.UID
+
+// An OrderedDict is a dict whose entries are encoded in the order
+// given, rather than in the sorted key order used for maps. Its keys
+// should be unique. Decoders return dicts as maps.
+type OrderedDict = binaryplist.OrderedDict
+
+// A DictEntry is a single entry of an OrderedDict.
+type DictEntry = binaryplist.DictEntry
+
+// A Marshaler is a type that is encoded as the value its MarshalPlist
+// method returns, such as a type with several forms in a plist.
+type Marshaler = plistreflect.Marshaler
+
+// An Unmarshaler is a type that decodes itself. UnmarshalPlist is given
+// a function that decodes the plist value into the value its argument
+// points to, which it may call more than once to try the forms the
+// type accepts.
+type Unmarshaler = plistreflect.Unmarshaler
+
 // A Kind represents a kind of plist.
-// There are three distinct kinds: ASCII, XML and Binary.
+// There are three distinct plist kinds: ASCII, XML and Binary.
+// JSON is not a plist kind of its own, but is supported as
+// an interchange format.
 type Kind int
 
 const (
 	Unknown Kind = iota
 	XML     // XML plists are supported for both reading and writing
-	ASCII   // ASCII plists are not currently supported
-	Binary  // Binary plists are not currently supported
+	ASCII   // ASCII plists are supported for both reading and writing
+	Binary  // Binary plists are supported for both reading and writing
+	JSON    // JSON is supported for both reading and writing, in plain mode unless set otherwise (see JSONMode)
 )
 
+var kindNames = []string{"unknown", "xml", "ascii", "binary", "json"}
+
+// String returns the name of the kind: "xml", "ascii", "binary" or
+// "json".
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "Kind(" + strconv.Itoa(int(k)) + ")"
+	}
+	return kindNames[k]
+}
+
+// ParseKind returns the kind named by name, as returned by
+// Kind.String. Case is ignored, and plutil's format names xml1,
+// binary1 and openstep are accepted as well.
+func ParseKind(name string) (Kind, error) {
+	switch strings.ToLower(name) {
+	case "xml", "xml1":
+		return XML, nil
+	case "ascii", "openstep":
+		return ASCII, nil
+	case "binary", "binary1":
+		return Binary, nil
+	case "json":
+		return JSON, nil
+	}
+	return Unknown, fmt.Errorf("plist: unknown kind %q", name)
+}
+
 // Unmarshal unmarshals a plist into the value v.
 // The value v must be a pointer to a type supported
 // by the kind of plist presented in the given data.
@@ -37,7 +98,22 @@ func Unmarshal(data []byte, v interface{}) error {
 	return dec.Decode(v)
 }
 
+// UnmarshalWithKind is like Unmarshal, but also returns the kind of
+// the plist, so that it can be written back in the same kind. The kind
+// is returned even if decoding fails, once it is known.
+func UnmarshalWithKind(data []byte, v interface{}) (Kind, error) {
+	dec := NewDecoder(bytes.NewBuffer(data))
+	err := dec.Decode(v)
+	return dec.Kind(), err
+}
+
 // Marshal marshals the value v into a plist.
+//
+// Struct fields are encoded under the key given by their plist tag, or
+// their name. Options may follow the key, as in `plist:"Label,omitempty"`:
+// omitempty leaves a field out when it is false, 0, nil or empty, and
+// unknown marks a map field that holds the dict entries no other field
+// claims, on decoding as well.
 func Marshal(v interface{}) ([]byte, error) {
 	bw := new(bytes.Buffer)
 	enc := NewEncoder(bw)
@@ -48,60 +124,42 @@ func Marshal(v interface{}) ([]byte, error) {
 	return bw.Bytes(), nil
 }
 
-// a detectingReader is a special io.Reader that can
-// detect plist kinds.
-type detectingReader struct {
-	buf []byte
-	r   io.Reader
-}
-
-// detectKind reads some bytes off the start of the
-// detectingReader's reader to determine which plist
-// kind the given data belongs to.
-func (r *detectingReader) detectKind() (Kind, error) {
-	r.buf = make([]byte, 10)
-	_, err := io.ReadFull(r.r, r.buf)
-	if err != nil {
-		return Unknown, err
-	}
-
-	str := string(r.buf)
-	if strings.Contains(str, "<?xml") {
-		return XML, nil
-	} else if strings.Contains(str, "bplist") {
-		return Binary, nil
-	} else if strings.ContainsAny(str, "{(") {
-		return ASCII, nil
-	}
-
-	return Unknown, errors.New("plist: unknown kind")
-}
-
-func (r *detectingReader) Read(p []byte) (int, error) {
-	// As long as we haven't consumed buf yet, read from it.
-	if len(r.buf) > 0 {
-		n := copy(p, r.buf)
-		r.buf = r.buf[n:]
-		return n, nil
-	}
-	return r.r.Read(p)
-}
-
 // A Decoder represents a plist decoder.
 // The decoder automatically detects the kind of the plist
 // it is reading.
 type Decoder struct {
-	dr        *detectingReader
-	plistDec  plistDecoder
+	br       *bufio.Reader
+	kind     Kind
+	jsonMode JSONMode
+	ordered  bool
+	plistDec plistDecoder
 }
 
 // NewDecoder creates a new Decoder capable of reading any of the
-// three plist kinds.
+// plist kinds, detecting the kind as DetectKind does.
 func NewDecoder(r io.Reader) *Decoder {
 	d := new(Decoder)
-	d.dr = &detectingReader{
-		r: r,
+	d.br = bufio.NewReader(r)
+	return d
+}
+
+// NewSpecificDecoder creates a new Decoder that reads the kind
+// given as Kind, without attempting to detect it.
+func NewSpecificDecoder(r io.Reader, kind Kind) *Decoder {
+	d := new(Decoder)
+	switch kind {
+	case XML:
+		d.plistDec = xmlplist.NewDecoder(r)
+	case ASCII:
+		d.plistDec = asciiplist.NewDecoder(r)
+	case Binary:
+		d.plistDec = binaryplist.NewDecoder(r)
+	case JSON:
+		d.plistDec = jsonplist.NewDecoder(r)
+	default:
+		return nil
 	}
+	d.kind = kind
 	return d
 }
 
@@ -109,21 +167,54 @@ func NewDecoder(r io.Reader) *Decoder {
 // into the value v.
 func (d *Decoder) Decode(v interface{}) error {
 	if d.plistDec == nil {
-		kind, err := d.dr.detectKind()
+		kind, enc, err := peekKind(d.br)
+		d.kind = kind
 		if err != nil {
 			return err
 		}
-		if kind == XML {
-			d.plistDec = xmlplist.NewDecoder(d.dr)
-		} else if kind == ASCII {
-			d.plistDec = asciiplist.NewDecoder(d.dr)
-		} else {
-			return errors.New("plist: unknown kind")
+		var r io.Reader = d.br
+		if kind != Binary {
+			r, err = textReader(d.br, enc)
+			if err != nil {
+				return err
+			}
+		}
+		d.plistDec = NewSpecificDecoder(r, kind).plistDec
+		d.SetJSONMode(d.jsonMode)
+		if d.ordered {
+			d.UseOrderedDicts()
 		}
 	}
 	return d.plistDec.Decode(v)
 }
 
+// SetJSONMode sets how a Decoder that reads JSON represents the plist
+// values JSON has no equivalent for. The default is PlainJSON, as with
+// plutil, so that ordinary JSON files are read as they are.
+func (d *Decoder) SetJSONMode(mode JSONMode) {
+	d.jsonMode = mode
+	if dec, ok := d.plistDec.(*jsonplist.Decoder); ok {
+		dec.SetTyped(mode == TypedJSON)
+	}
+}
+
+// UseOrderedDicts makes Decode return dicts as OrderedDict values,
+// with their entries in the order of the input, when v points to an
+// empty interface. Plists of every kind keep their key order this way.
+func (d *Decoder) UseOrderedDicts() {
+	d.ordered = true
+	if d.plistDec != nil {
+		d.plistDec.UseOrderedDicts()
+	}
+}
+
+// Kind returns the kind of plist the Decoder reads. For a Decoder
+// created by NewDecoder, it is Unknown until the first call to Decode
+// has detected the kind.
+func (d *Decoder) Kind() Kind {
+	return d.kind
+}
+
 // An Encoder encodes values to one of the three plist formats.
 type Encoder struct {
 	plistEnc plistEncoder
@@ -142,12 +233,27 @@ func NewSpecificEncoder(w io.Writer, kind Kind) *Encoder {
 	switch kind {
 	case XML:
 		enc.plistEnc = xmlplist.NewEncoder(w)
+	case ASCII:
+		enc.plistEnc = asciiplist.NewEncoder(w)
+	case Binary:
+		enc.plistEnc = binaryplist.NewEncoder(w)
+	case JSON:
+		enc.plistEnc = jsonplist.NewEncoder(w)
 	default:
 		return nil
 	}
 	return enc
 }
 
+// SetJSONMode sets how an Encoder that writes JSON represents the plist
+// values JSON has no equivalent for. The default is PlainJSON, in which
+// such values cannot be encoded, as with plutil.
+func (e *Encoder) SetJSONMode(mode JSONMode) {
+	if enc, ok := e.plistEnc.(*jsonplist.Encoder); ok {
+		enc.SetTyped(mode == TypedJSON)
+	}
+}
+
 // Encode encodes the value v into the plist kind
 // the Encoder is configured to use.
 func (e *Encoder) Encode(v interface{}) error {
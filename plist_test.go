@@ -3,7 +3,9 @@ package plist
 import (
 	"bytes"
 	"io/ioutil"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestXMLDefault(t *testing.T) {
@@ -83,3 +85,314 @@ func TestDetectingReaderBinary(t *testing.T) {
 		t.Fatalf("should detect bplist")
 	}
 }
+
+func TestJSON(t *testing.T) {
+	v := map[string]interface{}{
+		"date": time.Date(2012, 1, 29, 13, 7, 25, 0, time.UTC),
+		"real": float64(2),
+	}
+
+	_, err := ToJSON(v, PlainJSON)
+	if err == nil {
+		t.Fatalf("plain JSON should reject dates")
+	}
+
+	buf, err := ToJSON(v, TypedJSON)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var actual map[string]interface{}
+	err = FromJSON(buf, &actual, TypedJSON)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(actual, v) {
+		t.Fatalf("got %#v, expected %#v", actual, v)
+	}
+}
+
+func TestJSONEncoder(t *testing.T) {
+	v := []interface{}{[]byte("hi"), int64(1)}
+	err := NewSpecificEncoder(new(bytes.Buffer), JSON).Encode(v)
+	if err == nil {
+		t.Fatalf("plain JSON should reject data")
+	}
+
+	buf := new(bytes.Buffer)
+	enc := NewSpecificEncoder(buf, JSON)
+	enc.SetJSONMode(TypedJSON)
+	err = enc.Encode(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var actual []interface{}
+	dec := NewSpecificDecoder(buf, JSON)
+	dec.SetJSONMode(TypedJSON)
+	err = dec.Decode(&actual)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := []interface{}{[]byte("hi"), int64(1)}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("got %#v, expected %#v", actual, expected)
+	}
+}
+
+func TestDetectedJSONIsPlain(t *testing.T) {
+	in := []byte(`{"$date": "not a date", "n": 1}`)
+	var v map[string]interface{}
+	kind, err := UnmarshalWithKind(in, &v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := map[string]interface{}{"$date": "not a date", "n": int64(1)}
+	if kind != JSON || !reflect.DeepEqual(v, expected) {
+		t.Fatalf("got %v %#v, expected %#v", kind, v, expected)
+	}
+
+	buf := new(bytes.Buffer)
+	err = NewSpecificEncoder(buf, JSON).Encode(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if buf.String() != `{"$date":"not a date","n":1}`+"\n" {
+		t.Fatalf("got %s", buf)
+	}
+
+	v["d"] = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = NewSpecificEncoder(new(bytes.Buffer), JSON).Encode(v)
+	if err == nil {
+		t.Fatalf("plain JSON should reject dates")
+	}
+}
+
+func TestUIDAcrossKinds(t *testing.T) {
+	v := map[string]interface{}{
+		"root":    UID(1),
+		"objects": []interface{}{"$null", UID(300)},
+	}
+
+	for _, kind := range []Kind{Binary, XML, ASCII, JSON} {
+		buf := new(bytes.Buffer)
+		enc := NewSpecificEncoder(buf, kind)
+		enc.SetJSONMode(TypedJSON)
+		err := enc.Encode(v)
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		var actual map[string]interface{}
+		dec := NewSpecificDecoder(buf, kind)
+		dec.SetJSONMode(TypedJSON)
+		err = dec.Decode(&actual)
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		if !reflect.DeepEqual(actual, v) {
+			t.Fatalf("kind %v: got %#v, expected %#v", kind, actual, v)
+		}
+	}
+
+	buf, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Contains(buf, []byte("<dict>\n\t\t<key>CF$UID</key>\n\t\t<integer>1</integer>\n\t</dict>")) {
+		t.Fatalf("UID not encoded as a CF$UID dict:\n%s", buf)
+	}
+}
+
+// oneOrMore is a string or an array of strings.
+type oneOrMore []string
+
+func (o oneOrMore) MarshalPlist() (interface{}, error) {
+	if len(o) == 1 {
+		return o[0], nil
+	}
+	return []string(o), nil
+}
+
+func (o *oneOrMore) UnmarshalPlist(unmarshal func(v interface{}) error) error {
+	var s string
+	if unmarshal(&s) == nil {
+		*o = oneOrMore{s}
+		return nil
+	}
+	return unmarshal((*[]string)(o))
+}
+
+type taggedStruct struct {
+	Name  string                 `plist:"name,omitempty"`
+	Count int                    `plist:",omitempty"`
+	Tags  oneOrMore              `plist:"tags"`
+	Other map[string]interface{} `plist:",unknown"`
+}
+
+func TestStructTagsAcrossKinds(t *testing.T) {
+	v := taggedStruct{
+		Name:  "a",
+		Tags:  oneOrMore{"x"},
+		Other: map[string]interface{}{"extra": "e", "name": "shadowed"},
+	}
+	expected := map[string]interface{}{"name": "a", "tags": "x", "extra": "e"}
+	expectedStruct := taggedStruct{
+		Name:  "a",
+		Tags:  oneOrMore{"x"},
+		Other: map[string]interface{}{"extra": "e"},
+	}
+
+	for _, kind := range []Kind{Binary, XML, JSON, ASCII} {
+		buf := new(bytes.Buffer)
+		err := NewSpecificEncoder(buf, kind).Encode(v)
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		data := buf.Bytes()
+		var actual map[string]interface{}
+		err = NewSpecificDecoder(bytes.NewReader(data), kind).Decode(&actual)
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("kind %v: got %#v, expected %#v", kind, actual, expected)
+		}
+		var actualStruct taggedStruct
+		err = NewSpecificDecoder(bytes.NewReader(data), kind).Decode(&actualStruct)
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		if !reflect.DeepEqual(actualStruct, expectedStruct) {
+			t.Fatalf("kind %v: got %#v, expected %#v", kind, actualStruct, expectedStruct)
+		}
+	}
+
+	var many taggedStruct
+	err := Unmarshal([]byte(`{"tags": ["x", "y"]}`), &many)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(many.Tags, oneOrMore{"x", "y"}) {
+		t.Fatalf("got %#v, expected [x y]", many.Tags)
+	}
+}
+
+func TestOrderedDictAcrossKinds(t *testing.T) {
+	v := OrderedDict{
+		{Key: "b", Value: int64(1)},
+		{Key: "-", Value: "dash"},
+		{Key: "", Value: "empty"},
+		{Key: "a", Value: OrderedDict{{Key: "z", Value: true}, {Key: "y", Value: false}}},
+	}
+	expected := map[string]interface{}{
+		"b": int64(1),
+		"-": "dash",
+		"":  "empty",
+		"a": map[string]interface{}{"z": true, "y": false},
+	}
+
+	for _, kind := range []Kind{Binary, XML, JSON, ASCII} {
+		buf := new(bytes.Buffer)
+		err := NewSpecificEncoder(buf, kind).Encode(v)
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		data := buf.Bytes()
+		var actual map[string]interface{}
+		err = NewSpecificDecoder(bytes.NewReader(data), kind).Decode(&actual)
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		if kind == ASCII {
+			// ASCII plists, tested last, store numbers and
+			// booleans as strings.
+			expected["b"] = "1"
+			expected["a"] = map[string]interface{}{"z": "YES", "y": "NO"}
+			v[0].Value = "1"
+			v[3].Value = OrderedDict{{Key: "z", Value: "YES"}, {Key: "y", Value: "NO"}}
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("kind %v: got %#v, expected %#v", kind, actual, expected)
+		}
+
+		var ordered interface{}
+		dec := NewSpecificDecoder(bytes.NewReader(data), kind)
+		dec.UseOrderedDicts()
+		err = dec.Decode(&ordered)
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		if !reflect.DeepEqual(ordered, v) {
+			t.Fatalf("kind %v: got %#v, expected %#v", kind, ordered, v)
+		}
+	}
+
+	buf, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	last := -1
+	for _, key := range []string{"<key>b</key>", "<key>-</key>", "<key></key>", "<key>a</key>", "<key>z</key>", "<key>y</key>"} {
+		i := bytes.Index(buf, []byte(key))
+		if i < last {
+			t.Fatalf("%s out of order:\n%s", key, buf)
+		}
+		last = i
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	for _, kind := range []Kind{XML, ASCII, Binary, JSON} {
+		parsed, err := ParseKind(kind.String())
+		if err != nil || parsed != kind {
+			t.Fatalf("%v: got %v, %v", kind, parsed, err)
+		}
+	}
+	for name, kind := range map[string]Kind{"XML": XML, "xml1": XML, "binary1": Binary, "openstep": ASCII} {
+		parsed, err := ParseKind(name)
+		if err != nil || parsed != kind {
+			t.Fatalf("%s: got %v, %v", name, parsed, err)
+		}
+	}
+	_, err := ParseKind("yaml")
+	if err == nil {
+		t.Fatalf("expected error for unknown kind")
+	}
+	if Kind(42).String() != "Kind(42)" {
+		t.Fatalf("got %q", Kind(42).String())
+	}
+}
+
+func TestUnmarshalWithKind(t *testing.T) {
+	v := map[string]interface{}{"a": "b"}
+	for _, kind := range []Kind{XML, ASCII, Binary, JSON} {
+		buf := new(bytes.Buffer)
+		err := NewSpecificEncoder(buf, kind).Encode(v)
+		if err != nil {
+			t.Fatalf("%v: %v", kind, err)
+		}
+
+		var decoded map[string]interface{}
+		got, err := UnmarshalWithKind(buf.Bytes(), &decoded)
+		if err != nil {
+			t.Fatalf("%v: %v", kind, err)
+		}
+		if got != kind {
+			t.Fatalf("got kind %v, expected %v", got, kind)
+		}
+		if decoded["a"] != "b" {
+			t.Fatalf("%v: got %v", kind, decoded)
+		}
+	}
+
+	dec := NewDecoder(bytes.NewBufferString("bplist17"))
+	if dec.Kind() != Unknown {
+		t.Fatalf("kind known before decoding")
+	}
+	var x interface{}
+	if dec.Decode(&x) == nil || dec.Kind() != Binary {
+		t.Fatalf("got kind %v", dec.Kind())
+	}
+	if NewSpecificDecoder(bytes.NewBufferString("()"), ASCII).Kind() != ASCII {
+		t.Fatalf("specific decoder does not report its kind")
+	}
+}
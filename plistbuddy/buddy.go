@@ -0,0 +1,593 @@
+// Package plistbuddy implements the command language of Apple's
+// PlistBuddy tool on top of the plist package.
+//
+// A Buddy holds a single plist file in memory. Commands such as
+// "Set :CFBundleVersion 42" are run against it with Exec, and the
+// result is written back with Save, using the kind of plist the
+// file was originally stored as.
+package plistbuddy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/internal/dictkeys"
+)
+
+// ErrExit is returned by Exec for the Exit and Quit commands.
+var ErrExit = errors.New("plistbuddy: exit")
+
+const help = `Command Format:
+    Help - Prints this information
+    Exit - Exits the program, changes are not saved to the file
+    Save - Saves the current changes to the file
+    Revert - Reloads the last saved version of the file
+    Clear [<Type>] - Clears out all existing entries, and creates root of Type
+    Print [<Entry>] - Prints value of Entry.  Otherwise, prints file
+    Set <Entry> <Value> - Sets the value at Entry to Value
+    Add <Entry> <Type> [<Value>] - Adds Entry to the plist, with value Value
+    Copy <EntrySrc> <EntryDst> - Copies the EntrySrc property to EntryDst
+    Delete <Entry> - Deletes Entry from the plist
+    Merge <file.plist> [<Entry>] - Adds the contents of file.plist to Entry
+    Import <Entry> <file> - Creates or sets Entry the contents of file
+
+Entry Format:
+    Entries consist of property key names delimited by colons.  Array items
+    are specified by a zero-based integer index.  Examples:
+        :CFBundleShortVersionString
+        :CFBundleDocumentTypes:2:CFBundleTypeExtensions
+    A colon that is part of a key name may be escaped with a backslash.
+
+Types:
+    string
+    array
+    dict
+    bool
+    real
+    integer
+    date
+    data
+`
+
+// A Buddy edits a single plist file using PlistBuddy's commands.
+type Buddy struct {
+	// Out receives the output of the Print and Help commands.
+	Out io.Writer
+	// XML makes Print output XML plists rather than
+	// PlistBuddy's own description format.
+	XML bool
+
+	path     string
+	kind     plist.Kind
+	root     interface{}
+	modified bool
+}
+
+// Open reads the plist file at path. If the file does not exist,
+// the Buddy starts out with an empty dict, and the file is created
+// as an XML plist when it is saved.
+func Open(path string) (*Buddy, error) {
+	b := &Buddy{
+		Out:  os.Stdout,
+		path: path,
+	}
+	err := b.Revert()
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Kind returns the kind of plist the file is saved as.
+func (b *Buddy) Kind() plist.Kind {
+	return b.kind
+}
+
+// Modified reports whether the plist has been changed
+// since it was last read or saved.
+func (b *Buddy) Modified() bool {
+	return b.modified
+}
+
+// Revert discards all changes and rereads the plist file.
+func (b *Buddy) Revert() error {
+	buf, err := ioutil.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		b.kind = plist.XML
+		b.root = map[string]interface{}{}
+		b.modified = false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var root interface{}
+	kind, err := plist.UnmarshalWithKind(buf, &root)
+	if err != nil {
+		return err
+	}
+	b.kind = kind
+	b.root = root
+	b.modified = false
+	return nil
+}
+
+// Save writes the plist back to its file, in the kind of
+// plist the file was read as.
+func (b *Buddy) Save() error {
+	err := plist.WriteFile(b.path, b.root, b.kind)
+	if err != nil {
+		return err
+	}
+	b.modified = false
+	return nil
+}
+
+// A commandError is an error reported by one of the commands.
+// Its message is prefixed by the name of the command, the way
+// PlistBuddy does.
+type commandError struct {
+	cmd string
+	msg string
+}
+
+func (e *commandError) Error() string {
+	return e.cmd + ": " + e.msg
+}
+
+// The commands understood by Exec. Command names are not
+// case sensitive.
+var commands = map[string]string{
+	"help":   "Help",
+	"exit":   "Exit",
+	"quit":   "Quit",
+	"bye":    "Bye",
+	"save":   "Save",
+	"revert": "Revert",
+	"clear":  "Clear",
+	"print":  "Print",
+	"set":    "Set",
+	"add":    "Add",
+	"copy":   "Copy",
+	"delete": "Delete",
+	"merge":  "Merge",
+	"import": "Import",
+}
+
+// Exec runs a single command, such as "Print :CFBundleVersion".
+func (b *Buddy) Exec(command string) error {
+	args, err := splitCommand(command)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	name, ok := commands[strings.ToLower(args[0])]
+	if !ok {
+		return fmt.Errorf("Unrecognized Command: %s", args[0])
+	}
+	fail := func(format string, a ...interface{}) error {
+		return &commandError{name, fmt.Sprintf(format, a...)}
+	}
+
+	switch name {
+	case "Help":
+		_, err = io.WriteString(b.Out, help)
+		return err
+	case "Exit", "Quit", "Bye":
+		return ErrExit
+	case "Save":
+		return b.Save()
+	case "Revert":
+		return b.Revert()
+	case "Clear":
+		typ := "dict"
+		if len(args) > 1 {
+			typ = strings.ToLower(args[1])
+		}
+		switch typ {
+		case "dict", "dictionary":
+			b.root = map[string]interface{}{}
+		case "array":
+			b.root = []interface{}{}
+		default:
+			return fail("Invalid Type %q", args[1])
+		}
+		b.modified = true
+		return nil
+	case "Print":
+		entry := ""
+		if len(args) > 1 {
+			entry = args[1]
+		}
+		v, ok := lookup(b.root, entry)
+		if !ok {
+			return fail("Entry, %q, Does Not Exist", entry)
+		}
+		return b.print(v)
+	case "Set":
+		if len(args) < 3 {
+			return fail("Invalid Arguments")
+		}
+		return b.set(args[1], strings.Join(args[2:], " "), fail)
+	case "Add":
+		if len(args) < 3 {
+			return fail("Invalid Arguments")
+		}
+		return b.add(args[1], args[2], strings.Join(args[3:], " "), fail)
+	case "Copy":
+		if len(args) != 3 {
+			return fail("Invalid Arguments")
+		}
+		v, ok := lookup(b.root, args[1])
+		if !ok {
+			return fail("Entry, %q, Does Not Exist", args[1])
+		}
+		return b.insert(args[2], copyValue(v), fail)
+	case "Delete":
+		if len(args) != 2 {
+			return fail("Invalid Arguments")
+		}
+		return b.delete(args[1], fail)
+	case "Merge":
+		if len(args) < 2 || len(args) > 3 {
+			return fail("Invalid Arguments")
+		}
+		entry := ""
+		if len(args) == 3 {
+			entry = args[2]
+		}
+		return b.merge(args[1], entry, fail)
+	case "Import":
+		if len(args) != 3 {
+			return fail("Invalid Arguments")
+		}
+		buf, err := ioutil.ReadFile(args[2])
+		if err != nil {
+			return fail("Error Reading File: %s", args[2])
+		}
+		return b.store(args[1], buf, fail)
+	}
+
+	panic("unreachable")
+}
+
+// splitCommand splits a command line into its arguments. Arguments
+// are separated by whitespace, and can be quoted using single or
+// double quotes. A backslash escapes the character following it,
+// except for colons, which are left for splitEntry to handle.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var cur []byte
+	inArg := false
+	var quote byte
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case c == '\\' && i+1 < len(command):
+			i++
+			if command[i] == ':' {
+				cur = append(cur, '\\')
+			}
+			cur = append(cur, command[i])
+			inArg = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			cur = append(cur, c)
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, string(cur))
+				cur = nil
+				inArg = false
+			}
+		default:
+			cur = append(cur, c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("Unterminated Quote")
+	}
+	if inArg {
+		args = append(args, string(cur))
+	}
+	return args, nil
+}
+
+// set replaces the value at entry, parsing value according
+// to the type of the existing value.
+func (b *Buddy) set(entry, value string, fail func(string, ...interface{}) error) error {
+	old, ok := lookup(b.root, entry)
+	if !ok {
+		return fail("Entry, %q, Does Not Exist", entry)
+	}
+	typ := typeName(old)
+	if typ == "dict" || typ == "array" {
+		return fail("Cannot Perform Set On Containers")
+	}
+	v, err := parseValue(typ, value)
+	if err != nil {
+		return fail("%v", err)
+	}
+	return b.store(entry, v, fail)
+}
+
+// add adds a new value of type typ at entry.
+func (b *Buddy) add(entry, typ, value string, fail func(string, ...interface{}) error) error {
+	typ = strings.ToLower(typ)
+	if typ == "dictionary" {
+		typ = "dict"
+	}
+	v, err := parseValue(typ, value)
+	if err != nil {
+		return fail("%v", err)
+	}
+	return b.insert(entry, v, fail)
+}
+
+// insert stores v at entry, which must not exist yet. The last
+// component of entry may be an array index, in which case v is
+// inserted at that index. An empty last component appends v to
+// an array.
+func (b *Buddy) insert(entry string, v interface{}, fail func(string, ...interface{}) error) error {
+	comps := splitEntry(entry)
+	if len(comps) == 0 {
+		return fail("%q Entry Already Exists", entry)
+	}
+	parentPath := plist.JoinKeyPath(comps[:len(comps)-1]...)
+	parent, err := plist.Get(b.root, parentPath)
+	if err != nil {
+		return fail("Entry, %q, Does Not Exist", entry)
+	}
+
+	if a, ok := parent.([]interface{}); ok {
+		i := len(a)
+		if last := comps[len(comps)-1]; last != "" {
+			i, err = strconv.Atoi(last)
+			if err != nil || i < 0 || i > len(a) {
+				return fail("Entry, %q, Does Not Exist", entry)
+			}
+		}
+		a = append(a, nil)
+		copy(a[i+1:], a[i:])
+		a[i] = v
+		return b.storePath(parentPath, entry, a, fail)
+	}
+
+	if plist.Exists(b.root, keyPath(entry)) {
+		return fail("%q Entry Already Exists", entry)
+	}
+	return b.store(entry, v, fail)
+}
+
+// store stores v at entry, replacing any existing value.
+func (b *Buddy) store(entry string, v interface{}, fail func(string, ...interface{}) error) error {
+	return b.storePath(keyPath(entry), entry, v, fail)
+}
+
+// storePath stores v at the key path path, replacing any existing
+// value. Errors are reported using entry.
+func (b *Buddy) storePath(path, entry string, v interface{}, fail func(string, ...interface{}) error) error {
+	err := plist.Set(&b.root, path, v)
+	if err != nil {
+		return fail("Entry, %q, Does Not Exist", entry)
+	}
+	b.modified = true
+	return nil
+}
+
+// delete removes the value at entry.
+func (b *Buddy) delete(entry string, fail func(string, ...interface{}) error) error {
+	if len(splitEntry(entry)) == 0 {
+		return fail("Cannot Delete Root Entry")
+	}
+	err := plist.Delete(&b.root, keyPath(entry))
+	if err != nil {
+		return fail("Entry, %q, Does Not Exist", entry)
+	}
+	b.modified = true
+	return nil
+}
+
+// merge adds the contents of the plist file at path to the
+// dict or array at entry. Keys that already exist in a dict
+// are left untouched and reported to Out.
+func (b *Buddy) merge(path, entry string, fail func(string, ...interface{}) error) error {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fail("Error Reading File: %s", path)
+	}
+	var src interface{}
+	err = plist.Unmarshal(buf, &src)
+	if err != nil {
+		return fail("Error Reading File: %s", path)
+	}
+
+	target, ok := lookup(b.root, entry)
+	if !ok {
+		return fail("Entry, %q, Does Not Exist", entry)
+	}
+
+	switch t := target.(type) {
+	case map[string]interface{}:
+		m, ok := src.(map[string]interface{})
+		if !ok {
+			return fail("Can't Add %s Entries to a Dict", typeName(src))
+		}
+		for k, v := range m {
+			if _, exists := t[k]; exists {
+				fmt.Fprintf(b.Out, "Merge: Entry %q Already Exists\n", entry+":"+k)
+				continue
+			}
+			t[k] = copyValue(v)
+		}
+		b.modified = true
+		return nil
+	case []interface{}:
+		if a, ok := src.([]interface{}); ok {
+			for _, v := range a {
+				t = append(t, copyValue(v))
+			}
+		} else {
+			t = append(t, copyValue(src))
+		}
+		return b.store(entry, t, fail)
+	}
+	return fail("Can't Merge Into a Non-Container Entry")
+}
+
+// copyValue returns a deep copy of the plist value v.
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			m[k] = copyValue(elem)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(val))
+		for i, elem := range val {
+			a[i] = copyValue(elem)
+		}
+		return a
+	case []byte:
+		return append([]byte(nil), val...)
+	}
+	return v
+}
+
+// typeName returns PlistBuddy's name for the type of v.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "dict"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "real"
+	case time.Time:
+		return "date"
+	case []byte:
+		return "data"
+	}
+	return "unknown"
+}
+
+// The date formats accepted by Set and Add.
+var dateFormats = []string{
+	time.UnixDate,
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02",
+}
+
+// parseValue parses value as a plist value of type typ.
+func parseValue(typ, value string) (interface{}, error) {
+	switch typ {
+	case "string":
+		return value, nil
+	case "dict":
+		return map[string]interface{}{}, nil
+	case "array":
+		return []interface{}{}, nil
+	case "bool":
+		switch strings.ToLower(value) {
+		case "true", "yes", "1", "":
+			return true, nil
+		case "false", "no", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("Invalid Bool Value: %s", value)
+	case "real":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Real Value: %s", value)
+		}
+		return f, nil
+	case "integer":
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Integer Value: %s", value)
+		}
+		return i, nil
+	case "date":
+		for _, layout := range dateFormats {
+			t, err := time.Parse(layout, value)
+			if err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return nil, fmt.Errorf("Invalid Date Value: %s", value)
+	case "data":
+		return []byte(value), nil
+	}
+	return nil, fmt.Errorf("Unrecognized Type: %s", typ)
+}
+
+// print writes v to Out, either as an XML plist or in
+// PlistBuddy's description format.
+func (b *Buddy) print(v interface{}) error {
+	if b.XML {
+		return plist.NewEncoder(b.Out).Encode(v)
+	}
+	buf := new(bytes.Buffer)
+	describe(buf, v, 0)
+	buf.WriteByte('\n')
+	_, err := b.Out.Write(buf.Bytes())
+	return err
+}
+
+// describe writes v in PlistBuddy's description format.
+func describe(buf *bytes.Buffer, v interface{}, depth int) {
+	indent := strings.Repeat("    ", depth)
+	switch val := v.(type) {
+	case map[string]interface{}:
+		buf.WriteString("Dict {\n")
+		for _, k := range dictkeys.Sorted(val) {
+			buf.WriteString(indent + "    " + k + " = ")
+			describe(buf, val[k], depth+1)
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+	case []interface{}:
+		buf.WriteString("Array {\n")
+		for _, elem := range val {
+			buf.WriteString(indent + "    ")
+			describe(buf, elem, depth+1)
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+	case float64:
+		buf.WriteString(strconv.FormatFloat(val, 'f', 6, 64))
+	case time.Time:
+		buf.WriteString(val.UTC().Format(time.UnixDate))
+	case []byte:
+		buf.Write(val)
+	case string:
+		buf.WriteString(val)
+	default:
+		fmt.Fprint(buf, val)
+	}
+}
@@ -0,0 +1,199 @@
+package plistbuddy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mkrautz/plist"
+)
+
+// openCopy copies the named file into a temporary directory
+// and opens the copy.
+func openCopy(t *testing.T, name string) (*Buddy, string) {
+	buf, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	path := filepath.Join(t.TempDir(), filepath.Base(name))
+	err = ioutil.WriteFile(path, buf, 0644)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	b, err := Open(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	b.Out = new(bytes.Buffer)
+	return b, path
+}
+
+func TestEditAndSave(t *testing.T) {
+	b, path := openCopy(t, "../xmlplist/testdata/RecursiveEntitlements.plist")
+
+	commands := []string{
+		"Set :Entitlements:get-task-allow false",
+		"Add :CFBundleVersion string 42",
+		`Add :CFBundleName string "Hello World"`,
+		"Add :Groups array",
+		"Add :Groups:0 string b",
+		"Add :Groups:0 string a",
+		"Copy :Entitlements :Copied",
+		"Delete :get-task-allow",
+		"Add :com.example\\:key integer 7",
+		"Add :com.example.groups array",
+		"Add :com.example.groups:0 string x",
+	}
+	for _, cmd := range commands {
+		err := b.Exec(cmd)
+		if err != nil {
+			t.Fatalf("%v: %v", cmd, err)
+		}
+	}
+	err := b.Save()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var actual map[string]interface{}
+	err = plist.Unmarshal(buf, &actual)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := map[string]interface{}{
+		"Entitlements": map[string]interface{}{
+			"get-task-allow": false,
+		},
+		"Copied": map[string]interface{}{
+			"get-task-allow": false,
+		},
+		"CFBundleVersion":    "42",
+		"CFBundleName":       "Hello World",
+		"Groups":             []interface{}{"a", "b"},
+		"com.example:key":    int64(7),
+		"com.example.groups": []interface{}{"x"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("got %#v, expected %#v", actual, expected)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	b, _ := openCopy(t, "../xmlplist/testdata/RecursiveEntitlements.plist")
+
+	errTests := []struct {
+		Command string
+		Error   string
+	}{
+		{"Set :Missing 1", `Set: Entry, ":Missing", Does Not Exist`},
+		{"Set :Entitlements 1", "Set: Cannot Perform Set On Containers"},
+		{"Set :get-task-allow maybe", "Set: Invalid Bool Value: maybe"},
+		{"Add :get-task-allow bool true", `Add: ":get-task-allow" Entry Already Exists`},
+		{"Add :Missing:Key string x", `Add: Entry, ":Missing:Key", Does Not Exist`},
+		{"Delete :Missing", `Delete: Entry, ":Missing", Does Not Exist`},
+		{"Frobnicate", "Unrecognized Command: Frobnicate"},
+	}
+	for _, et := range errTests {
+		err := b.Exec(et.Command)
+		if err == nil || err.Error() != et.Error {
+			t.Errorf("%v: expected error %q, got %v", et.Command, et.Error, err)
+		}
+	}
+	if b.Modified() {
+		t.Fatalf("failed commands modified the plist")
+	}
+	if b.Exec("Exit") != ErrExit {
+		t.Fatalf("Exit did not return ErrExit")
+	}
+}
+
+func TestPrint(t *testing.T) {
+	b, _ := openCopy(t, "../xmlplist/testdata/DecodeEverything.plist")
+
+	err := b.Exec("Print")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := `Array {
+    42
+    50.000000
+    Sun Jan 29 13:07:25 UTC 2012
+    ` + "\xff\xff\xff" + `
+    hello
+    Dict {
+        hey = ok
+    }
+}
+`
+	out := b.Out.(*bytes.Buffer)
+	if out.String() != expected {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	out.Reset()
+	err = b.Exec("print :5:hey")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if out.String() != "ok\n" {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestMergeAndRevert(t *testing.T) {
+	b, _ := openCopy(t, "../xmlplist/testdata/Entitlements.plist")
+
+	err := b.Exec("Clear dict")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = b.Exec("Merge ../xmlplist/testdata/RecursiveEntitlements.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, ok := lookup(b.root, ":Entitlements:get-task-allow"); !ok {
+		t.Fatalf("merge did not add nested dict")
+	}
+
+	err = b.Exec("Revert")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, ok := lookup(b.root, ":Entitlements"); ok {
+		t.Fatalf("revert did not discard changes")
+	}
+	if b.Modified() {
+		t.Fatalf("modified after revert")
+	}
+}
+
+func TestSaveKeepsKind(t *testing.T) {
+	b, path := openCopy(t, "../asciiplist/testdata/Dict.plist")
+	if b.Kind() != plist.ASCII {
+		t.Fatalf("expected ASCII kind, got %v", b.Kind())
+	}
+
+	err := b.Exec("Set :hey 5")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	err = b.Save()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if buf[0] != '{' {
+		t.Fatalf("saved file is not an ASCII plist:\n%s", buf)
+	}
+}
@@ -0,0 +1,46 @@
+package plistbuddy
+
+import (
+	"strings"
+
+	"github.com/mkrautz/plist"
+)
+
+// splitEntry splits an entry such as :CFBundleURLTypes:0:CFBundleURLSchemes
+// into its components. The leading colon is optional, and a colon that is
+// part of a key can be escaped with a backslash. The root entry has no
+// components.
+func splitEntry(entry string) []string {
+	entry = strings.TrimPrefix(entry, ":")
+	if entry == "" {
+		return nil
+	}
+
+	var comps []string
+	var cur []byte
+	for i := 0; i < len(entry); i++ {
+		c := entry[i]
+		switch {
+		case c == '\\' && i+1 < len(entry) && (entry[i+1] == ':' || entry[i+1] == '\\'):
+			i++
+			cur = append(cur, entry[i])
+		case c == ':':
+			comps = append(comps, string(cur))
+			cur = nil
+		default:
+			cur = append(cur, c)
+		}
+	}
+	return append(comps, string(cur))
+}
+
+// keyPath converts an entry into the equivalent plist key path.
+func keyPath(entry string) string {
+	return plist.JoinKeyPath(splitEntry(entry)...)
+}
+
+// lookup returns the value found at entry in v.
+func lookup(v interface{}, entry string) (interface{}, bool) {
+	val, err := plist.Get(v, keyPath(entry))
+	return val, err == nil
+}
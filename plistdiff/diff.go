@@ -0,0 +1,176 @@
+// Package plistdiff compares plists semantically.
+//
+// Rather than comparing the text of two documents, plistdiff decodes
+// them and compares the resulting values, so that key order, formatting
+// and plist kind make no difference. Each difference is reported as a
+// Change at a key path, in the syntax understood by plist.Get.
+package plistdiff
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/internal/dictkeys"
+	"github.com/mkrautz/plist/internal/keypath"
+)
+
+// A ChangeKind describes how a value differs between two plists.
+type ChangeKind int
+
+const (
+	Added   ChangeKind = iota // the value only exists in the new plist
+	Removed                   // the value only exists in the old plist
+	Changed                   // the value exists in both, but differs
+)
+
+// String returns the name of the change kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// MarshalText lets a ChangeKind appear by name in JSON output.
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// A Change is a single difference between two plists.
+// Old is nil for added values, and New is nil for removed values.
+type Change struct {
+	Kind ChangeKind  `json:"kind"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// A Result holds the differences between two plists, ordered
+// by their position in the documents. Dict keys are visited in
+// sorted order.
+type Result struct {
+	Changes []Change `json:"changes"`
+}
+
+// Equal reports whether the two plists were found to be equal.
+func (r *Result) Equal() bool {
+	return len(r.Changes) == 0
+}
+
+// Diff decodes two plists of any kind and compares them.
+func Diff(oldData, newData []byte) (*Result, error) {
+	var oldVal, newVal interface{}
+	err := plist.Unmarshal(oldData, &oldVal)
+	if err != nil {
+		return nil, err
+	}
+	err = plist.Unmarshal(newData, &newVal)
+	if err != nil {
+		return nil, err
+	}
+	return Compare(oldVal, newVal), nil
+}
+
+// Compare compares two decoded plist values, as produced by
+// unmarshaling into an interface{}.
+//
+// Values are only equal if they have the same type: an integer
+// never equals a real. Arrays are compared element by element.
+func Compare(oldVal, newVal interface{}) *Result {
+	r := new(Result)
+	r.compare("", oldVal, newVal)
+	return r
+}
+
+func (r *Result) add(kind ChangeKind, path string, oldVal, newVal interface{}) {
+	r.Changes = append(r.Changes, Change{
+		Kind: kind,
+		Path: path,
+		Old:  oldVal,
+		New:  newVal,
+	})
+}
+
+// compare appends the differences between oldVal and newVal,
+// both found at path.
+func (r *Result) compare(path string, oldVal, newVal interface{}) {
+	switch o := oldVal.(type) {
+	case map[string]interface{}:
+		n, ok := newVal.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range unionKeys(o, n) {
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			kp := plist.ChildKeyPath(path, k)
+			switch {
+			case !inNew:
+				r.add(Removed, kp, ov, nil)
+			case !inOld:
+				r.add(Added, kp, nil, nv)
+			default:
+				r.compare(kp, ov, nv)
+			}
+		}
+		return
+	case []interface{}:
+		n, ok := newVal.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(o) || i < len(n); i++ {
+			ip := keypath.Index(path, i)
+			switch {
+			case i >= len(n):
+				r.add(Removed, ip, o[i], nil)
+			case i >= len(o):
+				r.add(Added, ip, nil, n[i])
+			default:
+				r.compare(ip, o[i], n[i])
+			}
+		}
+		return
+	}
+
+	if !equal(oldVal, newVal) {
+		r.add(Changed, path, oldVal, newVal)
+	}
+}
+
+// equal reports whether two scalar plist values are equal.
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case time.Time:
+		y, ok := b.(time.Time)
+		return ok && x.Equal(y)
+	case []byte:
+		y, ok := b.([]byte)
+		return ok && bytes.Equal(x, y)
+	case float64:
+		// NaN reals are considered equal to each other.
+		y, ok := b.(float64)
+		return ok && (x == y || x != x && y != y)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// unionKeys returns the keys of both dicts in sorted order.
+func unionKeys(a, b map[string]interface{}) []string {
+	union := make(map[string]interface{}, len(a)+len(b))
+	for k := range a {
+		union[k] = nil
+	}
+	for k := range b {
+		union[k] = nil
+	}
+	return dictkeys.Sorted(union)
+}
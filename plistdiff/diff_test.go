@@ -0,0 +1,86 @@
+package plistdiff
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/binaryplist"
+)
+
+func TestDiffAcrossKinds(t *testing.T) {
+	xmlBuf, err := ioutil.ReadFile("../xmlplist/testdata/RecursiveEntitlements.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var v interface{}
+	err = plist.Unmarshal(xmlBuf, &v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	binBuf, err := binaryplist.Marshal(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	r, err := Diff(xmlBuf, binBuf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !r.Equal() {
+		t.Fatalf("expected no changes, got:\n%v", r)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	oldVal := map[string]interface{}{
+		"CFBundleVersion": "41",
+		"Removed":         true,
+		"Count":           int64(1),
+		"com.example.key": []interface{}{"a", "b"},
+		"Nested":          map[string]interface{}{"x": []byte{1}},
+	}
+	newVal := map[string]interface{}{
+		"CFBundleVersion": "42",
+		"Added":           map[string]interface{}{"y": int64(2)},
+		"Count":           float64(1),
+		"com.example.key": []interface{}{"a"},
+		"Nested":          map[string]interface{}{"x": []byte{1}},
+	}
+
+	r := Compare(oldVal, newVal)
+	expected := []Change{
+		{Added, "Added", nil, map[string]interface{}{"y": int64(2)}},
+		{Changed, "CFBundleVersion", "41", "42"},
+		{Changed, "Count", int64(1), float64(1)},
+		{Removed, "Removed", true, nil},
+		{Removed, `com\.example\.key[1]`, "b", nil},
+	}
+	if !reflect.DeepEqual(r.Changes, expected) {
+		t.Fatalf("got %#v, expected %#v", r.Changes, expected)
+	}
+
+	for _, c := range r.Changes {
+		if c.Old != nil {
+			v, err := plist.Get(oldVal, c.Path)
+			if err != nil || !reflect.DeepEqual(v, c.Old) {
+				t.Errorf("%v does not address old value: %v", c.Path, err)
+			}
+		}
+	}
+
+	text := `--- a
++++ b
++Added = { "y" = 2; }
+-CFBundleVersion = string "41"
++CFBundleVersion = string "42"
+-Count = integer 1
++Count = real 1
+-Removed = boolean true
+-com\.example\.key[1] = string "b"
+`
+	if r.String() != text {
+		t.Fatalf("unexpected text:\n%v", r)
+	}
+}
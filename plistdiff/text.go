@@ -0,0 +1,96 @@
+package plistdiff
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist/internal/dictkeys"
+	"github.com/mkrautz/plist/internal/typename"
+)
+
+// WriteText writes the result to w in the style of a unified diff.
+// Removed values are prefixed with '-', added values with '+', and
+// a changed value is written as a removal followed by an addition:
+//
+//	--- a/Info.plist
+//	+++ b/Info.plist
+//	-CFBundleVersion = string "41"
+//	+CFBundleVersion = string "42"
+//	+LSMinimumSystemVersion = string "10.13"
+//
+// Nothing is written if the plists are equal.
+func (r *Result) WriteText(w io.Writer, oldName, newName string) error {
+	if r.Equal() {
+		return nil
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "--- %s\n+++ %s\n", oldName, newName)
+	for _, c := range r.Changes {
+		path := c.Path
+		if path == "" {
+			path = "<root>"
+		}
+		if c.Kind != Added {
+			fmt.Fprintf(bw, "-%s = %s\n", path, formatValue(c.Old))
+		}
+		if c.Kind != Removed {
+			fmt.Fprintf(bw, "+%s = %s\n", path, formatValue(c.New))
+		}
+	}
+	return bw.Flush()
+}
+
+// String returns the result as written by WriteText, with
+// the old and new plists named "a" and "b".
+func (r *Result) String() string {
+	var sb strings.Builder
+	r.WriteText(&sb, "a", "b")
+	return sb.String()
+}
+
+// formatValue formats a value along with its plist type.
+func formatValue(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return format(v)
+	}
+	return typename.Of(v) + " " + format(v)
+}
+
+// format formats a value on a single line. Dicts and arrays
+// are written in the style of ASCII plists.
+func format(v interface{}) string {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		keys := dictkeys.Sorted(val)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = strconv.Quote(k) + " = " + format(val[k]) + ";"
+		}
+		if len(parts) == 0 {
+			return "{}"
+		}
+		return "{ " + strings.Join(parts, " ") + " }"
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, elem := range val {
+			parts[i] = format(elem)
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	case string:
+		return strconv.Quote(val)
+	case float64:
+		return strconv.FormatFloat(val, 'g', -1, 64)
+	case time.Time:
+		return val.UTC().Format(time.RFC3339)
+	case []byte:
+		return "<" + hex.EncodeToString(val) + ">"
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,247 @@
+package plistedit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A token is an XML token and the range of the source it was read from.
+type token struct {
+	tok        xml.Token
+	start, end int
+}
+
+// A parser builds the tree of a document from its tokens.
+type parser struct {
+	src  string
+	toks []token
+	i    int
+	unit string
+}
+
+// Parse parses an XML plist.
+func Parse(data []byte) (*Document, error) {
+	p := &parser{src: string(data)}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		start := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		p.toks = append(p.toks, token{xml.CopyToken(tok), start, int(dec.InputOffset())})
+	}
+
+	// Find the root value inside <plist>.
+	inPlist := false
+	for ; p.i < len(p.toks); p.i++ {
+		se, ok := p.toks[p.i].tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if !inPlist && se.Name.Local == "plist" {
+			inPlist = true
+			continue
+		}
+		if !inPlist {
+			return nil, fmt.Errorf("plist: unexpected <%s>, expected <plist>", se.Name.Local)
+		}
+		break
+	}
+	if p.i == len(p.toks) {
+		return nil, p.errorf("no root value found")
+	}
+
+	d := new(Document)
+	start := p.toks[p.i].start
+	d.head = p.src[:start]
+	root, end, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if root.kind != Dict && root.kind != Array {
+		return nil, p.errorf("bad root element: must be dict or array")
+	}
+	d.root = root
+	d.tail = p.src[end:]
+	d.unit = p.unit
+	if d.unit == "" {
+		d.unit = "\t"
+	}
+	return d, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line := 1
+	if p.i < len(p.toks) {
+		line += strings.Count(p.src[:p.toks[p.i].start], "\n")
+	}
+	return fmt.Errorf("plist: xml line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// skip skips whitespace, comments and processing instructions, and
+// returns the next token, which is a start or end element.
+func (p *parser) skip() (token, error) {
+	for ; p.i < len(p.toks); p.i++ {
+		t := p.toks[p.i]
+		switch tok := t.tok.(type) {
+		case xml.Comment, xml.ProcInst:
+			continue
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) == 0 {
+				continue
+			}
+			return t, p.errorf("unexpected text %q", strings.TrimSpace(string(tok)))
+		case xml.Directive:
+			return t, p.errorf("unexpected <!%s>", tok)
+		}
+		return t, nil
+	}
+	return token{}, p.errorf("unexpected end of document")
+}
+
+// selfClosing reports whether the start element at p.i was written
+// as <name/>, in which case the decoder reports an end element that
+// takes up no space.
+func (p *parser) selfClosing() bool {
+	if p.i+1 >= len(p.toks) {
+		return false
+	}
+	next := p.toks[p.i+1]
+	_, ok := next.tok.(xml.EndElement)
+	return ok && next.start == next.end
+}
+
+// value parses the value whose start element is at p.i, and returns
+// it and the offset of its end.
+func (p *parser) value() (*Node, int, error) {
+	t := p.toks[p.i]
+	se := t.tok.(xml.StartElement)
+	n := &Node{indent: lineIndent(p.src[:t.start], "")}
+	switch se.Name.Local {
+	case "dict":
+		n.kind = Dict
+	case "array":
+		n.kind = Array
+	case "string":
+		n.kind = String
+	case "integer":
+		n.kind = Integer
+	case "real":
+		n.kind = Real
+	case "true", "false":
+		n.kind = Boolean
+	case "date":
+		n.kind = Date
+	case "data":
+		n.kind = Data
+	default:
+		return nil, 0, p.errorf("unexpected <%s>", se.Name.Local)
+	}
+	if n.kind == Dict || n.kind == Array {
+		return p.container(n)
+	}
+
+	n.name = se.Name.Local
+	var text strings.Builder
+	for p.i++; p.i < len(p.toks); p.i++ {
+		switch tok := p.toks[p.i].tok.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.StartElement:
+			return nil, 0, p.errorf("unexpected <%s> in <%s>", tok.Name.Local, n.name)
+		case xml.EndElement:
+			end := p.toks[p.i].end
+			p.i++
+			n.text = text.String()
+			n.raw = p.src[t.start:end]
+			return n, end, nil
+		}
+	}
+	return nil, 0, p.errorf("unexpected end of document")
+}
+
+// container parses the dict or array n, whose start element is at p.i.
+func (p *parser) container(n *Node) (*Node, int, error) {
+	t := p.toks[p.i]
+	n.open = p.src[t.start:t.end]
+	if p.selfClosing() {
+		p.i += 2
+		return n, t.end, nil
+	}
+
+	pos := t.end
+	p.i++
+	for {
+		next, err := p.skip()
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, ok := next.tok.(xml.EndElement); ok {
+			n.trailing = p.src[pos:next.start]
+			n.close = p.src[next.start:next.end]
+			p.i++
+			return n, next.end, nil
+		}
+
+		it := &item{leading: p.src[pos:next.start]}
+		if n.kind == Dict {
+			se := next.tok.(xml.StartElement)
+			if se.Name.Local != "key" {
+				return nil, 0, p.errorf("expected <key>, got <%s>", se.Name.Local)
+			}
+			keyEnd, err := p.key(it)
+			if err != nil {
+				return nil, 0, err
+			}
+			next, err = p.skip()
+			if err != nil {
+				return nil, 0, err
+			}
+			if _, ok := next.tok.(xml.StartElement); !ok {
+				return nil, 0, p.errorf("missing value for key %q", it.key)
+			}
+			it.between = p.src[keyEnd:next.start]
+		}
+		if p.unit == "" && strings.Contains(it.leading, "\n") {
+			indent := lineIndent(it.leading, "")
+			if len(indent) > len(n.indent) && strings.HasPrefix(indent, n.indent) {
+				p.unit = indent[len(n.indent):]
+			}
+		}
+
+		it.value, pos, err = p.value()
+		if err != nil {
+			return nil, 0, err
+		}
+		n.items = append(n.items, it)
+	}
+}
+
+// key parses the key element at p.i into it, and returns the offset
+// of its end.
+func (p *parser) key(it *item) (int, error) {
+	start := p.toks[p.i].start
+	var text strings.Builder
+	for p.i++; p.i < len(p.toks); p.i++ {
+		switch tok := p.toks[p.i].tok.(type) {
+		case xml.CharData:
+			text.Write(tok)
+		case xml.StartElement:
+			return 0, p.errorf("unexpected <%s> in <key>", tok.Name.Local)
+		case xml.EndElement:
+			end := p.toks[p.i].end
+			p.i++
+			it.key = text.String()
+			it.keyRaw = p.src[start:end]
+			return end, nil
+		}
+	}
+	return 0, p.errorf("unexpected end of document")
+}
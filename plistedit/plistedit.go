@@ -0,0 +1,422 @@
+// Package plistedit edits XML plists in place, keeping their comments
+// and formatting.
+//
+// Parse reads an XML plist into a concrete syntax tree that remembers
+// the source of every element and of the whitespace and comments
+// between them. Values are changed with Set and Delete, addressed by
+// key paths as in the plist package, and Bytes writes the document
+// back. Regions of the document that were not edited are written
+// byte for byte as they were read; new values are indented to match
+// their surroundings.
+//
+//	doc, err := plistedit.Parse(data)
+//	...
+//	err = doc.Set("CFBundleVersion", "42")
+//	...
+//	data = doc.Bytes()
+package plistedit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/xmlplist"
+)
+
+// A Kind is the type of a plist value.
+type Kind int
+
+const (
+	Dict Kind = iota
+	Array
+	String
+	Integer
+	Real
+	Boolean
+	Date
+	Data
+)
+
+var kindNames = []string{"dict", "array", "string", "integer", "real", "boolean", "date", "data"}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "Kind(" + strconv.Itoa(int(k)) + ")"
+	}
+	return kindNames[k]
+}
+
+// A Document is a parsed XML plist.
+type Document struct {
+	head string // up to the root value, including <plist>
+	root *Node
+	tail string // after the root value, including </plist>
+	unit string // one level of indentation
+}
+
+// A Node is a value in a Document.
+type Node struct {
+	kind   Kind
+	indent string // indentation of the line the element starts on
+
+	// Dicts and arrays.
+	open     string // "<dict>", or "<dict/>" when empty
+	items    []*item
+	trailing string // before the closing tag
+	close    string
+
+	// Scalars.
+	raw  string // the whole element
+	text string // its character data
+	name string // the element name, which tells true from false
+}
+
+// An item is an element of a dict or array, with the whitespace and
+// comments that precede it.
+type item struct {
+	leading string
+	key     string // dicts only
+	keyRaw  string
+	between string // between the key and the value
+	value   *Node
+}
+
+// Kind returns the kind of the value of n.
+func (n *Node) Kind() Kind {
+	return n.kind
+}
+
+// Len returns the number of elements of a dict or array.
+func (n *Node) Len() int {
+	return len(n.items)
+}
+
+// Keys returns the keys of a dict, in document order.
+func (n *Node) Keys() []string {
+	var keys []string
+	for _, it := range n.items {
+		keys = append(keys, it.key)
+	}
+	return keys
+}
+
+// Root returns the root value of the document.
+func (d *Document) Root() *Node {
+	return d.root
+}
+
+// Bytes returns the XML of the document.
+func (d *Document) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteString(d.head)
+	d.root.write(buf)
+	buf.WriteString(d.tail)
+	return buf.Bytes()
+}
+
+func (n *Node) write(buf *bytes.Buffer) {
+	if n.kind != Dict && n.kind != Array {
+		buf.WriteString(n.raw)
+		return
+	}
+	buf.WriteString(n.open)
+	for _, it := range n.items {
+		buf.WriteString(it.leading)
+		if n.kind == Dict {
+			buf.WriteString(it.keyRaw)
+			buf.WriteString(it.between)
+		}
+		it.value.write(buf)
+	}
+	buf.WriteString(n.trailing)
+	buf.WriteString(n.close)
+}
+
+// Value returns the value of n, in the form plist.Unmarshal
+// decodes into an interface{}.
+func (n *Node) Value() (interface{}, error) {
+	switch n.kind {
+	case Dict:
+		dict := make(map[string]interface{}, len(n.items))
+		for _, it := range n.items {
+			v, err := it.value.Value()
+			if err != nil {
+				return nil, err
+			}
+			dict[it.key] = v
+		}
+		return dict, nil
+	case Array:
+		var array []interface{}
+		for _, it := range n.items {
+			v, err := it.value.Value()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, v)
+		}
+		return array, nil
+	case String:
+		return n.text, nil
+	case Integer:
+		i, err := strconv.ParseInt(strings.TrimSpace(n.text), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: bad integer %q", n.text)
+		}
+		return i, nil
+	case Real:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n.text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("plist: bad real %q", n.text)
+		}
+		return f, nil
+	case Boolean:
+		return n.name == "true", nil
+	case Date:
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(n.text))
+		if err != nil {
+			return nil, fmt.Errorf("plist: bad date %q", n.text)
+		}
+		return t, nil
+	case Data:
+		b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(n.text), ""))
+		if err != nil {
+			return nil, fmt.Errorf("plist: bad data: %v", err)
+		}
+		return b, nil
+	}
+	return nil, errors.New("plist: bad node")
+}
+
+// Value returns the value of the whole document.
+func (d *Document) Value() (interface{}, error) {
+	return d.root.Value()
+}
+
+// lookup returns the index of the item of n that comp names, and
+// whether it exists. For a missing dict key, the index is where the
+// item would be added.
+func (n *Node) lookup(comp string) (int, bool) {
+	switch n.kind {
+	case Dict:
+		for i, it := range n.items {
+			if it.key == comp {
+				return i, true
+			}
+		}
+		return len(n.items), false
+	case Array:
+		i, err := strconv.Atoi(comp)
+		if err != nil || i < 0 {
+			return -1, false
+		}
+		return i, i < len(n.items)
+	}
+	return -1, false
+}
+
+// parent returns the container holding the value at path, and the
+// last component of the path.
+func (d *Document) parent(path string) (*Node, string, error) {
+	comps, err := plist.SplitKeyPath(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(comps) == 0 {
+		return nil, "", nil
+	}
+	n := d.root
+	for _, comp := range comps[:len(comps)-1] {
+		i, ok := n.lookup(comp)
+		if !ok {
+			return nil, "", &plist.KeyPathError{Path: path, Err: plist.ErrNotFound}
+		}
+		n = n.items[i].value
+	}
+	if n.kind != Dict && n.kind != Array {
+		return nil, "", &plist.KeyPathError{Path: path, Err: fmt.Errorf("%s is not a dict or array", n.kind)}
+	}
+	return n, comps[len(comps)-1], nil
+}
+
+// Get returns the node at the key path.
+func (d *Document) Get(path string) (*Node, error) {
+	parent, last, err := d.parent(path)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return d.root, nil
+	}
+	i, ok := parent.lookup(last)
+	if !ok {
+		return nil, &plist.KeyPathError{Path: path, Err: plist.ErrNotFound}
+	}
+	return parent.items[i].value, nil
+}
+
+// Set stores v at the key path. An existing value is replaced in
+// place, keeping the comments around it. A missing key is added at
+// the end of its dict, and an index one past the end of an array
+// appends to it. The empty path replaces the root value.
+func (d *Document) Set(path string, v interface{}) error {
+	parent, last, err := d.parent(path)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		n, err := d.render(v, d.root.indent)
+		if err != nil {
+			return err
+		}
+		if n.kind != Dict && n.kind != Array {
+			return errors.New("plist: bad root element: must be dict or array")
+		}
+		d.root = n
+		return nil
+	}
+
+	i, ok := parent.lookup(last)
+	if ok {
+		it := parent.items[i]
+		n, err := d.render(v, it.value.indent)
+		if err != nil {
+			return err
+		}
+		it.value = n
+		return nil
+	}
+	if i != len(parent.items) {
+		return &plist.KeyPathError{Path: path, Err: errors.New("array index out of range")}
+	}
+
+	leading, between := d.newItem(parent)
+	it, err := d.renderItem(parent.kind, last, v, lineIndent(leading, parent.indent+d.unit))
+	if err != nil {
+		return err
+	}
+	it.leading, it.between = leading, between
+	parent.items = append(parent.items, it)
+	return nil
+}
+
+// newItem returns the whitespace to put before and inside a new item
+// so that it fits in after the last item of n, and opens n if it was
+// written as <dict/>.
+func (d *Document) newItem(n *Node) (leading, between string) {
+	if len(n.items) > 0 {
+		last := n.items[len(n.items)-1]
+		leading = whitespaceSuffix(last.leading)
+		between = last.between
+		if strings.TrimSpace(between) != "" {
+			between = whitespaceSuffix(between)
+		}
+		return leading, between
+	}
+
+	leading = "\n" + n.indent + d.unit
+	if n.close == "" {
+		n.open = "<" + n.kind.String() + ">"
+		n.close = "</" + n.kind.String() + ">"
+	}
+	if n.trailing == "" {
+		n.trailing = "\n" + n.indent
+	}
+	return leading, leading
+}
+
+// Delete removes the value at the key path, along with the comments
+// that precede it.
+func (d *Document) Delete(path string) error {
+	parent, last, err := d.parent(path)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return &plist.KeyPathError{Path: path, Err: errors.New("cannot delete the root value")}
+	}
+	i, ok := parent.lookup(last)
+	if !ok {
+		return &plist.KeyPathError{Path: path, Err: plist.ErrNotFound}
+	}
+	parent.items = append(parent.items[:i], parent.items[i+1:]...)
+	return nil
+}
+
+// render returns a node for v, formatted as the XML encoder does and
+// indented from indent.
+func (d *Document) render(v interface{}, indent string) (*Node, error) {
+	it, err := d.renderItem(Array, "", v, indent)
+	if err != nil {
+		return nil, err
+	}
+	return it.value, nil
+}
+
+// renderItem returns an item of a container of the given kind holding
+// key and v. Keys and values are written by the XML encoder, so that
+// they are escaped the same way.
+func (d *Document) renderItem(kind Kind, key string, v interface{}, indent string) (*item, error) {
+	var wrapper interface{} = []interface{}{v}
+	if kind == Dict {
+		wrapper = map[string]interface{}{key: v}
+	}
+	buf, err := xmlplist.Marshal(wrapper)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := Parse(buf)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.root.items) != 1 {
+		return nil, errors.New("plist: cannot render value")
+	}
+	it := doc.root.items[0]
+	d.reindent(it.value, indent)
+	return it, nil
+}
+
+// reindent indents the contents of n, which starts at indent.
+func (d *Document) reindent(n *Node, indent string) {
+	n.indent = indent
+	if n.kind != Dict && n.kind != Array {
+		return
+	}
+	if len(n.items) == 0 {
+		n.open = "<" + n.kind.String() + "/>"
+		n.trailing, n.close = "", ""
+		return
+	}
+	for _, it := range n.items {
+		it.leading = "\n" + indent + d.unit
+		it.between = it.leading
+		d.reindent(it.value, indent+d.unit)
+	}
+	n.trailing = "\n" + indent
+}
+
+// whitespaceSuffix returns the part of s after its last comment or
+// other non-space text.
+func whitespaceSuffix(s string) string {
+	i := strings.LastIndexFunc(s, func(r rune) bool {
+		return !strings.ContainsRune(" \t\r\n", r)
+	})
+	return s[i+1:]
+}
+
+// lineIndent returns the indentation of the last line of s, or def
+// if s has only one line.
+func lineIndent(s, def string) string {
+	i := strings.LastIndexByte(s, '\n')
+	if i < 0 {
+		return def
+	}
+	return s[i+1:]
+}
@@ -0,0 +1,133 @@
+package plistedit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/xmlplist"
+)
+
+func TestRoundTrip(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Info.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	doc, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(doc.Bytes(), buf) {
+		t.Fatalf("round trip changed the document:\n%s", doc.Bytes())
+	}
+
+	v, err := doc.Value()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var expected interface{}
+	err = xmlplist.Unmarshal(buf, &expected)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("got %v, expected %v", v, expected)
+	}
+
+	keys := []string{"CFBundleName", "CFBundleVersion", "CFBundleURLTypes", "LSRequiresIPhoneOS", "UIBackgroundModes", "NSAllowsArbitraryLoads"}
+	if !reflect.DeepEqual(doc.Root().Keys(), keys) {
+		t.Fatalf("unexpected keys %v", doc.Root().Keys())
+	}
+}
+
+func TestEdit(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Info.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	doc, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	edits := []struct {
+		path string
+		v    interface{}
+	}{
+		{"CFBundleVersion", "42"},
+		{"CFBundleURLTypes[0].CFBundleURLSchemes[1]", "hi"},
+		{"UIBackgroundModes.0", "audio"},
+		{"NSAppTransportSecurity", map[string]interface{}{
+			"NSExceptionDomains": map[string]interface{}{
+				"example.com": map[string]interface{}{"NSIncludesSubdomains": true},
+			},
+		}},
+	}
+	for _, e := range edits {
+		err := doc.Set(e.path, e.v)
+		if err != nil {
+			t.Fatalf("set %s: %v", e.path, err)
+		}
+	}
+	err = doc.Delete("NSAllowsArbitraryLoads")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected, err := ioutil.ReadFile("testdata/Info.plist.golden")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(doc.Bytes(), expected) {
+		t.Fatalf("unexpected document:\n%s", doc.Bytes())
+	}
+
+	n, err := doc.Get("CFBundleURLTypes[0].CFBundleURLSchemes")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if n.Kind() != Array || n.Len() != 2 {
+		t.Fatalf("unexpected node %v with %d items", n.Kind(), n.Len())
+	}
+}
+
+func TestEditErrors(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Info.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	doc, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, path := range []string{"Missing.Key", "CFBundleURLTypes[3]", "CFBundleName.Key"} {
+		if doc.Set(path, "x") == nil {
+			t.Errorf("set %s: expected error", path)
+		}
+	}
+	_, err = doc.Get("Missing")
+	if kpe, ok := err.(*plist.KeyPathError); !ok || kpe.Err != plist.ErrNotFound {
+		t.Errorf("unexpected error %v", err)
+	}
+	if !bytes.Equal(doc.Bytes(), buf) {
+		t.Fatalf("failed edits changed the document")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, doc := range []string{
+		`<plist><array><!x></array></plist>`,
+		`<plist><dict><key>a</key><!x></dict></plist>`,
+		`<plist><dict><!x><key>a</key><true/></dict></plist>`,
+		`<plist><array>text</array></plist>`,
+	} {
+		_, err := Parse([]byte(doc))
+		if err == nil {
+			t.Errorf("%s: expected error", doc)
+		}
+	}
+	if Kind(42).String() != "Kind(42)" {
+		t.Fatalf("got %q", Kind(42).String())
+	}
+}
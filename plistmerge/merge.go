@@ -0,0 +1,200 @@
+// Package plistmerge performs three-way merges of plists.
+//
+// Plists are merged at the level of dict keys rather than lines of
+// text, so that concurrent edits to different keys of the same file
+// merge cleanly regardless of formatting. A key path that was changed
+// differently on both sides is a conflict.
+package plistmerge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/plistdiff"
+)
+
+// A Conflict records a key path that was changed in different ways
+// on both sides of a merge. Base, Ours and Theirs are nil where the
+// value does not exist.
+type Conflict struct {
+	Path   string
+	Base   interface{}
+	Ours   interface{}
+	Theirs interface{}
+}
+
+func (c Conflict) String() string {
+	path := c.Path
+	if path == "" {
+		path = "<root>"
+	}
+	switch {
+	case c.Ours == nil:
+		return fmt.Sprintf("%s: removed in ours, changed in theirs", path)
+	case c.Theirs == nil:
+		return fmt.Sprintf("%s: changed in ours, removed in theirs", path)
+	case c.Base == nil:
+		return fmt.Sprintf("%s: added differently in ours and theirs", path)
+	}
+	return fmt.Sprintf("%s: changed differently in ours and theirs", path)
+}
+
+// Merge3 merges the changes made between base and theirs into ours.
+// All three are decoded plist values, as produced by unmarshaling
+// into an interface{}.
+//
+// Dicts are merged key by key. Arrays are merged only when both sides
+// appended to the base array; otherwise an array is treated as a single
+// value. Where a conflict is found, the merged plist holds our value.
+func Merge3(base, ours, theirs interface{}) (interface{}, []Conflict) {
+	m := new(merger)
+	v, _ := m.merge("", base, ours, theirs, true, true, true)
+	return v, m.conflicts
+}
+
+type merger struct {
+	conflicts []Conflict
+}
+
+// equal reports whether two values, which may be absent, are equal.
+func equal(a interface{}, aok bool, b interface{}, bok bool) bool {
+	if !aok || !bok {
+		return aok == bok
+	}
+	return plistdiff.Compare(a, b).Equal()
+}
+
+// merge merges the values found at path. The ok flags report whether
+// each value exists. The merged value is returned along with whether
+// it exists.
+func (m *merger) merge(path string, base, ours, theirs interface{}, bok, ook, tok bool) (interface{}, bool) {
+	switch {
+	case equal(ours, ook, theirs, tok):
+		return ours, ook
+	case equal(base, bok, ours, ook):
+		return theirs, tok
+	case equal(base, bok, theirs, tok):
+		return ours, ook
+	}
+
+	if od, ok := ours.(map[string]interface{}); ok {
+		if td, ok := theirs.(map[string]interface{}); ok {
+			bd, ok := base.(map[string]interface{})
+			if !ok {
+				bd = map[string]interface{}{}
+			}
+			return m.mergeDict(path, bd, od, td), true
+		}
+	}
+	if oa, ok := ours.([]interface{}); ok {
+		if ta, ok := theirs.([]interface{}); ok {
+			if ba, ok := base.([]interface{}); ok {
+				if merged, ok := appendOnly(ba, oa, ta); ok {
+					return merged, true
+				}
+			}
+		}
+	}
+
+	c := Conflict{Path: path}
+	if bok {
+		c.Base = base
+	}
+	if ook {
+		c.Ours = ours
+	}
+	if tok {
+		c.Theirs = theirs
+	}
+	m.conflicts = append(m.conflicts, c)
+	return ours, ook
+}
+
+// mergeDict merges three dicts key by key.
+func (m *merger) mergeDict(path string, base, ours, theirs map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{})
+	keys := make(map[string]bool)
+	for _, d := range []map[string]interface{}{base, ours, theirs} {
+		for k := range d {
+			keys[k] = true
+		}
+	}
+	for k := range keys {
+		bv, bok := base[k]
+		ov, ook := ours[k]
+		tv, tok := theirs[k]
+		v, ok := m.merge(plist.ChildKeyPath(path, k), bv, ov, tv, bok, ook, tok)
+		if ok {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// appendOnly merges two arrays that only appended elements to base.
+// Elements appended on both sides are only kept once.
+func appendOnly(base, ours, theirs []interface{}) ([]interface{}, bool) {
+	if !hasPrefix(ours, base) || !hasPrefix(theirs, base) {
+		return nil, false
+	}
+	merged := append([]interface{}{}, ours...)
+	for _, elem := range theirs[len(base):] {
+		if !contains(ours[len(base):], elem) {
+			merged = append(merged, elem)
+		}
+	}
+	return merged, true
+}
+
+func hasPrefix(a, prefix []interface{}) bool {
+	return len(a) >= len(prefix) && equal(a[:len(prefix)], true, prefix, true)
+}
+
+func contains(a []interface{}, v interface{}) bool {
+	for _, elem := range a {
+		if equal(elem, true, v, true) {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeData decodes three plists of any kind and merges them as
+// Merge3 does. The result is encoded in the kind of ours, with the
+// keys of each dict in the order they appear in ours, followed by
+// keys only found in theirs.
+func MergeData(base, ours, theirs []byte) ([]byte, []Conflict, error) {
+	var bv, ov, tv interface{}
+	for _, d := range []struct {
+		buf []byte
+		v   *interface{}
+	}{{base, &bv}, {ours, &ov}, {theirs, &tv}} {
+		err := plist.Unmarshal(d.buf, d.v)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	merged, conflicts := Merge3(bv, ov, tv)
+	if merged == nil {
+		merged = map[string]interface{}{}
+	}
+
+	order := make(keyOrder)
+	order.scan(ours)
+	order.scan(theirs)
+	kind, _ := plist.DetectKind(bytes.NewReader(ours))
+
+	buf := new(bytes.Buffer)
+	enc := plist.NewSpecificEncoder(buf, kind)
+	if enc == nil {
+		return nil, nil, errors.New("plist: unknown kind")
+	}
+	err := enc.Encode(order.apply("", merged))
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), conflicts, nil
+}
@@ -0,0 +1,174 @@
+package plistmerge
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mkrautz/plist"
+)
+
+func TestMerge3(t *testing.T) {
+	base := map[string]interface{}{
+		"CFBundleVersion": "1",
+		"Removed":         true,
+		"Groups":          []interface{}{"a"},
+		"Nested":          map[string]interface{}{"x": int64(1), "y": int64(1)},
+	}
+	ours := map[string]interface{}{
+		"CFBundleVersion": "2",
+		"Groups":          []interface{}{"a", "b"},
+		"Nested":          map[string]interface{}{"x": int64(2), "y": int64(1)},
+	}
+	theirs := map[string]interface{}{
+		"CFBundleVersion": "1",
+		"Removed":         true,
+		"Added":           "new",
+		"Groups":          []interface{}{"a", "c", "b"},
+		"Nested":          map[string]interface{}{"x": int64(1), "y": int64(2)},
+	}
+
+	merged, conflicts := Merge3(base, ours, theirs)
+	if len(conflicts) != 0 {
+		t.Fatalf("unexpected conflicts %v", conflicts)
+	}
+	expected := map[string]interface{}{
+		"CFBundleVersion": "2",
+		"Added":           "new",
+		"Groups":          []interface{}{"a", "b", "c"},
+		"Nested":          map[string]interface{}{"x": int64(2), "y": int64(2)},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatalf("got %#v, expected %#v", merged, expected)
+	}
+}
+
+func TestMerge3Conflicts(t *testing.T) {
+	base := map[string]interface{}{
+		"a": "base",
+		"b": "base",
+		"c": []interface{}{"x", "y"},
+	}
+	ours := map[string]interface{}{
+		"a": "ours",
+		"c": []interface{}{"x"},
+		"d": int64(1),
+	}
+	theirs := map[string]interface{}{
+		"a": "theirs",
+		"b": "theirs",
+		"c": []interface{}{"y"},
+		"d": int64(2),
+	}
+
+	merged, conflicts := Merge3(base, ours, theirs)
+	paths := make([]string, len(conflicts))
+	for i, c := range conflicts {
+		paths[i] = c.String()
+	}
+	sort.Strings(paths)
+	expected := []string{
+		"a: changed differently in ours and theirs",
+		"b: removed in ours, changed in theirs",
+		"c: changed differently in ours and theirs",
+		"d: added differently in ours and theirs",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("got %q, expected %q", paths, expected)
+	}
+	if !reflect.DeepEqual(merged, ours) {
+		t.Fatalf("conflicts should keep our values, got %#v", merged)
+	}
+}
+
+const header = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+`
+
+func TestMergeDataKeepsKeyOrder(t *testing.T) {
+	base := header + `<dict>
+	<key>Zeta</key>
+	<string>1</string>
+	<key>Alpha</key>
+	<dict>
+		<key>z</key>
+		<true/>
+		<key>a</key>
+		<true/>
+	</dict>
+</dict>
+</plist>
+`
+	ours := strings.Replace(base, "<string>1</string>", "<string>2</string>", 1)
+	theirs := strings.Replace(base, "\t\t<key>a</key>", "\t\t<key>m</key>\n\t\t<false/>\n\t\t<key>a</key>", 1)
+
+	merged, conflicts, err := MergeData([]byte(base), []byte(ours), []byte(theirs))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("unexpected conflicts %v", conflicts)
+	}
+
+	expected := header + `<dict>
+	<key>Zeta</key>
+	<string>2</string>
+	<key>Alpha</key>
+	<dict>
+		<key>z</key>
+		<true/>
+		<key>a</key>
+		<true/>
+		<key>m</key>
+		<false/>
+	</dict>
+</dict>
+</plist>
+`
+	if string(merged) != expected {
+		t.Fatalf("unexpected result:\n%s", merged)
+	}
+}
+
+func TestMergeDataKeepsKeyOrderAllKinds(t *testing.T) {
+	base := plist.OrderedDict{
+		{Key: "Zeta", Value: "1"},
+		{Key: "Alpha", Value: plist.OrderedDict{{Key: "z", Value: "a"}, {Key: "a", Value: "b"}}},
+	}
+	ours := plist.OrderedDict{base[0], base[1], {Key: "New", Value: "c"}}
+	theirs := plist.OrderedDict{base[0], {Key: "Alpha", Value: plist.OrderedDict{{Key: "z", Value: "a"}, {Key: "m", Value: "d"}, {Key: "a", Value: "b"}}}}
+	expected := plist.OrderedDict{
+		{Key: "Zeta", Value: "1"},
+		{Key: "Alpha", Value: plist.OrderedDict{{Key: "z", Value: "a"}, {Key: "a", Value: "b"}, {Key: "m", Value: "d"}}},
+		{Key: "New", Value: "c"},
+	}
+
+	for _, kind := range []plist.Kind{plist.Binary, plist.ASCII, plist.JSON} {
+		var docs [3][]byte
+		for i, v := range []plist.OrderedDict{base, ours, theirs} {
+			buf := new(bytes.Buffer)
+			err := plist.NewSpecificEncoder(buf, kind).Encode(v)
+			if err != nil {
+				t.Fatalf("kind %v: %v", kind, err)
+			}
+			docs[i] = buf.Bytes()
+		}
+		merged, _, err := MergeData(docs[0], docs[1], docs[2])
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		dec := plist.NewSpecificDecoder(bytes.NewReader(merged), kind)
+		dec.UseOrderedDicts()
+		var actual interface{}
+		err = dec.Decode(&actual)
+		if err != nil {
+			t.Fatalf("kind %v: %v", kind, err)
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("kind %v: got %#v, expected %#v", kind, actual, expected)
+		}
+	}
+}
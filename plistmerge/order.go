@@ -0,0 +1,92 @@
+package plistmerge
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/internal/keypath"
+)
+
+// A keyOrder maps the key path of each dict to its keys, in the
+// order they appear in the documents it was built from.
+type keyOrder map[string][]string
+
+// scan records the key order of the dicts in a plist of any kind.
+// Keys already recorded for a dict keep their position; new keys are
+// appended. A plist that cannot be decoded is ignored.
+func (o keyOrder) scan(buf []byte) {
+	dec := plist.NewDecoder(bytes.NewReader(buf))
+	dec.UseOrderedDicts()
+	var v interface{}
+	if dec.Decode(&v) == nil {
+		o.record("", v)
+	}
+}
+
+// record records the key order of the dicts in v, found at path.
+func (o keyOrder) record(path string, v interface{}) {
+	switch val := v.(type) {
+	case []interface{}:
+		for i, elem := range val {
+			o.record(keypath.Index(path, i), elem)
+		}
+	case plist.OrderedDict:
+		for _, e := range val {
+			o.add(path, e.Key)
+			o.record(plist.ChildKeyPath(path, e.Key), e.Value)
+		}
+	}
+}
+
+// add records key as a key of the dict at path.
+func (o keyOrder) add(path, key string) {
+	for _, k := range o[path] {
+		if k == key {
+			return
+		}
+	}
+	o[path] = append(o[path], key)
+}
+
+// apply returns v, found at path, with its dicts arranged in key
+// order as ordered dicts.
+func (o keyOrder) apply(path string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case []interface{}:
+		a := make([]interface{}, len(val))
+		for i, elem := range val {
+			a[i] = o.apply(keypath.Index(path, i), elem)
+		}
+		return a
+	case map[string]interface{}:
+		keys := o.keys(path, val)
+		d := make(plist.OrderedDict, len(keys))
+		for i, k := range keys {
+			d[i] = plist.DictEntry{Key: k, Value: o.apply(plist.ChildKeyPath(path, k), val[k])}
+		}
+		return d
+	}
+	return v
+}
+
+// keys returns the keys of the dict d found at path, in key order.
+// Keys without a recorded position follow in sorted order.
+func (o keyOrder) keys(path string, d map[string]interface{}) []string {
+	keys := make([]string, 0, len(d))
+	seen := make(map[string]bool)
+	for _, k := range o[path] {
+		if _, ok := d[k]; ok {
+			keys = append(keys, k)
+			seen[k] = true
+		}
+	}
+	var rest []string
+	for k := range d {
+		if !seen[k] {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
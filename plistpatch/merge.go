@@ -0,0 +1,61 @@
+package plistpatch
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/mkrautz/plist/jsonplist"
+)
+
+// ApplyMergePatch applies a JSON Merge Patch document to a decoded
+// plist value and returns the patched value. doc is not modified.
+//
+// An object in the patch is merged into the dict at the same place in
+// doc, where null removes a key. Any other value, including a typed
+// value such as {"$date": "2012-01-29T13:07:25Z"}, replaces the value
+// in doc.
+func ApplyMergePatch(doc interface{}, patch []byte) (interface{}, error) {
+	return mergePatch(copyValue(doc), patch)
+}
+
+func mergePatch(target interface{}, patch []byte) (interface{}, error) {
+	var obj map[string]json.RawMessage
+	if !isObject(patch) || json.Unmarshal(patch, &obj) != nil || isTypedValue(obj) {
+		return decodeValue(patch)
+	}
+
+	dict, ok := target.(map[string]interface{})
+	if !ok {
+		dict = make(map[string]interface{})
+	}
+	for k, raw := range obj {
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			delete(dict, k)
+			continue
+		}
+		v, err := mergePatch(dict[k], raw)
+		if err != nil {
+			return nil, err
+		}
+		dict[k] = v
+	}
+	return dict, nil
+}
+
+// isObject reports whether the JSON value in buf is an object.
+func isObject(buf []byte) bool {
+	buf = bytes.TrimSpace(buf)
+	return len(buf) > 0 && buf[0] == '{'
+}
+
+// isTypedValue reports whether obj is one of the single-key objects
+// typed JSON uses for plist types JSON lacks.
+func isTypedValue(obj map[string]json.RawMessage) bool {
+	if len(obj) != 1 {
+		return false
+	}
+	for k := range obj {
+		return jsonplist.IsEnvelopeKey(k)
+	}
+	return false
+}
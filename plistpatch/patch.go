@@ -0,0 +1,325 @@
+// Package plistpatch applies JSON Patch (RFC 6902) and JSON Merge
+// Patch (RFC 7386) documents to plists.
+//
+// Patches are JSON documents, and their values are read as typed JSON
+// (see plist.TypedJSON), so that plist types JSON lacks can be written
+// as {"$date": "2012-01-29T13:07:25Z"} or {"$data": "////"}. A number
+// with a fraction or exponent is a real; any other number is an integer.
+//
+// The paths of JSON Patch operations are JSON Pointers such as
+// "/CFBundleURLTypes/0/CFBundleURLSchemes". A path that does not begin
+// with a slash is read as a plist key path instead, such as
+// "CFBundleURLTypes[0].CFBundleURLSchemes".
+package plistpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/plistdiff"
+)
+
+// An Operation is a single JSON Patch operation.
+type Operation struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+// A Patch is a JSON Patch document: a list of operations
+// applied in order.
+type Patch []Operation
+
+// An Error records a failed patch operation.
+type Error struct {
+	Index int    // the index of the operation in the patch
+	Op    string // the operation
+	Path  string // the path the operation applies to
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("plist: patch operation %d (%s %s): %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// DecodePatch parses a JSON Patch document.
+func DecodePatch(buf []byte) (Patch, error) {
+	var raw []struct {
+		Op    string          `json:"op"`
+		Path  *string         `json:"path"`
+		From  *string         `json:"from"`
+		Value json.RawMessage `json:"value"`
+	}
+	err := json.Unmarshal(buf, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	p := make(Patch, len(raw))
+	for i, r := range raw {
+		op := Operation{Op: r.Op}
+		fail := func(err error) error {
+			return &Error{i, r.Op, op.Path, err}
+		}
+		if r.Path == nil {
+			return nil, fail(errors.New("missing path"))
+		}
+		op.Path = *r.Path
+		switch r.Op {
+		case "add", "replace", "test":
+			if r.Value == nil {
+				return nil, fail(errors.New("missing value"))
+			}
+			op.Value, err = decodeValue(r.Value)
+			if err != nil {
+				return nil, fail(err)
+			}
+		case "move", "copy":
+			if r.From == nil {
+				return nil, fail(errors.New("missing from"))
+			}
+			op.From = *r.From
+		case "remove":
+		default:
+			return nil, fail(errors.New("unknown operation"))
+		}
+		p[i] = op
+	}
+	return p, nil
+}
+
+// decodeValue decodes a JSON value as typed JSON.
+func decodeValue(buf []byte) (interface{}, error) {
+	var v interface{}
+	err := plist.FromJSON(buf, &v, plist.TypedJSON)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Apply applies the patch to a decoded plist value, as produced by
+// unmarshaling into an interface{}, and returns the patched value.
+// The patch is applied atomically: doc is never modified, and no
+// result is returned if any operation fails.
+func (p Patch) Apply(doc interface{}) (interface{}, error) {
+	doc = copyValue(doc)
+	for i, op := range p {
+		var err error
+		doc, err = op.apply(doc)
+		if err != nil {
+			return nil, &Error{i, op.Op, op.Path, err}
+		}
+	}
+	return doc, nil
+}
+
+// apply applies a single operation to doc.
+func (op Operation) apply(doc interface{}) (interface{}, error) {
+	path, err := splitPath(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return add(doc, path, copyValue(op.Value))
+	case "remove":
+		return remove(doc, path)
+	case "replace":
+		if _, err := get(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return copyValue(op.Value), nil
+		}
+		return doc, plist.Set(&doc, plist.JoinKeyPath(path...), copyValue(op.Value))
+	case "test":
+		v, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !plistdiff.Compare(v, op.Value).Equal() {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	case "move", "copy":
+		from, err := splitPath(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return add(doc, path, copyValue(v))
+		}
+		if len(from) < len(path) && plist.JoinKeyPath(path[:len(from)]...) == plist.JoinKeyPath(from...) {
+			return nil, errors.New("cannot move a value into itself")
+		}
+		doc, err = remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, v)
+	}
+	return nil, errors.New("unknown operation")
+}
+
+// splitPath splits a JSON Pointer or a plist key path into its
+// components.
+func splitPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if path[0] != '/' {
+		return plist.SplitKeyPath(path)
+	}
+	comps := strings.Split(path[1:], "/")
+	for i, c := range comps {
+		comps[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(c)
+	}
+	return comps, nil
+}
+
+// get returns the value found at path in doc.
+func get(doc interface{}, path []string) (interface{}, error) {
+	return plist.Get(doc, plist.JoinKeyPath(path...))
+}
+
+// add adds val at path in doc. Unlike plist.Set, adding to an array
+// inserts the value before the given index; the index "-" appends.
+func add(doc interface{}, path []string, val interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return val, nil
+	}
+	parentPath := plist.JoinKeyPath(path[:len(path)-1]...)
+	last := path[len(path)-1]
+
+	parent, err := plist.Get(doc, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = val
+		return doc, nil
+	case []interface{}:
+		i := len(p)
+		if last != "-" {
+			i, err = arrayIndex(last)
+			if err != nil {
+				return nil, err
+			}
+			if i > len(p) {
+				return nil, plist.ErrNotFound
+			}
+		}
+		a := make([]interface{}, 0, len(p)+1)
+		a = append(a, p[:i]...)
+		a = append(a, val)
+		a = append(a, p[i:]...)
+		if len(path) == 1 {
+			return a, nil
+		}
+		return doc, plist.Set(&doc, parentPath, a)
+	}
+	return nil, fmt.Errorf("cannot add to a value of type %T", parent)
+}
+
+// remove removes the value at path from doc.
+func remove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the root")
+	}
+	if _, err := get(doc, path); err != nil {
+		return nil, err
+	}
+	return doc, plist.Delete(&doc, plist.JoinKeyPath(path...))
+}
+
+// arrayIndex parses an array index, which must not have
+// leading zeros.
+func arrayIndex(s string) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 || (len(s) > 1 && s[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", s)
+	}
+	return i, nil
+}
+
+// copyValue returns a deep copy of a decoded plist value.
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, elem := range val {
+			m[k] = copyValue(elem)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(val))
+		for i, elem := range val {
+			a[i] = copyValue(elem)
+		}
+		return a
+	case []byte:
+		return append([]byte{}, val...)
+	}
+	return v
+}
+
+// PatchData applies a JSON Patch document to a plist of any kind,
+// and returns the patched plist encoded in the same kind.
+func PatchData(data, patch []byte) ([]byte, error) {
+	p, err := DecodePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	return modifyData(data, p.Apply)
+}
+
+// MergePatchData applies a JSON Merge Patch document to a plist of
+// any kind, and returns the patched plist encoded in the same kind.
+func MergePatchData(data, patch []byte) ([]byte, error) {
+	return modifyData(data, func(doc interface{}) (interface{}, error) {
+		return ApplyMergePatch(doc, patch)
+	})
+}
+
+// modifyData decodes the plist in data, modifies it with fn and
+// encodes the result in the original kind.
+func modifyData(data []byte, fn func(interface{}) (interface{}, error)) ([]byte, error) {
+	var doc interface{}
+	kind, err := plist.UnmarshalWithKind(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	doc, err = fn(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	enc := plist.NewSpecificEncoder(buf, kind)
+	if enc == nil {
+		return nil, errors.New("plist: unknown kind")
+	}
+	err = enc.Encode(doc)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
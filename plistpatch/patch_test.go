@@ -0,0 +1,132 @@
+package plistpatch
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mkrautz/plist"
+)
+
+func doc() map[string]interface{} {
+	return map[string]interface{}{
+		"CFBundleVersion": "1",
+		"CFBundleURLTypes": []interface{}{
+			map[string]interface{}{
+				"CFBundleURLSchemes": []interface{}{"a", "c"},
+			},
+		},
+		"com.example.key": int64(1),
+	}
+}
+
+func TestPatch(t *testing.T) {
+	p, err := DecodePatch([]byte(`[
+		{"op": "test", "path": "/CFBundleVersion", "value": "1"},
+		{"op": "replace", "path": "/CFBundleVersion", "value": "2"},
+		{"op": "add", "path": "/CFBundleURLTypes/0/CFBundleURLSchemes/1", "value": "b"},
+		{"op": "add", "path": "CFBundleURLTypes[0].CFBundleURLSchemes.-", "value": "d"},
+		{"op": "add", "path": "/CFBundleURLTypes/0/CFBundleURLSchemes/-", "value": "e"},
+		{"op": "remove", "path": "/CFBundleURLTypes/0/CFBundleURLSchemes/4"},
+		{"op": "add", "path": "/Expires", "value": {"$date": "2012-01-29T13:07:25Z"}},
+		{"op": "add", "path": "/Ratio", "value": 1.0},
+		{"op": "copy", "from": "/com.example.key", "path": "/a~1b"},
+		{"op": "move", "from": "/com.example.key", "path": "/Moved"}
+	]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	orig := doc()
+	actual, err := p.Apply(orig)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := map[string]interface{}{
+		"CFBundleVersion": "2",
+		"CFBundleURLTypes": []interface{}{
+			map[string]interface{}{
+				"CFBundleURLSchemes": []interface{}{"a", "b", "c", "d"},
+			},
+		},
+		"Expires": time.Date(2012, 1, 29, 13, 7, 25, 0, time.UTC),
+		"Ratio":   float64(1),
+		"a/b":     int64(1),
+		"Moved":   int64(1),
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("got %#v, expected %#v", actual, expected)
+	}
+	if !reflect.DeepEqual(orig, doc()) {
+		t.Fatalf("Apply modified its input")
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+	errTests := []struct {
+		Patch string
+		Error string
+	}{
+		{`[{"op": "test", "path": "/CFBundleVersion", "value": 1}]`, "plist: patch operation 0 (test /CFBundleVersion): test failed"},
+		{`[{"op": "remove", "path": "/Missing"}]`, `plist: patch operation 0 (remove /Missing): plist: key path "Missing": no value at key path`},
+		{`[{"op": "add", "path": "/CFBundleURLTypes/5", "value": 1}]`, `plist: patch operation 0 (add /CFBundleURLTypes/5): no value at key path`},
+		{`[{"op": "move", "from": "/CFBundleURLTypes", "path": "/CFBundleURLTypes/0"}]`, "plist: patch operation 0 (move /CFBundleURLTypes/0): cannot move a value into itself"},
+		{`[{"op": "add", "path": "/a", "value": null}]`, "plist: patch operation 0 (add /a): plist: null cannot be represented in a plist"},
+		{`[{"op": "frobnicate", "path": "/a"}]`, "plist: patch operation 0 (frobnicate /a): unknown operation"},
+	}
+	for _, et := range errTests {
+		p, err := DecodePatch([]byte(et.Patch))
+		if err == nil {
+			_, err = p.Apply(doc())
+		}
+		if err == nil || err.Error() != et.Error {
+			t.Errorf("%v: expected error %q, got %v", et.Patch, et.Error, err)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	actual, err := ApplyMergePatch(doc(), []byte(`{
+		"CFBundleVersion": null,
+		"CFBundleURLTypes": ["x"],
+		"Nested": {"a": {"$data": "AQI="}, "b": null},
+		"Icon": {"$dict": {"$data": "not data"}}
+	}`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := map[string]interface{}{
+		"CFBundleURLTypes": []interface{}{"x"},
+		"Nested":           map[string]interface{}{"a": []byte{1, 2}},
+		"Icon":             map[string]interface{}{"$data": "not data"},
+		"com.example.key":  int64(1),
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("got %#v, expected %#v", actual, expected)
+	}
+}
+
+func TestPatchDataKeepsKind(t *testing.T) {
+	buf, err := ioutil.ReadFile("../asciiplist/testdata/Dict.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	patched, err := MergePatchData(buf, []byte(`{"added": 5}`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if patched[0] != '{' {
+		t.Fatalf("patched plist is not an ASCII plist:\n%s", patched)
+	}
+
+	var v map[string]interface{}
+	err = plist.Unmarshal(patched, &v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	// ASCII plists store numbers as strings.
+	if v["added"] != "5" {
+		t.Fatalf("merge patch not applied: %#v", v)
+	}
+}
@@ -0,0 +1,117 @@
+package provisioning
+
+import (
+	"errors"
+)
+
+// toDER re-encodes the BER element at the start of data with definite
+// lengths, as encoding/asn1 requires, and returns it with the data
+// that follows it. Signed profiles often use indefinite lengths for
+// their constructed elements. Other BER forms are left alone.
+func toDER(data []byte) (der, rest []byte, err error) {
+	tagLen, err := tagLength(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) <= tagLen {
+		return nil, nil, errors.New("plist: truncated BER element")
+	}
+	tag := data[:tagLen]
+	constructed := data[0]&0x20 != 0
+	data = data[tagLen:]
+
+	if data[0] == 0x80 {
+		// An indefinite length: elements up to an end-of-contents.
+		if !constructed {
+			return nil, nil, errors.New("plist: indefinite length of primitive BER element")
+		}
+		data = data[1:]
+		var content []byte
+		for {
+			if len(data) >= 2 && data[0] == 0 && data[1] == 0 {
+				return append(appendLength(append([]byte(nil), tag...), len(content)), content...), data[2:], nil
+			}
+			if len(data) == 0 {
+				return nil, nil, errors.New("plist: missing BER end-of-contents")
+			}
+			var elem []byte
+			elem, data, err = toDER(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			content = append(content, elem...)
+		}
+	}
+
+	n, lenLen, err := readLength(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	data = data[lenLen:]
+	if n > len(data) {
+		return nil, nil, errors.New("plist: truncated BER element")
+	}
+	body, rest := data[:n], data[n:]
+	if constructed {
+		var content []byte
+		for len(body) > 0 {
+			var elem []byte
+			elem, body, err = toDER(body)
+			if err != nil {
+				return nil, nil, err
+			}
+			content = append(content, elem...)
+		}
+		body = content
+	}
+	return append(appendLength(append([]byte(nil), tag...), len(body)), body...), rest, nil
+}
+
+// tagLength returns the number of bytes of the identifier at the
+// start of data.
+func tagLength(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, errors.New("plist: truncated BER element")
+	}
+	if data[0]&0x1f != 0x1f {
+		return 1, nil
+	}
+	for i := 1; i < len(data); i++ {
+		if data[i]&0x80 == 0 {
+			return i + 1, nil
+		}
+	}
+	return 0, errors.New("plist: truncated BER tag")
+}
+
+// readLength reads the definite length at the start of data, and
+// returns it with the number of bytes it takes.
+func readLength(data []byte) (int, int, error) {
+	if data[0]&0x80 == 0 {
+		return int(data[0]), 1, nil
+	}
+	size := int(data[0] & 0x7f)
+	if size > 4 || size >= len(data) {
+		return 0, 0, errors.New("plist: bad BER length")
+	}
+	n := 0
+	for _, c := range data[1 : 1+size] {
+		n = n<<8 | int(c)
+	}
+	if n < 0 {
+		return 0, 0, errors.New("plist: bad BER length")
+	}
+	return n, 1 + size, nil
+}
+
+// appendLength appends the DER encoding of the length n to b.
+func appendLength(b []byte, n int) []byte {
+	if n < 0x80 {
+		return append(b, byte(n))
+	}
+	var buf []byte
+	for ; n > 0; n >>= 8 {
+		buf = append([]byte{byte(n)}, buf...)
+	}
+	return append(append(b, 0x80|byte(len(buf))), buf...)
+}
@@ -0,0 +1,88 @@
+package provisioning
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+var (
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+)
+
+// contentInfo is a CMS ContentInfo (RFC 5652, section 3). The
+// explicitly tagged fields of these structs hold the [0] element
+// itself; its Bytes are the encoding of the tagged value.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0"`
+}
+
+// signedData is a CMS SignedData (RFC 5652, section 5.1).
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// Extract returns the plist embedded in the BER- or DER-encoded CMS
+// signed data of a provisioning profile. The signature is not verified.
+func Extract(data []byte) ([]byte, error) {
+	der, rest, err := toDER(data)
+	if err != nil {
+		return nil, fmt.Errorf("plist: provisioning profile is not CMS signed data: %v", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("plist: trailing data after provisioning profile")
+	}
+	var ci contentInfo
+	_, err = asn1.Unmarshal(der, &ci)
+	if err != nil {
+		return nil, fmt.Errorf("plist: provisioning profile is not CMS signed data: %v", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("plist: provisioning profile has content type %v, expected signed data", ci.ContentType)
+	}
+
+	var sd signedData
+	_, err = asn1.Unmarshal(ci.Content.Bytes, &sd)
+	if err != nil {
+		return nil, fmt.Errorf("plist: bad provisioning profile signed data: %v", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidData) {
+		return nil, fmt.Errorf("plist: provisioning profile encapsulates %v, expected data", sd.EncapContentInfo.EContentType)
+	}
+	if len(sd.EncapContentInfo.EContent.Bytes) == 0 {
+		return nil, errors.New("plist: provisioning profile has no embedded content")
+	}
+	var econtent asn1.RawValue
+	_, err = asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &econtent)
+	if err != nil || econtent.Class != asn1.ClassUniversal || econtent.Tag != asn1.TagOctetString {
+		return nil, errors.New("plist: provisioning profile content is not an octet string")
+	}
+	if !econtent.IsCompound {
+		return econtent.Bytes, nil
+	}
+
+	// A constructed OCTET STRING holds its content in chunks.
+	var content []byte
+	chunks := econtent.Bytes
+	for len(chunks) > 0 {
+		var chunk []byte
+		chunks, err = asn1.Unmarshal(chunks, &chunk)
+		if err != nil {
+			return nil, fmt.Errorf("plist: bad provisioning profile content: %v", err)
+		}
+		content = append(content, chunk...)
+	}
+	return content, nil
+}
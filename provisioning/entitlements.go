@@ -0,0 +1,134 @@
+package provisioning
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// An EntitlementError describes an entitlement of an app that its
+// profile does not allow.
+type EntitlementError struct {
+	Key string
+	Msg string
+}
+
+func (e EntitlementError) Error() string {
+	return fmt.Sprintf("plist: entitlement %s: %s", e.Key, e.Msg)
+}
+
+// CheckEntitlements checks the entitlements of an app, as found in
+// its .entitlements file, against those the profile allows. The
+// $(AppIdentifierPrefix) and $(TeamIdentifierPrefix) variables Xcode
+// expands when signing are expanded using the profile. The problems
+// found are returned in order of key.
+func (p *Profile) CheckEntitlements(app map[string]interface{}) []EntitlementError {
+	var prefix, team string
+	if len(p.ApplicationIdentifierPrefix) > 0 {
+		prefix = p.ApplicationIdentifierPrefix[0] + "."
+	}
+	if t := p.Team(); t != "" {
+		team = t + "."
+	}
+	r := strings.NewReplacer("$(AppIdentifierPrefix)", prefix, "$(TeamIdentifierPrefix)", team)
+	expanded := make(map[string]interface{}, len(app))
+	for k, v := range app {
+		expanded[k] = expand(v, r)
+	}
+	return CompareEntitlements(p.Entitlements, expanded)
+}
+
+// expand expands variables in the strings of v.
+func expand(v interface{}, r *strings.Replacer) interface{} {
+	switch v := v.(type) {
+	case string:
+		return r.Replace(v)
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, elem := range v {
+			array[i] = expand(elem, r)
+		}
+		return array
+	}
+	return v
+}
+
+// CompareEntitlements checks the entitlements of an app against the
+// allowed entitlements of a profile. Allowed strings ending in * match
+// any string with the same prefix, allowed arrays match any subset of
+// their elements, and a true boolean also allows false.
+func CompareEntitlements(allowed, app map[string]interface{}) []EntitlementError {
+	keys := make([]string, 0, len(app))
+	for k := range app {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []EntitlementError
+	for _, key := range keys {
+		a, ok := allowed[key]
+		if !ok {
+			errs = append(errs, EntitlementError{key, "not allowed by the profile"})
+			continue
+		}
+		if !allows(a, app[key]) {
+			msg := fmt.Sprintf("value %v not allowed by the profile, which allows %v", app[key], a)
+			errs = append(errs, EntitlementError{key, msg})
+		}
+	}
+	return errs
+}
+
+// allows reports whether the allowed value a permits v.
+func allows(a, v interface{}) bool {
+	switch a := a.(type) {
+	case string:
+		if a == "*" {
+			return true
+		}
+		switch v := v.(type) {
+		case string:
+			return matches(a, v)
+		case []interface{}:
+			return allows([]interface{}{a}, v)
+		}
+		return false
+	case []interface{}:
+		var vals []interface{}
+		switch v := v.(type) {
+		case string:
+			vals = []interface{}{v}
+		case []interface{}:
+			vals = v
+		default:
+			return false
+		}
+	nextValue:
+		for _, val := range vals {
+			s, ok := val.(string)
+			if !ok {
+				return false
+			}
+			for _, elem := range a {
+				if pattern, ok := elem.(string); ok && (pattern == "*" || matches(pattern, s)) {
+					continue nextValue
+				}
+			}
+			return false
+		}
+		return true
+	case bool:
+		b, ok := v.(bool)
+		return ok && (b == a || !b)
+	}
+	return reflect.DeepEqual(a, v)
+}
+
+// matches reports whether s matches pattern, which may end in *.
+func matches(pattern, s string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(s, pattern[:len(pattern)-1])
+	}
+	return pattern == s
+}
@@ -0,0 +1,199 @@
+// Package provisioning decodes provisioning profiles
+// (.mobileprovision and .provisionprofile files) and checks the
+// entitlements of apps against them.
+//
+// A profile is an XML plist wrapped in CMS signed data. The package
+// extracts the plist without verifying the signature, so it must not
+// be used to decide whether a profile is trustworthy.
+package provisioning
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/mkrautz/plist"
+)
+
+// A Profile is the contents of a provisioning profile.
+type Profile struct {
+	Name                        string
+	UUID                        string
+	AppIDName                   string
+	ApplicationIdentifierPrefix []string
+	TeamIdentifier              []string
+	TeamName                    string
+	Platform                    []string
+	CreationDate                time.Time
+	ExpirationDate              time.Time
+	TimeToLive                  int64
+	Version                     int64
+	IsXcodeManaged              bool
+
+	// The UDIDs of the devices the profile may be installed on, unless
+	// it provisions all devices, as enterprise profiles do.
+	ProvisionedDevices   []string
+	ProvisionsAllDevices bool
+
+	// The certificates apps signed with the profile may be signed
+	// with.
+	DeveloperCertificates []*x509.Certificate
+
+	// The entitlements apps signed with the profile may have.
+	Entitlements map[string]interface{}
+
+	// All keys of the profile.
+	Raw map[string]interface{}
+}
+
+// Parse decodes a provisioning profile.
+func Parse(data []byte) (*Profile, error) {
+	buf, err := Extract(data)
+	if err != nil {
+		return nil, err
+	}
+	var dict map[string]interface{}
+	err = plist.Unmarshal(buf, &dict)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Profile{Raw: dict}
+	d := fields{dict, nil}
+	p.Name = d.str("Name")
+	p.UUID = d.str("UUID")
+	p.AppIDName = d.str("AppIDName")
+	p.ApplicationIdentifierPrefix = d.strs("ApplicationIdentifierPrefix")
+	p.TeamIdentifier = d.strs("TeamIdentifier")
+	p.TeamName = d.str("TeamName")
+	p.Platform = d.strs("Platform")
+	p.CreationDate = d.date("CreationDate")
+	p.ExpirationDate = d.date("ExpirationDate")
+	p.TimeToLive = d.integer("TimeToLive")
+	p.Version = d.integer("Version")
+	p.IsXcodeManaged = d.boolean("IsXcodeManaged")
+	p.ProvisionedDevices = d.strs("ProvisionedDevices")
+	p.ProvisionsAllDevices = d.boolean("ProvisionsAllDevices")
+	if ents, ok := dict["Entitlements"]; ok {
+		p.Entitlements, ok = ents.(map[string]interface{})
+		if !ok {
+			d.fail("Entitlements", "dict", ents)
+		}
+	}
+	if certs, ok := dict["DeveloperCertificates"].([]interface{}); ok {
+		for i, v := range certs {
+			der, ok := v.([]byte)
+			if !ok {
+				d.fail(fmt.Sprintf("DeveloperCertificates[%d]", i), "data", v)
+				continue
+			}
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, fmt.Errorf("plist: provisioning profile certificate %d: %v", i, err)
+			}
+			p.DeveloperCertificates = append(p.DeveloperCertificates, cert)
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return p, nil
+}
+
+// fields reads typed values from a dict, remembering the first value
+// of the wrong type. Missing keys yield zero values.
+type fields struct {
+	dict map[string]interface{}
+	err  error
+}
+
+func (d *fields) fail(key, expected string, v interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("plist: provisioning profile key %s: expected %s, got %T", key, expected, v)
+	}
+}
+
+func (d *fields) str(key string) string {
+	v, ok := d.dict[key]
+	s, isStr := v.(string)
+	if ok && !isStr {
+		d.fail(key, "string", v)
+	}
+	return s
+}
+
+func (d *fields) strs(key string) []string {
+	v, ok := d.dict[key]
+	if !ok {
+		return nil
+	}
+	array, ok := v.([]interface{})
+	if !ok {
+		d.fail(key, "array", v)
+		return nil
+	}
+	strs := make([]string, 0, len(array))
+	for i, elem := range array {
+		s, ok := elem.(string)
+		if !ok {
+			d.fail(fmt.Sprintf("%s[%d]", key, i), "string", elem)
+			continue
+		}
+		strs = append(strs, s)
+	}
+	return strs
+}
+
+func (d *fields) date(key string) time.Time {
+	v, ok := d.dict[key]
+	t, isDate := v.(time.Time)
+	if ok && !isDate {
+		d.fail(key, "date", v)
+	}
+	return t
+}
+
+func (d *fields) integer(key string) int64 {
+	v, ok := d.dict[key]
+	n, isInt := v.(int64)
+	if ok && !isInt {
+		d.fail(key, "integer", v)
+	}
+	return n
+}
+
+func (d *fields) boolean(key string) bool {
+	v, ok := d.dict[key]
+	b, isBool := v.(bool)
+	if ok && !isBool {
+		d.fail(key, "boolean", v)
+	}
+	return b
+}
+
+// Expired reports whether the profile has expired at time t.
+func (p *Profile) Expired(t time.Time) bool {
+	return !t.Before(p.ExpirationDate)
+}
+
+// Team returns the identifier of the team that owns the profile.
+func (p *Profile) Team() string {
+	if len(p.TeamIdentifier) > 0 {
+		return p.TeamIdentifier[0]
+	}
+	return ""
+}
+
+// ProvisionsDevice reports whether the profile may be installed on
+// the device with the given UDID.
+func (p *Profile) ProvisionsDevice(udid string) bool {
+	if p.ProvisionsAllDevices {
+		return true
+	}
+	for _, d := range p.ProvisionedDevices {
+		if d == udid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package provisioning
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mkrautz/plist"
+)
+
+func TestParse(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Example.mobileprovision")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	p, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if p.Name != "Hello Development" || p.Team() != "ABCDE12345" || p.TeamName != "Example Inc." {
+		t.Fatalf("got %#v", p)
+	}
+	if !p.ExpirationDate.Equal(time.Date(2025, 1, 14, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("got expiration date %v", p.ExpirationDate)
+	}
+	if !p.Expired(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) || p.Expired(p.CreationDate) {
+		t.Fatalf("wrong expiry")
+	}
+	if !p.ProvisionsDevice("00008101-000A1B2C3D4E5F6A") || p.ProvisionsDevice("unknown") {
+		t.Fatalf("wrong devices: %q", p.ProvisionedDevices)
+	}
+	if len(p.DeveloperCertificates) != 1 {
+		t.Fatalf("got %d certificates", len(p.DeveloperCertificates))
+	}
+	if cn := p.DeveloperCertificates[0].Subject.CommonName; cn != "Apple Development: Jane Doe (ABCDE12345)" {
+		t.Fatalf("got certificate %q", cn)
+	}
+	if p.Entitlements["application-identifier"] != "ABCDE12345.com.example.Hello" {
+		t.Fatalf("got entitlements %#v", p.Entitlements)
+	}
+}
+
+func TestParseIndefiniteLengths(t *testing.T) {
+	// The same profile, with the indefinite lengths and chunked
+	// content that Apple's signing tools write.
+	buf, err := ioutil.ReadFile("testdata/Indefinite.mobileprovision")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if buf[0] != 0x30 || buf[1] != 0x80 {
+		t.Fatalf("fixture does not use indefinite lengths")
+	}
+	p, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	data, err := ioutil.ReadFile("testdata/Example.mobileprovision")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected, err := Parse(data)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Fatalf("got %#v, expected %#v", p, expected)
+	}
+	_, err = Extract(buf[:len(buf)-2])
+	if err == nil {
+		t.Fatalf("expected error for missing end-of-contents")
+	}
+}
+
+func TestExtractGarbage(t *testing.T) {
+	_, err := Extract([]byte("<?xml version=\"1.0\"?><plist/>"))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	buf, err := ioutil.ReadFile("testdata/Example.mobileprovision")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	content, err := Extract(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.HasPrefix(content, []byte("<?xml")) {
+		t.Fatalf("got %q", content[:16])
+	}
+}
+
+func TestCheckEntitlements(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Example.mobileprovision")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	p, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	buf, err = ioutil.ReadFile("testdata/Hello.entitlements")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var app map[string]interface{}
+	err = plist.Unmarshal(buf, &app)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	var got []string
+	for _, err := range p.CheckEntitlements(app) {
+		got = append(got, err.Error())
+	}
+	expected := []string{
+		"plist: entitlement aps-environment: value production not allowed by the profile, which allows development",
+		"plist: entitlement com.apple.developer.icloud-services: not allowed by the profile",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+
+	app["get-task-allow"] = true
+	app["keychain-access-groups"] = []interface{}{"OTHERTEAM.com.example.shared"}
+	delete(app, "aps-environment")
+	delete(app, "com.apple.developer.icloud-services")
+	errs := p.CheckEntitlements(app)
+	if len(errs) != 1 || errs[0].Key != "keychain-access-groups" {
+		t.Fatalf("got %v", errs)
+	}
+}
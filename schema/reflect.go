@@ -0,0 +1,211 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkrautz/plist"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uidType  = reflect.TypeOf(plist.UID(0))
+)
+
+// For derives a schema from the type of v, which is usually a pointer
+// to a tagged struct. Struct fields are keyed by their plist tag, or
+// their name when untagged; fields tagged "-" and unexported fields
+// are left out. A field is required when its plist tag has the
+// "required" option, as in `plist:"PayloadType,required"`.
+//
+// Further constraints are given in a schema tag, as keyword=value
+// pairs separated by semicolons:
+//
+//	Port int `plist:"Port" schema:"minimum=1;maximum=65535"`
+//	Mode string `plist:"Mode" schema:"enum=auto|manual;description=How to connect"`
+//
+// The keywords are description, enum (values separated by |), pattern,
+// minLength, maxLength, minimum, maximum, minItems and maxItems. Extra
+// keys are allowed in dicts derived from structs, since the decoders
+// ignore them.
+func For(v interface{}) (*Schema, error) {
+	return forType(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+func forType(t reflect.Type, seen map[reflect.Type]bool) (*Schema, error) {
+	if t == nil {
+		return new(Schema), nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: Date}, nil
+	case uidType:
+		return &Schema{Type: UID}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return new(Schema), nil
+	case reflect.String:
+		return &Schema{Type: String}, nil
+	case reflect.Bool:
+		return &Schema{Type: Boolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Integer}, nil
+	case reflect.Float32, reflect.Float64:
+		// The decoders read integers into reals.
+		return &Schema{Type: Number}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: Data}, nil
+		}
+		items, err := forType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: Array, Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("plist: schema: map key type %v is not a string", t.Key())
+		}
+		elem, err := forType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		if elem.Type == "" && len(elem.AnyOf) == 0 {
+			elem = nil
+		}
+		return &Schema{Type: Dict, AdditionalProperties: elem}, nil
+	case reflect.Struct:
+		if seen[t] {
+			// A recursive type. Its fields are checked one level up.
+			return &Schema{Type: Dict}, nil
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		return forStruct(t, seen)
+	}
+	return nil, fmt.Errorf("plist: schema: unsupported type %v", t)
+}
+
+func forStruct(t reflect.Type, seen map[reflect.Type]bool) (*Schema, error) {
+	s := &Schema{Type: Dict, Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("plist")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		key := parts[0]
+		if key == "" {
+			key = f.Name
+		}
+
+		prop, err := forType(f.Type, seen)
+		if err != nil {
+			return nil, err
+		}
+		err = applyTag(prop, f)
+		if err != nil {
+			return nil, err
+		}
+		s.Properties[key] = prop
+		for _, opt := range parts[1:] {
+			if opt == "required" {
+				s.Required = append(s.Required, key)
+			}
+		}
+	}
+	return s, nil
+}
+
+// applyTag applies the constraints of the schema tag of f to s.
+func applyTag(s *Schema, f reflect.StructField) error {
+	tag := f.Tag.Get("schema")
+	if tag == "" {
+		return nil
+	}
+	for _, kv := range strings.Split(tag, ";") {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			return fmt.Errorf("plist: schema tag of field %s: expected keyword=value, got %q", f.Name, kv)
+		}
+		keyword, val := strings.TrimSpace(kv[:i]), kv[i+1:]
+		var err error
+		switch keyword {
+		case "description":
+			s.Description = val
+		case "pattern":
+			s.Pattern = val
+			s.pattern, err = regexp.Compile(val)
+		case "enum":
+			for _, e := range strings.Split(val, "|") {
+				var ev interface{}
+				ev, err = parseScalar(s.Type, e)
+				if err != nil {
+					break
+				}
+				s.Enum = append(s.Enum, ev)
+			}
+		case "minLength":
+			s.MinLength, err = parseCount(val)
+		case "maxLength":
+			s.MaxLength, err = parseCount(val)
+		case "minItems":
+			s.MinItems, err = parseCount(val)
+		case "maxItems":
+			s.MaxItems, err = parseCount(val)
+		case "minimum":
+			s.Minimum, err = parseFloat(val)
+		case "maximum":
+			s.Maximum, err = parseFloat(val)
+		default:
+			err = fmt.Errorf("unknown keyword %q", keyword)
+		}
+		if err != nil {
+			return fmt.Errorf("plist: schema tag of field %s: %v", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// parseScalar parses an enum value for a schema of type t.
+func parseScalar(t, str string) (interface{}, error) {
+	switch t {
+	case Integer:
+		return strconv.ParseInt(str, 10, 64)
+	case Real, Number:
+		return strconv.ParseFloat(str, 64)
+	case Boolean:
+		return strconv.ParseBool(str)
+	}
+	return str, nil
+}
+
+func parseCount(str string) (*int, error) {
+	n, err := strconv.Atoi(str)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid count %q", str)
+	}
+	return &n, nil
+}
+
+func parseFloat(str string) (*float64, error) {
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
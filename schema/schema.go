@@ -0,0 +1,338 @@
+// Package schema validates decoded plists against a schema.
+//
+// Schemas are written in a dialect of JSON Schema, as a plist or JSON
+// document:
+//
+//	{
+//		type = dict;
+//		required = (CFBundleIdentifier);
+//		properties = {
+//			CFBundleIdentifier = {type = string; pattern = "^[A-Za-z0-9.-]+$";};
+//			LSMinimumSystemVersion = {type = string;};
+//			CFBundleSupportedPlatforms = {type = array; items = {type = string; enum = (iPhoneOS, MacOSX);};};
+//		};
+//	}
+//
+// The types are those of plists: dict, array, string, integer, real,
+// boolean, date, data and uid, as well as number, which is an integer
+// or a real. A schema without a type matches any value. The keywords
+// understood besides type are description, enum, pattern, minLength,
+// maxLength, minimum, maximum, items, minItems, maxItems, properties,
+// required, additionalProperties (a schema, or false to disallow
+// other keys) and anyOf.
+//
+// Schemas can also be derived from tagged Go structs with For.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/internal/dictkeys"
+	"github.com/mkrautz/plist/internal/typename"
+)
+
+// The types of values a schema can require.
+const (
+	Dict    = "dict"
+	Array   = "array"
+	String  = "string"
+	Integer = "integer"
+	Real    = "real"
+	Number  = "number"
+	Boolean = "boolean"
+	Date    = "date"
+	Data    = "data"
+	UID     = "uid"
+)
+
+// A Schema describes the values a plist may hold. The zero Schema
+// matches any value.
+type Schema struct {
+	Type        string
+	Description string
+	Enum        []interface{}
+
+	// For strings.
+	Pattern   string
+	MinLength *int
+	MaxLength *int
+
+	// For integers and reals.
+	Minimum *float64
+	Maximum *float64
+
+	// For arrays.
+	Items    *Schema
+	MinItems *int
+	MaxItems *int
+
+	// For dicts. Keys that are not in Properties must match
+	// AdditionalProperties, unless Closed is set, in which case
+	// they are not allowed at all.
+	Properties           map[string]*Schema
+	Required             []string
+	AdditionalProperties *Schema
+	Closed               bool
+
+	// AnyOf lists alternative schemas, at least one of which the
+	// value must match.
+	AnyOf []*Schema
+
+	pattern *regexp.Regexp
+}
+
+// Parse parses a schema written as a plist, or as JSON.
+func Parse(data []byte) (*Schema, error) {
+	var v interface{}
+	err := plist.Unmarshal(data, &v)
+	if err != nil {
+		jsonErr := plist.FromJSON(data, &v, plist.PlainJSON)
+		if jsonErr != nil {
+			return nil, err
+		}
+	}
+	return FromValue(v)
+}
+
+// FromValue converts a decoded schema document into a Schema.
+func FromValue(v interface{}) (*Schema, error) {
+	return fromValue(v, "")
+}
+
+func schemaError(path, format string, args ...interface{}) error {
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Errorf("plist: schema %s: %s", path, fmt.Sprintf(format, args...))
+}
+
+func fromValue(v interface{}, path string) (*Schema, error) {
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, schemaError(path, "expected dict, got %s", typename.Of(v))
+	}
+
+	s := new(Schema)
+	for _, key := range dictkeys.Sorted(dict) {
+		val := dict[key]
+		kpath := plist.ChildKeyPath(path, key)
+		var err error
+		switch key {
+		case "type":
+			s.Type, err = stringValue(val, kpath)
+			if err == nil && !knownType(s.Type) {
+				err = schemaError(kpath, "unknown type %q", s.Type)
+			}
+		case "description":
+			s.Description, err = stringValue(val, kpath)
+		case "enum":
+			a, ok := val.([]interface{})
+			if !ok {
+				err = schemaError(kpath, "expected array, got %s", typename.Of(val))
+			}
+			s.Enum = a
+		case "pattern":
+			s.Pattern, err = stringValue(val, kpath)
+			if err == nil {
+				s.pattern, err = regexp.Compile(s.Pattern)
+				if err != nil {
+					err = schemaError(kpath, "%v", err)
+				}
+			}
+		case "minLength":
+			s.MinLength, err = intValue(val, kpath)
+		case "maxLength":
+			s.MaxLength, err = intValue(val, kpath)
+		case "minimum":
+			s.Minimum, err = numberValue(val, kpath)
+		case "maximum":
+			s.Maximum, err = numberValue(val, kpath)
+		case "items":
+			s.Items, err = fromValue(val, kpath)
+		case "minItems":
+			s.MinItems, err = intValue(val, kpath)
+		case "maxItems":
+			s.MaxItems, err = intValue(val, kpath)
+		case "properties":
+			props, ok := val.(map[string]interface{})
+			if !ok {
+				err = schemaError(kpath, "expected dict, got %s", typename.Of(val))
+				break
+			}
+			s.Properties = make(map[string]*Schema, len(props))
+			for _, name := range dictkeys.Sorted(props) {
+				s.Properties[name], err = fromValue(props[name], plist.ChildKeyPath(kpath, name))
+				if err != nil {
+					break
+				}
+			}
+		case "required":
+			a, ok := val.([]interface{})
+			if !ok {
+				err = schemaError(kpath, "expected array, got %s", typename.Of(val))
+				break
+			}
+			for i, e := range a {
+				name, ok := e.(string)
+				if !ok {
+					err = schemaError(fmt.Sprintf("%s[%d]", kpath, i), "expected string, got %s", typename.Of(e))
+					break
+				}
+				s.Required = append(s.Required, name)
+			}
+		case "additionalProperties":
+			switch val {
+			case true, "true", "YES":
+			case false, "false", "NO":
+				s.Closed = true
+			default:
+				s.AdditionalProperties, err = fromValue(val, kpath)
+			}
+		case "anyOf":
+			a, ok := val.([]interface{})
+			if !ok {
+				err = schemaError(kpath, "expected array, got %s", typename.Of(val))
+				break
+			}
+			for i, e := range a {
+				var alt *Schema
+				alt, err = fromValue(e, fmt.Sprintf("%s[%d]", kpath, i))
+				if err != nil {
+					break
+				}
+				s.AnyOf = append(s.AnyOf, alt)
+			}
+		default:
+			err = schemaError(kpath, "unknown keyword")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func knownType(t string) bool {
+	switch t {
+	case Dict, Array, String, Integer, Real, Number, Boolean, Date, Data, UID:
+		return true
+	}
+	return false
+}
+
+func stringValue(v interface{}, path string) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", schemaError(path, "expected string, got %s", typename.Of(v))
+	}
+	return s, nil
+}
+
+// intValue reads a count. ASCII plists write integers as strings.
+func intValue(v interface{}, path string) (*int, error) {
+	f, err := numberValue(v, path)
+	if err != nil {
+		return nil, err
+	}
+	if *f < 0 || *f != float64(int(*f)) {
+		return nil, schemaError(path, "expected non-negative integer, got %v", *f)
+	}
+	n := int(*f)
+	return &n, nil
+}
+
+func numberValue(v interface{}, path string) (*float64, error) {
+	var f float64
+	switch n := v.(type) {
+	case int64:
+		f = float64(n)
+	case float64:
+		f = n
+	case string:
+		_, err := fmt.Sscan(n, &f)
+		if err != nil {
+			return nil, schemaError(path, "expected number, got %q", n)
+		}
+	default:
+		return nil, schemaError(path, "expected number, got %s", typename.Of(v))
+	}
+	return &f, nil
+}
+
+// Dict returns the schema as a dict, in the form Parse reads.
+func (s *Schema) Dict() map[string]interface{} {
+	dict := make(map[string]interface{})
+	if s.Type != "" {
+		dict["type"] = s.Type
+	}
+	if s.Description != "" {
+		dict["description"] = s.Description
+	}
+	if s.Enum != nil {
+		dict["enum"] = s.Enum
+	}
+	if s.Pattern != "" {
+		dict["pattern"] = s.Pattern
+	}
+	setInt(dict, "minLength", s.MinLength)
+	setInt(dict, "maxLength", s.MaxLength)
+	if s.Minimum != nil {
+		dict["minimum"] = *s.Minimum
+	}
+	if s.Maximum != nil {
+		dict["maximum"] = *s.Maximum
+	}
+	if s.Items != nil {
+		dict["items"] = s.Items.Dict()
+	}
+	setInt(dict, "minItems", s.MinItems)
+	setInt(dict, "maxItems", s.MaxItems)
+	if s.Properties != nil {
+		props := make(map[string]interface{}, len(s.Properties))
+		for name, prop := range s.Properties {
+			props[name] = prop.Dict()
+		}
+		dict["properties"] = props
+	}
+	if len(s.Required) > 0 {
+		req := make([]interface{}, len(s.Required))
+		for i, name := range s.Required {
+			req[i] = name
+		}
+		dict["required"] = req
+	}
+	if s.Closed {
+		dict["additionalProperties"] = false
+	} else if s.AdditionalProperties != nil {
+		dict["additionalProperties"] = s.AdditionalProperties.Dict()
+	}
+	if len(s.AnyOf) > 0 {
+		alts := make([]interface{}, len(s.AnyOf))
+		for i, alt := range s.AnyOf {
+			alts[i] = alt.Dict()
+		}
+		dict["anyOf"] = alts
+	}
+	return dict
+}
+
+func setInt(dict map[string]interface{}, key string, n *int) {
+	if n != nil {
+		dict[key] = int64(*n)
+	}
+}
+
+// compiled returns the compiled pattern of s.
+func (s *Schema) compiled() (*regexp.Regexp, error) {
+	if s.pattern == nil || s.pattern.String() != s.Pattern {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return nil, errors.New("invalid pattern: " + err.Error())
+		}
+		s.pattern = re
+	}
+	return s.pattern, nil
+}
@@ -0,0 +1,123 @@
+package schema
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/app.schema.json")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	s, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	buf, err = ioutil.ReadFile("testdata/app.plist")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	errs, err := s.ValidateData(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := []string{
+		`plist: schema violation at (root): missing required key "Version"`,
+		`plist: schema violation at Identifier: "com.example app" does not match pattern "^[A-Za-z0-9.-]+$"`,
+		`plist: schema violation at Limits.name: expected number, got string`,
+		`plist: schema violation at Mode: "automatic" is not one of ("auto", "manual")`,
+		`plist: schema violation at Servers[1]: missing required key "Host"`,
+		`plist: schema violation at Servers[1].Port: 70000 is greater than 65535`,
+		`plist: schema violation at Token: boolean does not match any of the allowed schemas`,
+		`plist: schema violation at Verbose: key is not allowed`,
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("unexpected errors:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestParseASCII(t *testing.T) {
+	s, err := Parse([]byte(`{
+		type = dict;
+		required = (Name);
+		additionalProperties = NO;
+		properties = {
+			Name = {type = string; maxLength = 4;};
+		};
+	}`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !s.Closed || *s.Properties["Name"].MaxLength != 4 {
+		t.Fatalf("unexpected schema %#v", s)
+	}
+
+	errs := s.Validate(map[string]interface{}{"Name": "Rosalind", "Age": int64(36)})
+	if len(errs) != 2 || errs[0].Path != "Age" || errs[1].Msg != "length 8 is greater than 4" {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	_, err = Parse([]byte(`{type = dictionary;}`))
+	if err == nil || err.Error() != `plist: schema type: unknown type "dictionary"` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+type server struct {
+	Host string `plist:"Host,required" schema:"minLength=1"`
+	Port int    `plist:"Port" schema:"minimum=1;maximum=65535"`
+}
+
+type app struct {
+	Identifier string             `plist:"Identifier,required" schema:"pattern=^[A-Za-z0-9.-]+$"`
+	Version    int64              `plist:"Version,required" schema:"minimum=1"`
+	Released   time.Time          `plist:"Released"`
+	Icon       []byte             `plist:"Icon"`
+	Mode       string             `plist:"Mode" schema:"enum=auto|manual"`
+	Servers    []server           `plist:"Servers,required" schema:"minItems=1"`
+	Limits     map[string]float64 `plist:"Limits"`
+	Token      interface{}        `plist:"Token"`
+	Ignored    string             `plist:"-"`
+	internal   string
+}
+
+func TestFor(t *testing.T) {
+	s, err := For(&app{})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Apart from anyOf and additionalProperties, the derived schema
+	// matches the hand-written one.
+	buf, err := ioutil.ReadFile("testdata/app.schema.json")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected, err := Parse(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected.Closed = false
+	expected.Properties["Token"] = new(Schema)
+	if !reflect.DeepEqual(s.Dict(), expected.Dict()) {
+		t.Fatalf("unexpected schema\n%v\nexpected\n%v", s.Dict(), expected.Dict())
+	}
+
+	errs := s.Validate(map[string]interface{}{
+		"Identifier": "com.example.app",
+		"Version":    int64(0),
+		"Servers":    []interface{}{map[string]interface{}{"Host": ""}},
+	})
+	if len(errs) != 2 || errs[0].Path != "Servers[0].Host" || errs[1].Path != "Version" {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+}
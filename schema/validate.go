@@ -0,0 +1,189 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mkrautz/plist"
+	"github.com/mkrautz/plist/internal/dictkeys"
+	"github.com/mkrautz/plist/internal/typename"
+)
+
+// A ValidationError describes a value that violates a schema.
+type ValidationError struct {
+	Path string // key path of the value, empty for the root
+	Msg  string
+}
+
+func (e ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("plist: schema violation at %s: %s", path, e.Msg)
+}
+
+// Validate checks the decoded plist value v against s and returns
+// every violation found, in key path order within each dict.
+func (s *Schema) Validate(v interface{}) []ValidationError {
+	var errs []ValidationError
+	s.validate(v, "", &errs)
+	return errs
+}
+
+// ValidateData decodes the plist data and validates it against s.
+func (s *Schema) ValidateData(data []byte) ([]ValidationError, error) {
+	var v interface{}
+	err := plist.Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+	return s.Validate(v), nil
+}
+
+func (s *Schema) validate(v interface{}, path string, errs *[]ValidationError) {
+	report := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{path, fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, alt := range s.AnyOf {
+			if len(alt.Validate(v)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("%s does not match any of the allowed schemas", typename.Of(v))
+			return
+		}
+	}
+
+	if s.Type != "" && !hasType(v, s.Type) {
+		report("expected %s, got %s", s.Type, typename.Of(v))
+		return
+	}
+
+	if s.Enum != nil {
+		found := false
+		for _, e := range s.Enum {
+			if equal(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("%s is not one of %s", format(v), formatList(s.Enum))
+		}
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			report("length %d is less than %d", n, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			report("length %d is greater than %d", n, *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			re, err := s.compiled()
+			if err != nil {
+				report("%v", err)
+			} else if !re.MatchString(v) {
+				report("%q does not match pattern %q", v, s.Pattern)
+			}
+		}
+	case int64, uint64, float64:
+		f, _ := number(v)
+		if s.Minimum != nil && f < *s.Minimum {
+			report("%v is less than %v", v, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			report("%v is greater than %v", v, *s.Maximum)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			report("%d items is fewer than %d", len(v), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			report("%d items is more than %d", len(v), *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, e := range v {
+				s.Items.validate(e, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				report("missing required key %q", name)
+			}
+		}
+		for _, key := range dictkeys.Sorted(v) {
+			kpath := plist.ChildKeyPath(path, key)
+			if prop, ok := s.Properties[key]; ok {
+				prop.validate(v[key], kpath, errs)
+			} else if s.Closed {
+				*errs = append(*errs, ValidationError{kpath, "key is not allowed"})
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(v[key], kpath, errs)
+			}
+		}
+	}
+}
+
+// hasType reports whether v is a value of the schema type t.
+func hasType(v interface{}, t string) bool {
+	if t == Number {
+		_, ok := number(v)
+		return ok
+	}
+	return typename.Of(v) == t
+}
+
+// number returns the value of an integer or real.
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// equal reports whether the plist values a and b are equal. Integers
+// and reals of the same value are equal.
+func equal(a, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	if x, ok := a.(time.Time); ok {
+		y, ok := b.(time.Time)
+		return ok && x.Equal(y)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func format(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func formatList(vals []interface{}) string {
+	strs := make([]string, len(vals))
+	for i, v := range vals {
+		strs[i] = format(v)
+	}
+	return "(" + strings.Join(strs, ", ") + ")"
+}
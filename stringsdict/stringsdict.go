@@ -0,0 +1,257 @@
+// Package stringsdict decodes, encodes and validates .stringsdict
+// files, the XML plists that hold the plural and device-specific
+// variants of localized strings.
+//
+// Each entry of a stringsdict file has a format string, which refers
+// to variables as %#@name@. Each variable selects one of its forms
+// according to a rule, such as the plural category of a number:
+//
+//	<key>%d files</key>
+//	<dict>
+//		<key>NSStringLocalizedFormatKey</key>
+//		<string>%#@files@</string>
+//		<key>files</key>
+//		<dict>
+//			<key>NSStringFormatSpecTypeKey</key>
+//			<string>NSStringPluralRuleType</string>
+//			<key>NSStringFormatValueTypeKey</key>
+//			<string>d</string>
+//			<key>one</key>
+//			<string>%d file</string>
+//			<key>other</key>
+//			<string>%d files</string>
+//		</dict>
+//	</dict>
+package stringsdict
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+
+	"github.com/mkrautz/plist/xmlplist"
+)
+
+// Keys used by stringsdict entries and variables.
+const (
+	FormatKey          = "NSStringLocalizedFormatKey"
+	FormatSpecTypeKey  = "NSStringFormatSpecTypeKey"
+	FormatValueTypeKey = "NSStringFormatValueTypeKey"
+)
+
+// Rule types of variables.
+const (
+	PluralRule         = "NSStringPluralRuleType"
+	DeviceSpecificRule = "NSStringDeviceSpecificRuleType"
+	VariableWidthRule  = "NSStringVariableWidthRuleType"
+)
+
+// A Variable selects one of its forms according to its rule. For
+// plural rules, the forms are keyed by plural category (zero, one,
+// two, few, many and other); for device-specific rules, by device
+// (such as iphone, ipad or mac); and for variable width rules, by
+// width.
+type Variable struct {
+	RuleType  string
+	ValueType string // the format specifier of the argument, such as "d"
+	Forms     map[string]string
+}
+
+// An Entry is a single localized string of a stringsdict file.
+//
+// An entry may also be a device-specific or variable width variation
+// itself, keyed by the rule type instead of a format string:
+//
+//	<key>TAP</key>
+//	<dict>
+//		<key>NSStringDeviceSpecificRuleType</key>
+//		<dict>
+//			<key>iphone</key>
+//			<string>Tap</string>
+//			<key>mac</key>
+//			<string>Click</string>
+//		</dict>
+//	</dict>
+//
+// Such entries are decoded into Variation, and need no format.
+type Entry struct {
+	Format    string
+	Variables map[string]Variable
+	Variation *Variable
+}
+
+// variationRules are the rule types an entry may be keyed by.
+var variationRules = []string{DeviceSpecificRule, VariableWidthRule}
+
+// A File maps the keys of a stringsdict file to their entries.
+type File map[string]Entry
+
+// Unmarshal parses the stringsdict data.
+func Unmarshal(data []byte) (File, error) {
+	return NewDecoder(bytes.NewBuffer(data)).Decode()
+}
+
+// Marshal returns the stringsdict encoding of f.
+func Marshal(f File) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := NewEncoder(buf).Encode(f)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// A Decoder reads stringsdict files.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder creates a new stringsdict reader.
+func NewDecoder(r io.Reader) *Decoder {
+	d := new(Decoder)
+	d.r = r
+	return d
+}
+
+// Decode reads a single stringsdict file.
+func (d *Decoder) Decode() (File, error) {
+	buf, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]interface{}
+	err = xmlplist.Unmarshal(buf, &root)
+	if err != nil {
+		return nil, err
+	}
+
+	f := make(File, len(root))
+	for key, v := range root {
+		dict, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("plist: stringsdict entry %q is not a dict", key)
+		}
+		var e Entry
+		e.Variables = make(map[string]Variable)
+		for name, vv := range dict {
+			if name == FormatKey {
+				continue
+			}
+			variable, err := decodeVariable(key, name, vv)
+			if err != nil {
+				return nil, err
+			}
+			if contains(variationRules, name) {
+				variable.RuleType = name
+				e.Variation = &variable
+				continue
+			}
+			e.Variables[name] = variable
+		}
+
+		format, ok := dict[FormatKey]
+		if ok {
+			e.Format, ok = format.(string)
+		}
+		if !ok && (format != nil || e.Variation == nil) {
+			return nil, fmt.Errorf("plist: stringsdict entry %q has no %s string", key, FormatKey)
+		}
+		f[key] = e
+	}
+	return f, nil
+}
+
+// decodeVariable decodes the variable name of the entry key.
+func decodeVariable(key, name string, v interface{}) (Variable, error) {
+	var variable Variable
+	vd, ok := v.(map[string]interface{})
+	if !ok {
+		return variable, fmt.Errorf("plist: variable %q of stringsdict entry %q is not a dict", name, key)
+	}
+	variable.Forms = make(map[string]string)
+	for k, fv := range vd {
+		s, ok := fv.(string)
+		if !ok {
+			return variable, fmt.Errorf("plist: %s of variable %q of stringsdict entry %q is not a string", k, name, key)
+		}
+		switch k {
+		case FormatSpecTypeKey:
+			variable.RuleType = s
+		case FormatValueTypeKey:
+			variable.ValueType = s
+		default:
+			variable.Forms[k] = s
+		}
+	}
+	return variable, nil
+}
+
+// An Encoder writes stringsdict files.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	enc := new(Encoder)
+	enc.w = w
+	return enc
+}
+
+// Encode writes f as an XML plist.
+func (e *Encoder) Encode(f File) error {
+	root := make(map[string]interface{}, len(f))
+	for key, entry := range f {
+		dict := make(map[string]interface{}, len(entry.Variables)+1)
+		if entry.Format != "" || entry.Variation == nil {
+			dict[FormatKey] = entry.Format
+		}
+		if v := entry.Variation; v != nil {
+			vd := make(map[string]interface{}, len(v.Forms))
+			for k, s := range v.Forms {
+				vd[k] = s
+			}
+			dict[v.RuleType] = vd
+		}
+		for name, v := range entry.Variables {
+			vd := make(map[string]interface{}, len(v.Forms)+2)
+			for k, s := range v.Forms {
+				vd[k] = s
+			}
+			vd[FormatSpecTypeKey] = v.RuleType
+			if v.ValueType != "" {
+				vd[FormatValueTypeKey] = v.ValueType
+			}
+			dict[name] = vd
+		}
+		root[key] = dict
+	}
+	return xmlplist.NewEncoder(e.w).Encode(root)
+}
+
+// variableRef matches a reference to a variable in a format string,
+// such as %#@files@ or %1$#@files@.
+var variableRef = regexp.MustCompile(`%(?:[0-9]+\$)?#@([^@]*)@`)
+
+// References returns the names of the variables referred to by
+// format, in order of appearance.
+func References(format string) []string {
+	var names []string
+	for _, m := range variableRef.FindAllStringSubmatch(format, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// Keys returns the keys of f in sorted order.
+func (f File) Keys() []string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,123 @@
+package stringsdict
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Localizable.stringsdict")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	f, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := Entry{
+		Format: "%#@files@",
+		Variables: map[string]Variable{
+			"files": {PluralRule, "d", map[string]string{"one": "%d file", "other": "%d files"}},
+		},
+	}
+	if !reflect.DeepEqual(f["%d files"], expected) {
+		t.Fatalf("got %#v, expected %#v", f["%d files"], expected)
+	}
+	if errs := f.Validate(); len(errs) != 0 {
+		t.Fatalf("unexpected validation errors: %v", errs)
+	}
+
+	out, err := Marshal(f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	g, err := Unmarshal(out)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(f, g) {
+		t.Fatalf("round trip mismatch: got %#v, expected %#v", g, f)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	f := File{
+		"%d apples": {
+			Format: "%1$#@apples@ and %#@pears@",
+			Variables: map[string]Variable{
+				"apples": {PluralRule, "d", map[string]string{"one": "%d apple", "lots": "%d apples"}},
+				"unused": {"NSStringFancyRuleType", "", nil},
+			},
+		},
+	}
+	var got []string
+	for _, err := range f.Validate() {
+		got = append(got, err.Error())
+	}
+	expected := []string{
+		`plist: stringsdict entry "%d apples": variable "pears": referenced but not defined`,
+		`plist: stringsdict entry "%d apples": variable "apples": missing "other" form`,
+		`plist: stringsdict entry "%d apples": variable "apples": unknown form "lots" for NSStringPluralRuleType`,
+		`plist: stringsdict entry "%d apples": variable "unused": defined but not referenced`,
+		`plist: stringsdict entry "%d apples": variable "unused": unknown rule type "NSStringFancyRuleType"`,
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestDecodeVariationEntries(t *testing.T) {
+	tests := []struct {
+		file string
+		key  string
+		rule string
+		form string
+	}{
+		{"testdata/DeviceSpecific.stringsdict", "TAP_TO_CONTINUE", DeviceSpecificRule, "mac"},
+		{"testdata/VariableWidth.stringsdict", "WELCOME", VariableWidthRule, "20"},
+	}
+	for _, test := range tests {
+		buf, err := ioutil.ReadFile(test.file)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		f, err := Unmarshal(buf)
+		if err != nil {
+			t.Fatalf("%s: %v", test.file, err)
+		}
+		e := f[test.key]
+		if e.Format != "" || len(e.Variables) != 0 || e.Variation == nil {
+			t.Fatalf("%s: got %#v", test.file, e)
+		}
+		if e.Variation.RuleType != test.rule || e.Variation.Forms[test.form] == "" {
+			t.Fatalf("%s: got variation %#v", test.file, e.Variation)
+		}
+		if errs := f.Validate(); len(errs) != 0 {
+			t.Fatalf("%s: unexpected validation errors: %v", test.file, errs)
+		}
+
+		out, err := Marshal(f)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if bytes.Contains(out, []byte(FormatKey)) || bytes.Contains(out, []byte(FormatSpecTypeKey)) {
+			t.Fatalf("%s: unexpected keys in output:\n%s", test.file, out)
+		}
+		g, err := Unmarshal(out)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !reflect.DeepEqual(f, g) {
+			t.Fatalf("round trip mismatch: got %#v, expected %#v", g, f)
+		}
+	}
+
+	f := File{"TAP": {Variation: &Variable{RuleType: DeviceSpecificRule, Forms: map[string]string{"toaster": "Toast"}}}}
+	errs := f.Validate()
+	if len(errs) != 1 || errs[0].Error() != `plist: stringsdict entry "TAP": unknown form "toaster" for NSStringDeviceSpecificRuleType` {
+		t.Fatalf("got %v", errs)
+	}
+}
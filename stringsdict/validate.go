@@ -0,0 +1,148 @@
+package stringsdict
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// A ValidationError describes a problem with a stringsdict entry.
+type ValidationError struct {
+	Key      string // the key of the entry
+	Variable string // the variable, if the problem concerns one
+	Msg      string
+}
+
+func (e ValidationError) Error() string {
+	if e.Variable == "" {
+		return fmt.Sprintf("plist: stringsdict entry %q: %s", e.Key, e.Msg)
+	}
+	return fmt.Sprintf("plist: stringsdict entry %q: variable %q: %s", e.Key, e.Variable, e.Msg)
+}
+
+// The forms allowed by each rule type. Variable width rules
+// are keyed by arbitrary widths.
+var ruleForms = map[string][]string{
+	PluralRule:         {"zero", "one", "two", "few", "many", "other"},
+	DeviceSpecificRule: {"appletv", "apple-reality", "applevision", "applewatch", "ipad", "iphone", "ipod", "mac", "other"},
+	VariableWidthRule:  nil,
+}
+
+// valueType matches the format specifiers allowed as value types.
+var valueType = regexp.MustCompile(`^(hh|h|l|ll|q|z|t|j)?[dDiuUxXoOfeEgGcCsSp@aA]$`)
+
+// Validate checks that the variables of each entry match the
+// references in its format strings: every referenced variable must
+// be defined, every defined variable must be referenced, and every
+// variable must have a known rule type, valid forms and, for plural
+// rules, a value type and an "other" form. The problems found are
+// returned in order of key.
+func (f File) Validate() []ValidationError {
+	var errs []ValidationError
+	for _, key := range f.Keys() {
+		e := f[key]
+		fail := func(variable, format string, args ...interface{}) {
+			errs = append(errs, ValidationError{key, variable, fmt.Sprintf(format, args...)})
+		}
+		if e.Format == "" && e.Variation == nil {
+			fail("", "empty %s", FormatKey)
+		}
+
+		// Variables may be referenced by the format string, or by
+		// the forms of other variables and of the variation.
+		referenced := make(map[string]bool)
+		for _, name := range References(e.Format) {
+			referenced[name] = true
+		}
+		if v := e.Variation; v != nil {
+			for _, form := range v.Forms {
+				for _, ref := range References(form) {
+					referenced[ref] = true
+				}
+			}
+			if !contains(variationRules, v.RuleType) {
+				fail("", "unknown variation rule type %q", v.RuleType)
+			} else if allowed := ruleForms[v.RuleType]; allowed != nil {
+				for _, form := range sortedForms(v.Forms) {
+					if !contains(allowed, form) {
+						fail("", "unknown form %q for %s", form, v.RuleType)
+					}
+				}
+			}
+		}
+		names := make([]string, 0, len(e.Variables))
+		for name, v := range e.Variables {
+			names = append(names, name)
+			for _, form := range v.Forms {
+				for _, ref := range References(form) {
+					referenced[ref] = true
+				}
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range sortedSet(referenced) {
+			if _, ok := e.Variables[name]; !ok {
+				fail(name, "referenced but not defined")
+			}
+		}
+		for _, name := range names {
+			v := e.Variables[name]
+			if !referenced[name] {
+				fail(name, "defined but not referenced")
+			}
+			allowed, ok := ruleForms[v.RuleType]
+			if !ok {
+				fail(name, "unknown rule type %q", v.RuleType)
+				continue
+			}
+			if v.RuleType == PluralRule {
+				if v.ValueType == "" {
+					fail(name, "missing %s", FormatValueTypeKey)
+				}
+				if _, ok := v.Forms["other"]; !ok {
+					fail(name, `missing "other" form`)
+				}
+			}
+			if v.ValueType != "" && !valueType.MatchString(v.ValueType) {
+				fail(name, "invalid value type %q", v.ValueType)
+			}
+			if allowed == nil {
+				continue
+			}
+			for _, form := range sortedForms(v.Forms) {
+				if !contains(allowed, form) {
+					fail(name, "unknown form %q for %s", form, v.RuleType)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func sortedSet(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedForms(forms map[string]string) []string {
+	keys := make([]string, 0, len(forms))
+	for k := range forms {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, elem := range list {
+		if elem == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package stringsfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/mkrautz/plist/internal/asciilex"
+)
+
+// Unmarshal parses the strings file data and stores the result
+// in the value pointed to by v.
+func Unmarshal(data []byte, v interface{}) error {
+	return NewDecoder(bytes.NewBuffer(data)).Decode(v)
+}
+
+// A Decoder reads strings files.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder creates a new strings file reader.
+func NewDecoder(r io.Reader) *Decoder {
+	d := new(Decoder)
+	d.r = r
+	return d
+}
+
+// Decode reads a strings file into v, which must be a *File, a
+// *map[string]string, a *map[string]interface{} or an *interface{}.
+// Maps lose the order and comments of the entries.
+//
+// The encoding of the file is detected from its byte order mark.
+// Files without one are read as UTF-8, unless they appear to be
+// UTF-16.
+func (d *Decoder) Decode(v interface{}) error {
+	buf, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+	text, enc, err := decodeText(buf)
+	if err != nil {
+		return err
+	}
+	f, err := parse(text)
+	if err != nil {
+		return err
+	}
+	f.Encoding = enc
+
+	switch p := v.(type) {
+	case *File:
+		*p = *f
+	case *map[string]string:
+		*p = f.Map()
+	case *map[string]interface{}:
+		m := make(map[string]interface{}, len(f.Entries))
+		for k, val := range f.Map() {
+			m[k] = val
+		}
+		*p = m
+	case *interface{}:
+		m := make(map[string]interface{}, len(f.Entries))
+		for k, val := range f.Map() {
+			m[k] = val
+		}
+		*p = m
+	default:
+		return fmt.Errorf("plist: cannot decode strings file into %T", v)
+	}
+	return nil
+}
+
+// decodeText converts the raw bytes of a strings file into a string,
+// and reports the encoding they were in.
+func decodeText(buf []byte) (string, Encoding, error) {
+	var enc Encoding
+	switch {
+	case bytes.HasPrefix(buf, []byte{0xef, 0xbb, 0xbf}):
+		enc, buf = UTF8BOM, buf[3:]
+	case bytes.HasPrefix(buf, []byte{0xff, 0xfe}):
+		enc, buf = UTF16LE, buf[2:]
+	case bytes.HasPrefix(buf, []byte{0xfe, 0xff}):
+		enc, buf = UTF16BE, buf[2:]
+	case len(buf) >= 2 && buf[0] == 0 && buf[1] != 0:
+		enc = UTF16BE
+	case len(buf) >= 2 && buf[0] != 0 && buf[1] == 0:
+		enc = UTF16LE
+	}
+
+	if enc == UTF8 || enc == UTF8BOM {
+		if !utf8.Valid(buf) {
+			return "", enc, errors.New("plist: strings file is not valid UTF-8")
+		}
+		return string(buf), enc, nil
+	}
+
+	if len(buf)%2 != 0 {
+		return "", enc, errors.New("plist: strings file has an odd number of UTF-16 bytes")
+	}
+	units := make([]uint16, len(buf)/2)
+	for i := range units {
+		if enc == UTF16LE {
+			units[i] = uint16(buf[2*i]) | uint16(buf[2*i+1])<<8
+		} else {
+			units[i] = uint16(buf[2*i])<<8 | uint16(buf[2*i+1])
+		}
+	}
+	return string(utf16.Decode(units)), enc, nil
+}
+
+// A parser holds the state of parsing a single strings file.
+type parser struct {
+	lex      *asciilex.Lexer
+	comments []Comment
+}
+
+func errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("plist: strings file line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// next returns the next token that is not a comment. Comments are
+// collected.
+func (p *parser) next() (asciilex.Token, error) {
+	for {
+		tok, err := p.lex.Next()
+		if err != nil {
+			if e, ok := err.(*asciilex.Error); ok {
+				return tok, errorf(e.Line, "%s", e.Msg)
+			}
+			return tok, err
+		}
+		if tok.Kind != asciilex.Comment {
+			return tok, nil
+		}
+		p.comments = append(p.comments, Comment{strings.TrimSpace(tok.Text), tok.LineComment})
+	}
+}
+
+// describe describes tok for an error message.
+func describe(tok asciilex.Token) string {
+	switch tok.Kind {
+	case asciilex.EOF:
+		return "end of file"
+	case asciilex.String:
+		return strconv.Quote(tok.Text)
+	case asciilex.Data:
+		return "data"
+	}
+	return "'" + tok.Text + "'"
+}
+
+// parse parses the text of a strings file.
+func parse(text string) (*File, error) {
+	p := &parser{lex: asciilex.New(text)}
+	f := new(File)
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if tok.Kind == asciilex.EOF {
+			break
+		}
+		if tok.Kind != asciilex.String {
+			return nil, errorf(tok.Line, "unexpected %s", describe(tok))
+		}
+
+		e := Entry{Key: tok.Text}
+		e.Comments, p.comments = p.comments, nil
+
+		tok, err = p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case tok.Kind == asciilex.Punct && tok.Text == ";":
+			// "key"; is short for "key" = "key";
+			e.Value = e.Key
+		case tok.Kind == asciilex.Punct && tok.Text == "=":
+			tok, err = p.next()
+			if err != nil {
+				return nil, err
+			}
+			if tok.Kind != asciilex.String {
+				return nil, errorf(tok.Line, "unexpected %s as value of %q", describe(tok), e.Key)
+			}
+			e.Value = tok.Text
+			tok, err = p.next()
+			if err != nil {
+				return nil, err
+			}
+			if tok.Kind != asciilex.Punct || tok.Text != ";" {
+				return nil, errorf(tok.Line, "expected ';' after value of %q", e.Key)
+			}
+		default:
+			return nil, errorf(tok.Line, "expected '=' or ';' after key %q", e.Key)
+		}
+		f.Entries = append(f.Entries, e)
+	}
+	f.Trailer = p.comments
+	return f, nil
+}
@@ -0,0 +1,153 @@
+package stringsfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Marshal returns the encoding of v as a strings file.
+func Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := NewEncoder(buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// An Encoder writes strings files.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	enc := new(Encoder)
+	enc.w = w
+	return enc
+}
+
+// Encode writes v as a strings file. v may be a File or *File,
+// which is written in its own encoding, or a map[string]string or
+// map[string]interface{} holding only strings, which is written as
+// UTF-8 in sorted key order.
+//
+// Entries are written in the style of genstrings: each entry on
+// its own line, preceded by its comments, and separated from the
+// next entry by a blank line.
+func (e *Encoder) Encode(v interface{}) error {
+	var f *File
+	switch val := v.(type) {
+	case *File:
+		f = val
+	case File:
+		f = &val
+	case map[string]string:
+		f = new(File)
+		for k, s := range val {
+			f.Entries = append(f.Entries, Entry{Key: k, Value: s})
+		}
+		sortEntries(f.Entries)
+	case map[string]interface{}:
+		f = new(File)
+		for k, elem := range val {
+			s, ok := elem.(string)
+			if !ok {
+				return fmt.Errorf("plist: strings file value for %q is %T, not a string", k, elem)
+			}
+			f.Entries = append(f.Entries, Entry{Key: k, Value: s})
+		}
+		sortEntries(f.Entries)
+	default:
+		return fmt.Errorf("plist: cannot encode %T as a strings file", v)
+	}
+
+	var sb strings.Builder
+	for i, entry := range f.Entries {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		writeComments(&sb, entry.Comments)
+		sb.WriteString(quote(entry.Key) + " = " + quote(entry.Value) + ";\n")
+	}
+	if len(f.Trailer) > 0 {
+		if len(f.Entries) > 0 {
+			sb.WriteByte('\n')
+		}
+		writeComments(&sb, f.Trailer)
+	}
+
+	_, err := e.w.Write(encodeText(sb.String(), f.Encoding))
+	return err
+}
+
+// writeComments writes comments one per line, in the style they
+// were written in. A // comment spanning several lines is written
+// as a /* */ comment.
+func writeComments(sb *strings.Builder, comments []Comment) {
+	for _, c := range comments {
+		if c.Line && !strings.Contains(c.Text, "\n") {
+			sb.WriteString("// " + c.Text + "\n")
+			continue
+		}
+		// A comment cannot contain its own terminator.
+		text := strings.Replace(c.Text, "*/", "* /", -1)
+		sb.WriteString("/* " + text + " */\n")
+	}
+}
+
+// quote returns str as a quoted, escaped string.
+func quote(str string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range str {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// encodeText converts text into the bytes of the given encoding.
+func encodeText(text string, enc Encoding) []byte {
+	switch enc {
+	case UTF8BOM:
+		return append([]byte{0xef, 0xbb, 0xbf}, text...)
+	case UTF16LE, UTF16BE:
+		units := utf16.Encode([]rune(text))
+		buf := make([]byte, 2+2*len(units))
+		units = append([]uint16{0xfeff}, units...)
+		for i, u := range units {
+			if enc == UTF16LE {
+				buf[2*i], buf[2*i+1] = byte(u), byte(u>>8)
+			} else {
+				buf[2*i], buf[2*i+1] = byte(u>>8), byte(u)
+			}
+		}
+		return buf
+	}
+	return []byte(text)
+}
+
+// sortEntries sorts entries by key.
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+}
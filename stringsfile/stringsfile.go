@@ -0,0 +1,115 @@
+// Package stringsfile decodes and encodes .strings files, the
+// localization dialect of ASCII plists used by Localizable.strings
+// and InfoPlist.strings.
+//
+// A strings file is a dict without the surrounding braces:
+//
+//	/* Title of the main window */
+//	"WINDOW_TITLE" = "Hello, world";
+//
+// Unlike the other decoders in this module, the decoder keeps the
+// entries of a strings file in order, along with the comments that
+// precede each entry, so that files round-trip with the context
+// translators rely on.
+package stringsfile
+
+import (
+	"strconv"
+)
+
+// An Encoding is a text encoding of strings files.
+type Encoding int
+
+const (
+	UTF8    Encoding = iota // UTF-8 without a byte order mark
+	UTF8BOM                 // UTF-8 with a byte order mark
+	UTF16LE                 // little-endian UTF-16 with a byte order mark
+	UTF16BE                 // big-endian UTF-16 with a byte order mark
+)
+
+// String returns the name of the encoding.
+func (e Encoding) String() string {
+	switch e {
+	case UTF8:
+		return "UTF-8"
+	case UTF8BOM:
+		return "UTF-8 with BOM"
+	case UTF16LE:
+		return "UTF-16LE"
+	case UTF16BE:
+		return "UTF-16BE"
+	}
+	return "Encoding(" + strconv.Itoa(int(e)) + ")"
+}
+
+// An Entry is a single key-value pair of a strings file.
+type Entry struct {
+	Key   string
+	Value string
+
+	// The comments preceding the entry.
+	Comments []Comment
+}
+
+// A Comment is a comment of a strings file.
+type Comment struct {
+	// The text of the comment, without its delimiters and
+	// surrounding whitespace.
+	Text string
+
+	// Whether the comment is a // comment rather than a /* */ one.
+	Line bool
+}
+
+// A File is the contents of a strings file.
+type File struct {
+	Entries []Entry
+
+	// Comments found after the last entry.
+	Trailer []Comment
+
+	// The encoding the file was read from, or should be written in.
+	Encoding Encoding
+}
+
+// Get returns the value for key.
+func (f *File) Get(key string) (string, bool) {
+	for _, e := range f.Entries {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets the value for key, keeping the position and comments of
+// an existing entry. New entries are appended.
+func (f *File) Set(key, value string) {
+	for i := range f.Entries {
+		if f.Entries[i].Key == key {
+			f.Entries[i].Value = value
+			return
+		}
+	}
+	f.Entries = append(f.Entries, Entry{Key: key, Value: value})
+}
+
+// Delete removes the entry for key, if any.
+func (f *File) Delete(key string) {
+	for i := range f.Entries {
+		if f.Entries[i].Key == key {
+			f.Entries = append(f.Entries[:i], f.Entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Map returns the entries of the file as a map. If a key occurs more
+// than once, the last entry wins, as it does in Foundation.
+func (f *File) Map() map[string]string {
+	m := make(map[string]string, len(f.Entries))
+	for _, e := range f.Entries {
+		m[e.Key] = e.Value
+	}
+	return m
+}
@@ -0,0 +1,126 @@
+package stringsfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeUTF16(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Localizable.strings")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var f File
+	err = Unmarshal(buf, &f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := File{
+		Entries: []Entry{
+			{"WINDOW_TITLE", "Hello, world", []Comment{{"Localizable.strings\n   Example", false}, {"Title of the main window", false}}},
+			{"GREETING", "Grüß Gott, \"%@\"!\n", []Comment{{"Greeting shown at launch", true}}},
+			{"TOOLBAR_ADD", "Add", []Comment{{"Shown in the toolbar", false}, {"Keep it short", false}}},
+			{"SameAsKey", "SameAsKey", nil},
+			{"unquoted", "value", nil},
+			{"emoji", "\U0001F600 \U0001F600", nil},
+		},
+		Trailer:  []Comment{{"TODO: more strings", false}},
+		Encoding: UTF16LE,
+	}
+	if !reflect.DeepEqual(f, expected) {
+		t.Fatalf("got %#v, expected %#v", f, expected)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Localizable.strings")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var f File
+	err = Unmarshal(buf, &f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	f.Set("GREETING", "Hi")
+	f.Set("NEW", "New")
+	f.Delete("unquoted")
+
+	out, err := Marshal(&f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.HasPrefix(out, []byte{0xff, 0xfe}) {
+		t.Fatalf("encoding not preserved")
+	}
+
+	var g File
+	err = Unmarshal(out, &g)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(f, g) {
+		t.Fatalf("got %#v, expected %#v", g, f)
+	}
+}
+
+func TestEncodeCommentStyles(t *testing.T) {
+	in := "// Greeting\n\"a\" = \"b\";\n\n/* Farewell */\n// Keep it short\nc = d;\n"
+	var f File
+	err := Unmarshal([]byte(in), &f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	out, err := Marshal(&f)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := "// Greeting\n\"a\" = \"b\";\n\n/* Farewell */\n// Keep it short\n\"c\" = \"d\";\n"
+	if string(out) != expected {
+		t.Fatalf("got %q, expected %q", out, expected)
+	}
+}
+
+func TestEncodeMap(t *testing.T) {
+	out, err := Marshal(map[string]string{"b": "2", "a": "tab\there"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := "\"a\" = \"tab\\there\";\n\n\"b\" = \"2\";\n"
+	if string(out) != expected {
+		t.Fatalf("unexpected output %q", out)
+	}
+
+	var m map[string]string
+	err = Unmarshal(out, &m)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(m, map[string]string{"b": "2", "a": "tab\there"}) {
+		t.Fatalf("unexpected map %#v", m)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	errTests := []struct {
+		Input string
+		Error string
+	}{
+		{"\"a\" = \"b\"\n\"c\" = \"d\";", `plist: strings file line 2: expected ';' after value of "a"`},
+		{"\"a\" \"b\";", `plist: strings file line 1: expected '=' or ';' after key "a"`},
+		{"/* open", "plist: strings file line 1: unterminated comment"},
+		{"\"a\" = \"b", "plist: strings file line 1: unterminated string"},
+		{"\"a\" = \"b\";\n\"c\" = (d);", `plist: strings file line 2: unexpected '(' as value of "c"`},
+		{"\"a\" = \"b\";\n\n#", "plist: strings file line 3: unexpected character '#'"},
+	}
+	for _, et := range errTests {
+		var f File
+		err := Unmarshal([]byte(et.Input), &f)
+		if err == nil || err.Error() != et.Error {
+			t.Errorf("%q: expected error %q, got %v", et.Input, et.Error, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Package xcstrings decodes and encodes Xcode string catalogs
+// (.xcstrings files), and converts between them and the .strings and
+// .stringsdict files they replace.
+//
+// A string catalog is a JSON file holding every localization of every
+// string of a table:
+//
+//	{
+//	  "sourceLanguage" : "en",
+//	  "strings" : {
+//	    "WINDOW_TITLE" : {
+//	      "comment" : "Title of the main window",
+//	      "localizations" : {
+//	        "de" : {
+//	          "stringUnit" : {
+//	            "state" : "translated",
+//	            "value" : "Hallo, Welt"
+//	          }
+//	        }
+//	      }
+//	    }
+//	  },
+//	  "version" : "1.0"
+//	}
+package xcstrings
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+)
+
+// A Catalog is the contents of a string catalog. Fields of the JSON
+// file that are not modelled here are dropped when decoding.
+type Catalog struct {
+	SourceLanguage string             `json:"sourceLanguage"`
+	Strings        map[string]*String `json:"strings"`
+	Version        string             `json:"version"`
+}
+
+// A String is a single localizable string, keyed by its key in
+// Catalog.Strings.
+type String struct {
+	Comment         string                   `json:"comment,omitempty"`
+	ExtractionState string                   `json:"extractionState,omitempty"`
+	Localizations   map[string]*Localization `json:"localizations,omitempty"`
+	ShouldTranslate *bool                    `json:"shouldTranslate,omitempty"`
+}
+
+// A Localization is the value of a string in one language. It either
+// holds a plain value, or varies by plural category or device. A
+// plain value may refer to substitutions as %#@name@, each of which
+// varies in turn.
+type Localization struct {
+	StringUnit    *StringUnit              `json:"stringUnit,omitempty"`
+	Substitutions map[string]*Substitution `json:"substitutions,omitempty"`
+	Variations    Variations               `json:"variations,omitempty"`
+}
+
+// Variations maps a kind of variation ("plural" or "device") to the
+// localization of each of its forms, such as "one" and "other".
+type Variations map[string]map[string]*Localization
+
+// A StringUnit is a localized value along with its translation state,
+// such as "translated" or "needs_review".
+type StringUnit struct {
+	State string `json:"state"`
+	Value string `json:"value"`
+}
+
+// A Substitution is a variable part of a localized value. The values
+// of its variations refer to the argument as %arg.
+type Substitution struct {
+	ArgNum          int        `json:"argNum,omitempty"`
+	FormatSpecifier string     `json:"formatSpecifier"`
+	Variations      Variations `json:"variations,omitempty"`
+}
+
+// Common translation states.
+const (
+	Translated  = "translated"
+	NeedsReview = "needs_review"
+	New         = "new"
+)
+
+// Unmarshal parses the string catalog data.
+func Unmarshal(data []byte) (*Catalog, error) {
+	return NewDecoder(bytes.NewBuffer(data)).Decode()
+}
+
+// Marshal returns the encoding of c, formatted as Xcode writes it.
+func Marshal(c *Catalog) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := NewEncoder(buf).Encode(c)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// A Decoder reads string catalogs.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder creates a new string catalog reader.
+func NewDecoder(r io.Reader) *Decoder {
+	d := new(Decoder)
+	d.r = r
+	return d
+}
+
+// Decode reads a single string catalog.
+func (d *Decoder) Decode() (*Catalog, error) {
+	buf, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Catalog)
+	err = json.Unmarshal(buf, c)
+	if err != nil {
+		return nil, err
+	}
+	if c.Strings == nil {
+		c.Strings = make(map[string]*String)
+	}
+	return c, nil
+}
+
+// An Encoder writes string catalogs.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	enc := new(Encoder)
+	enc.w = w
+	return enc
+}
+
+// Encode writes c with sorted keys, two-space indentation and a
+// space on both sides of each colon, as Xcode does, so that saving
+// the catalog in Xcode afterwards produces no diff.
+func (e *Encoder) Encode(c *Catalog) error {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(c)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(spaceColons(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))))
+	return err
+}
+
+// spaceColons turns the "key": value pairs of indented JSON into
+// "key" : value.
+func spaceColons(data []byte) []byte {
+	out := make([]byte, 0, len(data)+len(data)/16)
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString && c == '\\':
+			out = append(out, c, data[i+1])
+			i++
+			continue
+		case c == '"':
+			inString = !inString
+		case !inString && c == ':':
+			out = append(out, ' ')
+		}
+		out = append(out, c)
+	}
+	return out
+}
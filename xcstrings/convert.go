@@ -0,0 +1,277 @@
+package xcstrings
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mkrautz/plist/stringsdict"
+	"github.com/mkrautz/plist/stringsfile"
+)
+
+// A Table holds the strings of one language in their pre-catalog
+// form: a .strings file for plain strings, and a .stringsdict file
+// for strings that vary.
+type Table struct {
+	Strings *stringsfile.File
+	Dict    stringsdict.File
+}
+
+// The variation kinds of the stringsdict rule types that string
+// catalogs support.
+var variationKinds = map[string]string{
+	stringsdict.PluralRule:         "plural",
+	stringsdict.DeviceSpecificRule: "device",
+}
+
+// ruleType returns the stringsdict rule type of a variation kind.
+func ruleType(kind string) (string, bool) {
+	for rule, k := range variationKinds {
+		if k == kind {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+// FromTables builds a catalog from the tables of each language. All
+// values are marked as translated, and the comments of the source
+// language's .strings file become the comments of the catalog.
+//
+// An entry of a .stringsdict file whose format is a lone variable,
+// or that is a variation itself, becomes a localization with
+// variations; other entries become substitutions, numbered in the
+// order they appear in the format.
+func FromTables(sourceLanguage string, tables map[string]Table) (*Catalog, error) {
+	c := &Catalog{
+		SourceLanguage: sourceLanguage,
+		Strings:        make(map[string]*String),
+		Version:        "1.0",
+	}
+	str := func(key string) *String {
+		s, ok := c.Strings[key]
+		if !ok {
+			s = &String{Localizations: make(map[string]*Localization)}
+			c.Strings[key] = s
+		}
+		return s
+	}
+
+	langs := make([]string, 0, len(tables))
+	for lang := range tables {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	for _, lang := range langs {
+		t := tables[lang]
+		if t.Strings != nil {
+			for _, e := range t.Strings.Entries {
+				s := str(e.Key)
+				s.Localizations[lang] = &Localization{
+					StringUnit: &StringUnit{Translated, e.Value},
+				}
+				if lang == sourceLanguage && len(e.Comments) > 0 {
+					lines := make([]string, len(e.Comments))
+					for i, c := range e.Comments {
+						lines[i] = c.Text
+					}
+					s.Comment = strings.Join(lines, "\n")
+				}
+			}
+		}
+		for _, key := range t.Dict.Keys() {
+			loc, err := fromEntry(t.Dict[key])
+			if err != nil {
+				return nil, fmt.Errorf("plist: stringsdict entry %q (%s): %v", key, lang, err)
+			}
+			str(key).Localizations[lang] = loc
+		}
+	}
+	return c, nil
+}
+
+// fromEntry converts a stringsdict entry to a localization.
+func fromEntry(e stringsdict.Entry) (*Localization, error) {
+	if e.Variation != nil && e.Format == "" {
+		vars, err := fromVariable(*e.Variation, "")
+		if err != nil {
+			return nil, err
+		}
+		return &Localization{Variations: vars}, nil
+	}
+
+	refs := stringsdict.References(e.Format)
+	if len(refs) == 1 && e.Format == "%#@"+refs[0]+"@" {
+		v, ok := e.Variables[refs[0]]
+		if !ok {
+			return nil, fmt.Errorf("variable %q not defined", refs[0])
+		}
+		vars, err := fromVariable(v, "")
+		if err != nil {
+			return nil, err
+		}
+		return &Localization{Variations: vars}, nil
+	}
+
+	loc := &Localization{
+		StringUnit:    &StringUnit{Translated, e.Format},
+		Substitutions: make(map[string]*Substitution),
+	}
+	for _, name := range refs {
+		if _, ok := loc.Substitutions[name]; ok {
+			continue
+		}
+		v, ok := e.Variables[name]
+		if !ok {
+			return nil, fmt.Errorf("variable %q not defined", name)
+		}
+		vars, err := fromVariable(v, "%"+v.ValueType)
+		if err != nil {
+			return nil, err
+		}
+		loc.Substitutions[name] = &Substitution{
+			ArgNum:          len(loc.Substitutions) + 1,
+			FormatSpecifier: v.ValueType,
+			Variations:      vars,
+		}
+	}
+	return loc, nil
+}
+
+// fromVariable converts the forms of a stringsdict variable to
+// variations. If arg is not empty, it is replaced by %arg.
+func fromVariable(v stringsdict.Variable, arg string) (Variations, error) {
+	kind, ok := variationKinds[v.RuleType]
+	if !ok {
+		return nil, fmt.Errorf("rule type %q has no string catalog equivalent", v.RuleType)
+	}
+	forms := make(map[string]*Localization, len(v.Forms))
+	for form, value := range v.Forms {
+		if arg != "" {
+			value = strings.Replace(value, arg, "%arg", -1)
+		}
+		forms[form] = &Localization{StringUnit: &StringUnit{Translated, value}}
+	}
+	return Variations{kind: forms}, nil
+}
+
+// Table extracts the strings of one language from c. Strings of the
+// source language that have no localization take their key as their
+// value, as they do in Xcode. Strings with variations are returned in
+// the stringsdict part of the table; a lone variation is given the
+// variable name "value".
+func (c *Catalog) Table(lang string) (Table, error) {
+	t := Table{
+		Strings: new(stringsfile.File),
+		Dict:    make(stringsdict.File),
+	}
+	keys := make([]string, 0, len(c.Strings))
+	for k := range c.Strings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := c.Strings[key]
+		loc := s.Localizations[lang]
+		if loc == nil && lang == c.SourceLanguage {
+			loc = &Localization{StringUnit: &StringUnit{Translated, key}}
+		}
+		if loc == nil {
+			continue
+		}
+
+		if loc.Variations == nil && loc.Substitutions == nil {
+			if loc.StringUnit == nil {
+				continue
+			}
+			e := stringsfile.Entry{Key: key, Value: loc.StringUnit.Value}
+			if s.Comment != "" {
+				for _, line := range strings.Split(s.Comment, "\n") {
+					e.Comments = append(e.Comments, stringsfile.Comment{Text: line})
+				}
+			}
+			t.Strings.Entries = append(t.Strings.Entries, e)
+			continue
+		}
+
+		e, err := toEntry(loc)
+		if err != nil {
+			return Table{}, fmt.Errorf("plist: string %q (%s): %v", key, lang, err)
+		}
+		t.Dict[key] = e
+	}
+	return t, nil
+}
+
+// toEntry converts a localization with variations or substitutions
+// to a stringsdict entry.
+func toEntry(loc *Localization) (stringsdict.Entry, error) {
+	e := stringsdict.Entry{Variables: make(map[string]stringsdict.Variable)}
+	if loc.Variations != nil {
+		v, err := toVariable(loc.Variations, "")
+		if err != nil {
+			return e, err
+		}
+		e.Format = "%#@value@"
+		e.Variables["value"] = v
+		return e, nil
+	}
+
+	if loc.StringUnit == nil {
+		return e, fmt.Errorf("substitutions without a value")
+	}
+	e.Format = loc.StringUnit.Value
+	for name, sub := range loc.Substitutions {
+		v, err := toVariable(sub.Variations, sub.FormatSpecifier)
+		if err != nil {
+			return e, err
+		}
+		e.Variables[name] = v
+	}
+	return e, nil
+}
+
+// formatSpec matches the first format specifier of a value.
+var formatSpec = regexp.MustCompile(`%(?:[0-9]+\$)?((?:hh|h|ll|l|q|z|t|j)?[dDiuUxXoOfeEgGcCsSp@aA])`)
+
+// toVariable converts variations to a stringsdict variable. If spec
+// is not empty, %arg is replaced by it; otherwise, the value type of
+// a plural variable is taken from the format specifiers of its forms.
+func toVariable(vars Variations, spec string) (stringsdict.Variable, error) {
+	var v stringsdict.Variable
+	if len(vars) != 1 {
+		return v, fmt.Errorf("%d kinds of variations, stringsdict supports one", len(vars))
+	}
+	for kind, forms := range vars {
+		rule, ok := ruleType(kind)
+		if !ok {
+			return v, fmt.Errorf("unknown variation kind %q", kind)
+		}
+		v.RuleType = rule
+		v.ValueType = spec
+		v.Forms = make(map[string]string, len(forms))
+		for form, loc := range forms {
+			if loc == nil || loc.StringUnit == nil {
+				return v, fmt.Errorf("nested variations in form %q", form)
+			}
+			value := loc.StringUnit.Value
+			if spec != "" {
+				value = strings.Replace(value, "%arg", "%"+spec, -1)
+			}
+			v.Forms[form] = value
+		}
+	}
+	if v.RuleType == stringsdict.PluralRule && v.ValueType == "" {
+		v.ValueType = "d"
+		for _, form := range []string{"other", "many", "few", "two", "one", "zero"} {
+			if m := formatSpec.FindStringSubmatch(v.Forms[form]); m != nil {
+				v.ValueType = m[1]
+				break
+			}
+		}
+	}
+	return v, nil
+}
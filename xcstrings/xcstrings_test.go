@@ -0,0 +1,162 @@
+package xcstrings
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/mkrautz/plist/stringsdict"
+	"github.com/mkrautz/plist/stringsfile"
+)
+
+var testTables = map[string]Table{
+	"en": {
+		Strings: &stringsfile.File{Entries: []stringsfile.Entry{
+			{Key: "GREETING", Value: "Hello, \"%@\"", Comments: []stringsfile.Comment{{Text: "Greeting shown at launch"}}},
+			{Key: "TAP", Value: "Tap"},
+			{Key: "WINDOW_TITLE", Value: "Hello, world"},
+		}},
+		Dict: stringsdict.File{
+			"%d files": {
+				Format: "%#@value@",
+				Variables: map[string]stringsdict.Variable{
+					"value": {RuleType: stringsdict.PluralRule, ValueType: "lld", Forms: map[string]string{"one": "%lld file", "other": "%lld files"}},
+				},
+			},
+			"%d files in %@": {
+				Format: "%#@files@ in %@",
+				Variables: map[string]stringsdict.Variable{
+					"files": {RuleType: stringsdict.PluralRule, ValueType: "d", Forms: map[string]string{"one": "%d file", "other": "%d files"}},
+				},
+			},
+		},
+	},
+	"de": {
+		Strings: &stringsfile.File{Entries: []stringsfile.Entry{
+			{Key: "GREETING", Value: "Hallo, „%@“", Comments: []stringsfile.Comment{{Text: "Begrüßung"}}},
+		}},
+		Dict: stringsdict.File{
+			"TAP": {
+				Format: "%#@value@",
+				Variables: map[string]stringsdict.Variable{
+					"value": {RuleType: stringsdict.DeviceSpecificRule, Forms: map[string]string{"iphone": "Tippen", "mac": "Klicken"}},
+				},
+			},
+		},
+	},
+}
+
+func TestFromTables(t *testing.T) {
+	c, err := FromTables("en", testTables)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	out, err := Marshal(c)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected, err := ioutil.ReadFile("testdata/Localizable.xcstrings")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(out, expected) {
+		t.Fatalf("got:\n%s\nexpected:\n%s", out, expected)
+	}
+}
+
+func TestTable(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/Localizable.xcstrings")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	c, err := Unmarshal(buf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for lang, expected := range testTables {
+		table, err := c.Table(lang)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if lang != c.SourceLanguage {
+			// Only the source language carries comments.
+			for i := range expected.Strings.Entries {
+				table.Strings.Entries[i].Comments = expected.Strings.Entries[i].Comments
+			}
+		}
+		if !reflect.DeepEqual(table, expected) {
+			t.Fatalf("%s: got %#v, expected %#v", lang, table, expected)
+		}
+	}
+}
+
+func TestUnsupportedRule(t *testing.T) {
+	_, err := FromTables("en", map[string]Table{
+		"en": {Dict: stringsdict.File{
+			"WIDTH": {
+				Format: "%#@w@",
+				Variables: map[string]stringsdict.Variable{
+					"w": {RuleType: stringsdict.VariableWidthRule, Forms: map[string]string{"1": "S", "20": "Short"}},
+				},
+			},
+		}},
+	})
+	if err == nil {
+		t.Fatalf("expected error for variable width rule")
+	}
+}
+
+func TestRoundTripShouldTranslate(t *testing.T) {
+	data := `{
+  "sourceLanguage" : "en",
+  "strings" : {
+    "APP_NAME" : {
+      "comment" : "Not translated",
+      "extractionState" : "manual",
+      "localizations" : {
+        "en" : {
+          "stringUnit" : {
+            "state" : "translated",
+            "value" : "Example"
+          }
+        }
+      },
+      "shouldTranslate" : false
+    }
+  },
+  "version" : "1.0"
+}`
+	c, err := Unmarshal([]byte(data))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if s := c.Strings["APP_NAME"]; s.ShouldTranslate == nil || *s.ShouldTranslate {
+		t.Fatalf("shouldTranslate not decoded")
+	}
+	out, err := Marshal(c)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if string(out) != data {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestFromVariationEntry(t *testing.T) {
+	c, err := FromTables("en", map[string]Table{
+		"en": {Dict: stringsdict.File{
+			"TAP": {Variation: &stringsdict.Variable{
+				RuleType: stringsdict.DeviceSpecificRule,
+				Forms:    map[string]string{"iphone": "Tap", "mac": "Click"},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	forms := c.Strings["TAP"].Localizations["en"].Variations["device"]
+	if forms["mac"] == nil || forms["mac"].StringUnit.Value != "Click" {
+		t.Fatalf("got %#v", forms)
+	}
+}
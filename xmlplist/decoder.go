@@ -10,7 +10,12 @@ import (
 	"io"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/mkrautz/plist/binaryplist"
+	"github.com/mkrautz/plist/internal/keypath"
+	"github.com/mkrautz/plist/internal/plistreflect"
 )
 
 // Unmarshal parses the XML-plist data and stores the result
@@ -24,19 +29,93 @@ func Unmarshal(data []byte, v interface{}) error {
 // XML-style plists.
 type Decoder struct {
 	xd  *xml.Decoder
+
+	// The position of the last token read by nextElement, and the
+	// positions recorded by Decode, if asked for.
+	pos       Position
+	positions map[string]Position
+	path      string
+
+	ordered bool
 }
 
-// NewDecoder creates a new XML plist reader.
+// A Position is the location of a value in an XML plist.
+type Position struct {
+	Offset int64 // the byte offset of the value's start element
+	Line   int   // the line of the start element, counting from 1
+}
+
+// NewDecoder creates a new XML plist reader. The plist must be
+// UTF-8; documents that declare a UTF-16 encoding are read as
+// UTF-8, since plist.Decoder converts them before decoding.
 func NewDecoder(r io.Reader) *Decoder {
 	d := new(Decoder)
 	d.xd = xml.NewDecoder(r)
+	d.xd.CharsetReader = charsetReader
 	return d
 }
 
+// UseOrderedDicts makes Decode return dicts as OrderedDict values,
+// with their entries in the order of the input, when v points to an
+// empty interface.
+func (d *Decoder) UseOrderedDicts() {
+	d.ordered = true
+}
+
+// RecordPositions makes the decoder record the position of each value
+// it reads, which Positions returns after Decode.
+func (d *Decoder) RecordPositions() {
+	d.positions = make(map[string]Position)
+}
+
+// Positions returns the positions of the values read by the last call
+// to Decode, keyed by their key paths, as read by plist.Get. The root
+// value has the key path "". Positions returns nil unless
+// RecordPositions was called.
+func (d *Decoder) Positions() map[string]Position {
+	return d.positions
+}
+
+// record records the position of the last element read as that of the
+// value at the current key path.
+func (d *Decoder) record() {
+	if d.positions != nil {
+		d.positions[d.path] = d.pos
+	}
+}
+
+// enter records the position of the last element read as that of the
+// value at the key path made from the current one by child, which then
+// becomes the current key path. It returns the previous key path, for
+// the caller to restore.
+func (d *Decoder) enter(child func(path string) string) string {
+	parent := d.path
+	if d.positions != nil {
+		d.path = child(parent)
+		d.record()
+	}
+	return parent
+}
+
+// charsetReader reads documents that declare a UTF-16 encoding as
+// they are. The XML parser cannot read UTF-16 text, so a document
+// that is still in UTF-16 fails before its declaration is read.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(label) {
+	case "utf-16", "utf-16le", "utf-16be":
+		return input, nil
+	}
+	return nil, fmt.Errorf("plist: unsupported encoding %q", label)
+}
+
 // nextElement returns the next StartElement or EndElement
 // token found in the stream.
 func (d *Decoder) nextElement() (xml.Token, error) {
 	for {
+		if d.positions != nil {
+			d.pos.Offset = d.xd.InputOffset()
+			d.pos.Line, _ = d.xd.InputPos()
+		}
 		t, err := d.xd.Token()
 		if err != nil {
 			return nil, err
@@ -68,21 +147,12 @@ func (d *Decoder) readEndElement(name string) error {
 	return nil
 }
 
-// expectWhitespace reads the next element (expected to be a
-// charadata token), and checks whether it only contains whitespace.
+// checkWhitespace checks whether cd only contains whitespace.
 // If not, it returns an error.
-func (d *Decoder) expectWhitespace() error {
-	t, err := d.xd.Token()
-	if err != nil {
-		return err
-	}
-	cd, ok := t.(xml.CharData)
-	if !ok {
-		return errors.New("plist: expected newline")
-	}
+func checkWhitespace(cd xml.CharData) error {
 	for _, r := range cd {
 		switch r {
-			case '\n', '\t', ' ':
+			case '\n', '\r', '\t', ' ':
 				// ok
 			default:
 				return fmt.Errorf("plist: unexpected character in whitespace: %q", r)
@@ -93,46 +163,41 @@ func (d *Decoder) expectWhitespace() error {
 
 // Decode decodes a single XML plist from the decoder.
 func (d *Decoder) Decode(v interface{}) error {
-	t, err := d.xd.Token()
-	if err != nil {
-		return err
-	}
-
-	// <?xml ...?>
-	pi, ok := t.(xml.ProcInst)
-	if !ok {
-		return errors.New("plist: expected ProcInst as first element")
-	}
-	if pi.Target != "xml" {
-		return errors.New("plist: expected xml ProcInst")
-	}
-
-	// \n
-	err = d.expectWhitespace()
-	if err != nil {
-		return err
+	if d.positions != nil {
+		d.positions = make(map[string]Position)
+		d.path = ""
 	}
-
-	// doctype
-	t, err = d.xd.Token()
-	if err != nil {
-		return err
-	}
-	directive, ok := t.(xml.Directive)
-	if !ok {
-		return errors.New("plist: expected directive")
-	}
-	if string(directive) != xmlPlistDocType {
-		return errors.New("plist: expected plist DTD")
-	}
-
-	// \n
-	err = d.expectWhitespace()
-	if err != nil {
-		return err
+	// The XML declaration, the doctype and comments, any of which may
+	// be left out, surrounded by whitespace.
+	for {
+		t, err := d.xd.Token()
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case xml.CharData:
+			err = checkWhitespace(t)
+			if err != nil {
+				return err
+			}
+			continue
+		case xml.Comment:
+			continue
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				return errors.New("plist: expected xml ProcInst")
+			}
+			continue
+		case xml.Directive:
+			if string(t) != xmlPlistDocType {
+				return errors.New("plist: expected plist DTD")
+			}
+			continue
+		case xml.StartElement:
+			return d.parsePlist(v, t)
+		}
+		return errors.New("plist: expected StartElement")
 	}
-
-	return d.parsePlist(v)
 }
 
 // expectCharData reads the next token of the stream,
@@ -176,31 +241,23 @@ func (d *Decoder) expectCharDataOrEndElement(name string) (buf []byte, endelem b
 	panic("unreachable")
 }
 
-// parsePlist parses the first <plist> StartElement and
+// parsePlist parses the first <plist> StartElement, se, and
 // begins reading the root element of the plist into v.
-func (d *Decoder) parsePlist(v interface{}) error {
-	t, err := d.nextElement()
-	if err != nil {
-		return err
-	}
-
+func (d *Decoder) parsePlist(v interface{}, se xml.StartElement) error {
 	// <plist version="xxxx">
-	se, ok := t.(xml.StartElement)
-	if !ok {
-		return errors.New("plist: expected StartElement")
-	}
 	if se.Name.Local != "plist" {
 		return errors.New("plist: expected <plist> StartElement")
 	}
-	if len(se.Attr) != 1 {
+	// The version attribute may be left out.
+	if len(se.Attr) > 1 {
 		return errors.New("plist: unexpected amount of attrs to plist StartElement")
 	}
-	if se.Attr[0].Name.Local != "version" && se.Attr[0].Value != xmlPlistVersion {
+	if len(se.Attr) == 1 && (se.Attr[0].Name.Local != "version" || se.Attr[0].Value != xmlPlistVersion) {
 		return errors.New("plist: unexpected plist version")
 	}
 
 	// Read the root element of the plist
-	t, err = d.nextElement()
+	t, err := d.nextElement()
 	if err != nil {
 		return err
 	}
@@ -209,7 +266,7 @@ func (d *Decoder) parsePlist(v interface{}) error {
 	// is the root element. If it isn't, check whether it's an
 	// EndElement. It could potentially be the </plist> tag,
 	// resulting in an empty plist.
-	se, ok = t.(xml.StartElement)
+	se, ok := t.(xml.StartElement)
 	if !ok {
 		if ee, ok := t.(xml.EndElement); ok {
 			if ee.Name.Local == "plist" {
@@ -218,6 +275,7 @@ func (d *Decoder) parsePlist(v interface{}) error {
 		}
 		return errors.New("plist: expected StartElement (or EndElement)")
 	}
+	d.record()
 
 	err = d.readRootType(v, se)
 	if err != nil {
@@ -254,6 +312,7 @@ func (d *Decoder) readDict(v interface{}, se xml.StartElement) error {
 	}
 
 	dictMap := map[string]interface{}{}
+	var keys []string
 	for {
 		// read <key>
 		t, err := d.nextElement()
@@ -274,24 +333,29 @@ func (d *Decoder) readDict(v interface{}, se xml.StartElement) error {
 			return errors.New("bad key name")
 		}
 
-		// read key name
-		keyNameBuf, err := d.expectCharData()
+		// read key name, which may be empty
+		keyNameBuf, end, err := d.expectCharDataOrEndElement("key")
 		if err != nil {
 			return err
 		}
 		keyName := string(keyNameBuf)
+		if _, dup := dictMap[keyName]; !dup {
+			keys = append(keys, keyName)
+		}
 
 		// read </key>
-		t, err = d.nextElement()
-		if err != nil {
-			return err
-		}
-		ee, ok := t.(xml.EndElement)
-		if !ok {
-			return errors.New("plist: unexpected tag")
-		}
-		if ee.Name.Local != "key" {
-			return errors.New("plist: expected end element for key")
+		if !end {
+			t, err = d.nextElement()
+			if err != nil {
+				return err
+			}
+			ee, ok := t.(xml.EndElement)
+			if !ok {
+				return errors.New("plist: unexpected tag")
+			}
+			if ee.Name.Local != "key" {
+				return errors.New("plist: expected end element for key")
+			}
 		}
 
 		// read type
@@ -303,15 +367,20 @@ func (d *Decoder) readDict(v interface{}, se xml.StartElement) error {
 		if !ok {
 			return errors.New("plist: expected start of type")
 		}
+		parent := d.enter(func(path string) string { return keypath.Child(path, keyName) })
 
 		switch se.Name.Local {
 		case "dict":
-			var m map[string]interface{}
+			var m interface{}
 			err = d.readDict(&m, se)
 			if err != nil {
 				return err
 			}
-			dictMap[keyName] = m
+			if uid, ok := dictUID(m); ok {
+				dictMap[keyName] = uid
+			} else {
+				dictMap[keyName] = m
+			}
 		case "array":
 			var a []interface{}
 			err = d.readArray(&a, se)
@@ -362,105 +431,33 @@ func (d *Decoder) readDict(v interface{}, se xml.StartElement) error {
 			}
 			dictMap[keyName] = i
 		}
+		d.path = parent
 	}
 
+	// UID dicts are left as maps for the caller to convert.
 	rv := reflect.ValueOf(v).Elem()
-	return mapToValue(dictMap, rv)
-}
-
-// mapToStruct converts the map-representation of the dictionary in dict
-// into a struct or a map given as val. Recursive structs and maps are
-// supported.
-func mapToValue(dict map[string]interface{}, val reflect.Value) error { 
-	if val.Kind() == reflect.Map {
-		val.Set(reflect.ValueOf(dict))
-	} else if val.Kind() == reflect.Struct {
-		typ := val.Type()
-		nfields := val.NumField()
-nextField:
-		for i := 0; i < nfields; i++ {
-			f := typ.Field(i)
-			name := f.Tag.Get("plist")
-			if name != "" {
-				if dictVal, ok := dict[name]; ok {
-					fieldVal := val.Field(i)
-					if fieldVal.Kind() == reflect.Map || fieldVal.Kind() == reflect.Struct {
-						newDict, ok := dictVal.(map[string]interface{})
-						if !ok {
-							panic("plist: internal dict value does not map to map[string]interface{}")
-						}
-						err := mapToValue(newDict, fieldVal)
-						if err != nil {
-							return err
-						}
-					} else if fieldVal.Kind() == reflect.Slice {
-						switch t := dictVal.(type) {
-						case []interface{}: // array
-							if len(t) == 0 {
-								fieldVal.Set(reflect.Zero(fieldVal.Type()))
-							} else {
-								targetTyp := fieldVal.Type().Elem()
-								actualTyp := reflect.TypeOf(t[0])
-
-								ok := false
-								// []interface{} fast path.
-								if targetTyp.Kind() == reflect.Interface {
-									fieldVal.Set(reflect.ValueOf(t))
-									continue nextField
-								// int32/int64 ambiguitiy.
-								} else if targetTyp.Kind() == reflect.Int {
-									switch actualTyp.Kind() {
-									case reflect.Int64, reflect.Int32:
-										ok = true
-									}
-								// uint32/uint64 ambiguity.
-								} else if targetTyp.Kind() == reflect.Uint {
-									switch actualTyp.Kind() {
-									case reflect.Uint64, reflect.Uint32:
-										ok = true
-									}
-								} else if targetTyp.Kind() == actualTyp.Kind() {
-									ok = true
-								}
-								if !ok {
-									return fmt.Errorf("plist: expected target type %v, got %v", targetTyp, actualTyp)
-								}
-
-								sv := reflect.MakeSlice(reflect.SliceOf(targetTyp), 0, len(t))
-								if targetTyp.Kind() == reflect.Int || targetTyp.Kind() == reflect.Uint {
-									for i := 0; i < len(t); i++ {
-										switch v := t[i].(type) {
-										case int32:
-											sv = reflect.Append(sv, reflect.ValueOf(int(v)))
-										case int64:
-											sv = reflect.Append(sv, reflect.ValueOf(int(v)))
-										case uint32:
-											sv = reflect.Append(sv, reflect.ValueOf(uint(v)))
-										case uint64:
-											sv = reflect.Append(sv, reflect.ValueOf(uint(v)))
-										}
-									}
-								} else {
-									for i := 0; i < len(t); i++ {
-										reflect.Append(sv, reflect.ValueOf(t[i]))
-									}
-								}
-								fieldVal.Set(sv)
-							}
-						case []byte: // data
-							fieldVal.Set(reflect.ValueOf(t))
-						}
-					} else {
-						if fieldVal.Type() == reflect.ValueOf(dictVal).Type() {
-							fieldVal.Set(reflect.ValueOf(dictVal))
-						}
-					}
-				}
-			}
+	if _, uid := dictUID(dictMap); d.ordered && rv.Kind() == reflect.Interface && !uid {
+		od := make(binaryplist.OrderedDict, len(keys))
+		for i, k := range keys {
+			od[i] = binaryplist.DictEntry{Key: k, Value: dictMap[k]}
 		}
+		return plistreflect.Set(rv, od)
 	}
+	return plistreflect.Set(rv, dictMap)
+}
 
-	return nil
+// dictUID returns the UID represented by v, if it is a
+// dict of the form {CF$UID = n}.
+func dictUID(v interface{}) (binaryplist.UID, bool) {
+	dict, ok := v.(map[string]interface{})
+	if !ok || len(dict) != 1 {
+		return 0, false
+	}
+	n, ok := dict["CF$UID"].(int64)
+	if !ok || n < 0 {
+		return 0, false
+	}
+	return binaryplist.UID(n), true
 }
 
 // readArray reads an XML plist array into v. The se parameter must be
@@ -488,15 +485,21 @@ func (d *Decoder) readArray(v interface{}, se xml.StartElement) error {
 			}
 			return errors.New("plist: expected StartElement")
 		}
+		index := len(slice)
+		parent := d.enter(func(path string) string { return keypath.Index(path, index) })
 
 		switch se.Name.Local {
 		case "dict":
-			var m map[string]interface{}
+			var m interface{}
 			err = d.readDict(&m, se)
 			if err != nil {
 				return err
 			}
-			slice = append(slice, m)
+			if uid, ok := dictUID(m); ok {
+				slice = append(slice, uid)
+			} else {
+				slice = append(slice, m)
+			}
 		case "array":
 			var a []interface{}
 			err = d.readArray(&a, se)
@@ -547,16 +550,10 @@ func (d *Decoder) readArray(v interface{}, se xml.StartElement) error {
 			}
 			slice = append(slice, i)
 		}
+		d.path = parent
 	}
 
-	rv := reflect.ValueOf(v).Elem()
-	k := rv.Kind()
-	if k != reflect.Slice && k != reflect.Array {
-		return fmt.Errorf("plist: cannot read array into %v field", k)
-	}
-	rv.Set(reflect.ValueOf(slice))
-
-	return nil
+	return plistreflect.Set(reflect.ValueOf(v).Elem(), slice)
 }
 
 // readBool reads an XML plist boolean into the value v.
@@ -633,7 +630,8 @@ func (d *Decoder) readData(v interface{}, se xml.StartElement) error {
 		return nil
 	}
 
-	dst, err := base64.StdEncoding.DecodeString(string(src))
+	// Data is usually wrapped and indented.
+	dst, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(src)), ""))
 	if err != nil {
 		return err
 	}
@@ -26,3 +26,52 @@ func TestAlfredWorkflow(t *testing.T) {
 		t.Errorf("expected bundleid value %v; got %v", expected, v)
 	}
 }
+
+type alfredWorkflow struct {
+	Bundleid    string                        `plist:"bundleid"`
+	Connections map[string][]alfredConnection `plist:"connections"`
+	Objects     []alfredObject                `plist:"objects"`
+	Uidata      map[string]alfredUidata       `plist:"uidata"`
+}
+
+type alfredConnection struct {
+	Destinationuid string `plist:"destinationuid"`
+	Modifiers      int64  `plist:"modifiers"`
+}
+
+type alfredObject struct {
+	Config struct {
+		Escaping int64 `plist:"escaping"`
+	} `plist:"config"`
+	UID string `plist:"uid"`
+}
+
+type alfredUidata struct {
+	Ypos float64 `plist:"ypos"`
+}
+
+func TestAlfredWorkflowToStruct(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/AlfredTimeKeeper.alfredworkflow")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var workflow alfredWorkflow
+	err = Unmarshal(buf, &workflow)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	conns := workflow.Connections["02659E2A-7ABB-4AFC-A9B9-62ACE375A522"]
+	expected := []alfredConnection{{Destinationuid: "1739AB08-C0AD-47E2-9EE4-64CBE569D4F3"}}
+	if !reflect.DeepEqual(conns, expected) {
+		t.Errorf("expected connections %v; got %v", expected, conns)
+	}
+	if len(workflow.Objects) == 0 || workflow.Objects[0].Config.Escaping != 63 {
+		t.Fatalf("unexpected objects %v", workflow.Objects)
+	}
+	for uid, ui := range workflow.Uidata {
+		if ui.Ypos == 0 {
+			t.Errorf("expected ypos for %s", uid)
+		}
+	}
+}
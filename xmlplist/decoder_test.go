@@ -2,6 +2,8 @@ package xmlplist
 
 import (
 	"io/ioutil"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -234,4 +236,47 @@ func TestDecodeIntoWrongType(t *testing.T) {
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
-}
\ No newline at end of file
+}
+
+func TestDecodePositions(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>a.b</key>
+	<array>
+		<string>x</string>
+		<dict>
+			<key>c</key>
+			<integer>1</integer>
+		</dict>
+	</array>
+	<key>d</key><true/>
+</dict>
+</plist>
+`
+	dec := NewDecoder(strings.NewReader(doc))
+	dec.RecordPositions()
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	lines := make(map[string]int)
+	for path, pos := range dec.Positions() {
+		if !strings.HasPrefix(doc[pos.Offset:], "<") {
+			t.Fatalf("offset of %q is not at a start element", path)
+		}
+		lines[path] = pos.Line
+	}
+	expected := map[string]int{
+		"":          3,
+		`a\.b`:      5,
+		`a\.b[0]`:   6,
+		`a\.b[1]`:   7,
+		`a\.b[1].c`: 9,
+		"d":         12,
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("got %v, expected %v", lines, expected)
+	}
+}
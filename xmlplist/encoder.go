@@ -1,16 +1,28 @@
 package xmlplist
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/base64"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/mkrautz/plist/binaryplist"
+	"github.com/mkrautz/plist/internal/plistreflect"
+)
+
+var (
+	uidType         = reflect.TypeOf(binaryplist.UID(0))
+	orderedDictType = reflect.TypeOf(binaryplist.OrderedDict(nil))
+	timeType        = reflect.TypeOf(time.Time{})
 )
 
 // Marshal returns the XML plist encoding of v.
@@ -26,23 +38,53 @@ func Marshal(v interface{}) ([]byte, error) {
 
 // An Encoder encodes Go values into 
 // the XML plist format.
+//
+// Strings and keys are escaped as CoreFoundation escapes them: only
+// &, < and > are replaced by entities, and carriage returns, which
+// CoreFoundation writes as is, are written as &#13; so that they
+// survive decoding. XML 1.0 cannot represent control characters other
+// than tab, newline and carriage return, U+FFFE and U+FFFF, so strings
+// holding them, or holding invalid UTF-8, cannot be encoded and Encode
+// returns an error. The binary format can hold such strings.
 type Encoder struct {
 	w            io.Writer
-	bw           *bufio.Writer
+	bw           *bytes.Buffer // the document being encoded
 	indentLevel  int
+
+	prefix      string
+	indentStr   string
+	compact     bool
+	selfClosing bool
+	crlf        bool
+	omitDocType bool
+	apple       bool
 }
 
 // Returns a string that conforms to the current indent level.
 // Strings that are output by the encoder should always have the
 // output of this function after a newline.
 func (e *Encoder) indent() string {
-	var b []byte
+	if e.compact {
+		return ""
+	}
+	b := []byte(e.prefix)
 	for i := 0; i < e.indentLevel; i++ {
-		b = append(b, '\t')
+		b = append(b, e.indentStr...)
 	}
 	return string(b)
 }
 
+// newline returns the line ending of the encoder's output.
+func (e *Encoder) newline() string {
+	switch {
+	case e.compact:
+		return ""
+	case e.crlf:
+		return "\r\n"
+	}
+	return "\n"
+}
+
 // Writes a string (including proper indentation) to the Encoder.
 func (e *Encoder) writeString(str string) error {
 	_, err := e.bw.WriteString(e.indent() + str)
@@ -53,71 +95,151 @@ func (e *Encoder) writeString(str string) error {
 }
 
 // NewEncoder returns a new Encoder capable of encoding XML plists.
+// By default, the output is indented with tabs and has LF line
+// endings.
 func NewEncoder(w io.Writer) *Encoder {
 	enc := new(Encoder)
 	enc.w = w
-	enc.bw = bufio.NewWriter(w)
+	enc.bw = new(bytes.Buffer)
+	enc.indentStr = "\t"
 	return enc
 }
 
+// SetIndent makes each line of the output after the first begin with
+// prefix, followed by one copy of indent per level of nesting.
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.prefix = prefix
+	e.indentStr = indent
+}
+
+// SetCompact makes the encoder write the whole document on a
+// single line, without indentation or line endings.
+func (e *Encoder) SetCompact(compact bool) {
+	e.compact = compact
+}
+
+// SetSelfClosing makes the encoder write empty dicts and arrays
+// as <dict/> and <array/>.
+func (e *Encoder) SetSelfClosing(selfClosing bool) {
+	e.selfClosing = selfClosing
+}
+
+// SetCRLF makes the encoder end lines with CR LF rather than LF.
+func (e *Encoder) SetCRLF(crlf bool) {
+	e.crlf = crlf
+}
+
+// SetOmitDocType makes the encoder leave out the DOCTYPE
+// declaration.
+func (e *Encoder) SetOmitDocType(omit bool) {
+	e.omitDocType = omit
+}
+
+// SetAppleStyle makes the output match that of CoreFoundation's
+// CFPropertyListCreateXMLData, as written by Xcode and plutil: tab
+// indentation, LF line endings, self-closing empty dicts and arrays,
+// and reals written with 17 significant digits. It resets the other
+// style options.
+func (e *Encoder) SetAppleStyle() {
+	e.prefix = ""
+	e.indentStr = "\t"
+	e.compact = false
+	e.selfClosing = true
+	e.crlf = false
+	e.omitDocType = false
+	e.apple = true
+}
+
 // Encode writes the XML plist encoding of v to the encoder's
-// writer.
+// writer. The document is built in memory first, so nothing is
+// written when v cannot be encoded.
 func (e *Encoder) Encode(v interface{}) error {
-	err := e.writeString(xml.Header)
-	if err != nil {
-		return err
-	} 
-
-	err = e.writeString("<!" + xmlPlistDocType + ">\n")
+	rv, err := plistreflect.Marshal(reflect.ValueOf(v))
 	if err != nil {
 		return err
 	}
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	root := false
+	switch rv.Kind() {
+	case reflect.Slice:
+		_, data := rv.Interface().([]byte)
+		root = !data
+	case reflect.Array, reflect.Map:
+		root = true
+	case reflect.Struct:
+		root = rv.Type() != timeType
+	}
+	if !root {
+		return errors.New("plist: bad root element: must be dict or array")
+	}
 
-	err = e.writeString("<plist version=\""+ xmlPlistVersion + "\">\n")
+	e.bw.Reset()
+	e.indentLevel = 0
+
+	// As with json.Indent, the prefix is not written on the first
+	// line, so that the XML declaration comes first.
+	_, err = e.bw.WriteString(strings.TrimSuffix(xml.Header, "\n") + e.newline())
 	if err != nil {
 		return err
-	}
-
-	if _, isData := v.([]byte); isData {
-		return errors.New("plist: bad root element: must be dict or map")
-	}
+	} 
 
-	rv := reflect.ValueOf(v)
-	switch rv.Kind() {
-	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
-		err = e.encodeAny(rv)
+	if !e.omitDocType {
+		err = e.writeString("<!" + xmlPlistDocType + ">" + e.newline())
 		if err != nil {
 			return err
 		}
-	default:
-		return errors.New("plist: bad root element: must be dict or map")
 	}
 
-	err = e.writeString("</plist>\n")
+	err = e.writeString("<plist version=\""+ xmlPlistVersion + "\">" + e.newline())
 	if err != nil {
 		return err
 	}
 
-	err = e.bw.Flush()
+	err = e.encodeAny(rv)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// The document ends with a line ending, even when compact.
+	end := e.newline()
+	if end == "" {
+		end = "\n"
+	}
+	err = e.writeString("</plist>" + end)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.w.Write(e.bw.Bytes())
+	return err
 }
 
 // encodeAny encodes any type into its XML plist equivalent.
 func (e *Encoder) encodeAny(rv reflect.Value) (err error) {
+	rv, err = plistreflect.Marshal(rv)
+	if err != nil {
+		return err
+	}
 	switch rv.Kind() {
 	case reflect.Slice, reflect.Array:
 		_, data := rv.Interface().([]byte)
-		if data {
+		if rv.Type() == orderedDictType {
+			err = e.encodeOrderedDict(rv.Interface().(binaryplist.OrderedDict))
+		} else if data {
 			err = e.encodeData(rv)	
 		} else {
 			err = e.encodeArray(rv)
 		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		err = e.encodeInt(rv)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Type() == uidType {
+			err = e.encodeUID(rv)
+		} else {
+			err = e.encodeUint(rv)
+		}
 	case reflect.Float32, reflect.Float64:
 		err = e.encodeFloat(rv)
 	case reflect.Bool:
@@ -132,6 +254,11 @@ func (e *Encoder) encodeAny(rv reflect.Value) (err error) {
 		} else {
 			err = e.encodeStruct(rv)
 		}
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			return errors.New("plist: cannot encode nil value")
+		}
+		err = e.encodeAny(rv.Elem())
 	default:
 		return fmt.Errorf("plist: cannot encode %v", rv.Kind())
 	}
@@ -140,7 +267,11 @@ func (e *Encoder) encodeAny(rv reflect.Value) (err error) {
 
 // encodeArray encodes an array type to the XML plist format.
 func (e *Encoder) encodeArray(rv reflect.Value) error {
-	err := e.writeString("<array>\n")
+	if e.selfClosing && rv.Len() == 0 {
+		return e.writeString("<array/>" + e.newline())
+	}
+
+	err := e.writeString("<array>" + e.newline())
 	if err != nil {
 		return err
 	}
@@ -157,7 +288,7 @@ func (e *Encoder) encodeArray(rv reflect.Value) error {
 
 	e.indentLevel--
 
-	err = e.writeString("</array>\n")
+	err = e.writeString("</array>" + e.newline())
 	if err != nil {
 		return err
 	}
@@ -168,17 +299,61 @@ func (e *Encoder) encodeArray(rv reflect.Value) error {
 // encodeInt encodes an integer type to the XML plist format.
 func (e *Encoder) encodeInt(rv reflect.Value) error {
 	val := rv.Int()
-	err := e.writeString("<integer>" + strconv.FormatInt(val, 10) + "</integer>\n")
+	err := e.writeString("<integer>" + strconv.FormatInt(val, 10) + "</integer>" + e.newline())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// encodeUint encodes an unsigned integer type to the XML plist format.
+func (e *Encoder) encodeUint(rv reflect.Value) error {
+	val := rv.Uint()
+	err := e.writeString("<integer>" + strconv.FormatUint(val, 10) + "</integer>" + e.newline())
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// encodeUID encodes a UID to the XML plist format, as a dict
+// with the single key CF$UID.
+func (e *Encoder) encodeUID(rv reflect.Value) error {
+	err := e.writeString("<dict>" + e.newline())
+	if err != nil {
+		return err
+	}
+	e.indentLevel++
+	err = e.writeString("<key>CF$UID</key>" + e.newline())
+	if err != nil {
+		return err
+	}
+	err = e.encodeUint(rv)
+	if err != nil {
+		return err
+	}
+	e.indentLevel--
+	return e.writeString("</dict>" + e.newline())
+}
+
 // encodeFloat encodes a floating point number to the XML plist format.
 func (e *Encoder) encodeFloat(rv reflect.Value) error {
 	val := rv.Float()
-	err := e.writeString("<real>" + strconv.FormatFloat(val, 'f', -1, 64) + "</real>\n")
+	str := strconv.FormatFloat(val, 'f', -1, 64)
+	if e.apple {
+		// CoreFoundation formats reals with "%.17g".
+		switch {
+		case math.IsNaN(val):
+			str = "nan"
+		case math.IsInf(val, 1):
+			str = "+infinity"
+		case math.IsInf(val, -1):
+			str = "-infinity"
+		default:
+			str = strconv.FormatFloat(val, 'g', 17, 64)
+		}
+	}
+	err := e.writeString("<real>" + str + "</real>" + e.newline())
 	if err != nil {
 		return err
 	}
@@ -186,22 +361,74 @@ func (e *Encoder) encodeFloat(rv reflect.Value) error {
 }
 
 // encodeData encodes a byte slice to the XML plist format.
+// As CoreFoundation does, the base64 text is written on lines of
+// their own, at the indentation of the <data> element, and wrapped so
+// that lines fit in 76 columns when tabs are 8 columns wide.
 func (e *Encoder) encodeData(rv reflect.Value) error {
 	buf := rv.Interface().([]byte)
+	str := base64.StdEncoding.EncodeToString(buf)
+
+	if e.compact {
+		return e.writeString("<data>" + str + "</data>")
+	}
 
-	err := e.writeString("<data>" + base64.StdEncoding.EncodeToString(buf) + "</data>\n")
+	err := e.writeString("<data>" + e.newline())
 	if err != nil {
 		return err
 	}
+	level := e.indentLevel
+	if level > 8 {
+		level = 8
+	}
+	width := 76 - 8*level
+	for len(str) > 0 {
+		n := width
+		if n > len(str) {
+			n = len(str)
+		}
+		err = e.writeString(str[:n] + e.newline())
+		if err != nil {
+			return err
+		}
+		str = str[n:]
+	}
+	return e.writeString("</data>" + e.newline())
+}
 
-	return nil
+// escaper escapes text as CoreFoundation does. Carriage returns are
+// the exception: XML readers turn them into newlines unless escaped.
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#13;")
+
+// writeEscaped writes the escaped text of a string or key. Strings
+// that XML 1.0 cannot represent are rejected.
+func (e *Encoder) writeEscaped(str string) error {
+	for i, r := range str {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(str[i:]); size == 1 {
+				return fmt.Errorf("plist: string %q is not valid UTF-8", str)
+			}
+		}
+		if !isXMLChar(r) {
+			return fmt.Errorf("plist: string %q contains %U, which XML cannot represent", str, r)
+		}
+	}
+	_, err := e.bw.WriteString(escaper.Replace(str))
+	return err
+}
+
+// isXMLChar reports whether r may appear in an XML 1.0 document.
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		0x20 <= r && r <= 0xd7ff ||
+		0xe000 <= r && r <= 0xfffd ||
+		0x10000 <= r && r <= 0x10ffff
 }
 
 // encodeBoolean encodes a bool to the XML plist format.
 func (e *Encoder) encodeBoolean(rv reflect.Value) error {
-	str := "<false/>\n"
+	str := "<false/>" + e.newline()
 	if rv.Bool() {
-		str = "<true/>\n"
+		str = "<true/>" + e.newline()
 	}
 	err := e.writeString(str)
 	if err != nil {
@@ -219,9 +446,12 @@ func (e *Encoder) encodeString(rv reflect.Value) error {
 		return err
 	}
 
-	xml.Escape(e.bw, []byte(str))
+	err = e.writeEscaped(str)
+	if err != nil {
+		return err
+	}
 
-	_, err = e.bw.WriteString("</string>\n")
+	_, err = e.bw.WriteString("</string>" + e.newline())
 	if err != nil {
 		return err
 	}
@@ -229,32 +459,45 @@ func (e *Encoder) encodeString(rv reflect.Value) error {
 	return nil
 }
 
-// encodeMap encodes a map to an XML plist dict.
+// encodeMap encodes a map to an XML plist dict. The keys of
+// the dict are written in sorted order.
 func (e *Encoder) encodeMap(rv reflect.Value) error {
-	dict, ok := rv.Interface().(map[string]interface{})
-	if !ok {
-		return errors.New("plist: bad map kind (must be map[string]interface{}")
+	if rv.Type().Key().Kind() != reflect.String {
+		return errors.New("plist: bad map kind (must have string keys)")
 	}
 
-	err := e.writeString("<dict>\n")
+	if e.selfClosing && rv.Len() == 0 {
+		return e.writeString("<dict/>" + e.newline())
+	}
+
+	err := e.writeString("<dict>" + e.newline())
 	if err != nil {
 		return err
 	}
 
 	e.indentLevel++
 
-	for k, v := range dict {
+	keys := make([]string, 0, rv.Len())
+	for _, kv := range rv.MapKeys() {
+		keys = append(keys, kv.String())
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
 		_, err = e.bw.WriteString(e.indent() + "<key>")
 		if err != nil {
 			return err
 		}
-		xml.Escape(e.bw, []byte(k))
-		_, err = e.bw.WriteString("</key>\n")
+		err = e.writeEscaped(k)
+		if err != nil {
+			return err
+		}
+		_, err = e.bw.WriteString("</key>" + e.newline())
 		if err != nil {
 			return err
 		}
 
-		err = e.encodeAny(reflect.ValueOf(v))
+		err = e.encodeAny(rv.MapIndex(reflect.ValueOf(k).Convert(rv.Type().Key())))
 		if err != nil {
 			return err
 		}
@@ -262,7 +505,7 @@ func (e *Encoder) encodeMap(rv reflect.Value) error {
 
 	e.indentLevel--
 
-	err = e.writeString("</dict>\n")
+	err = e.writeString("</dict>" + e.newline())
 	if err != nil {
 		return err
 	}
@@ -270,37 +513,74 @@ func (e *Encoder) encodeMap(rv reflect.Value) error {
 	return nil
 }
 
-// encodeStruct encodes a struct to an XML plist dict.
-func (e *Encoder) encodeStruct(rv reflect.Value) error {
-	err := e.writeString("<dict>\n")
+// encodeOrderedDict encodes an ordered dict to an XML plist dict,
+// keeping the order of its entries.
+func (e *Encoder) encodeOrderedDict(d binaryplist.OrderedDict) error {
+	if e.selfClosing && len(d) == 0 {
+		return e.writeString("<dict/>" + e.newline())
+	}
+
+	err := e.writeString("<dict>" + e.newline())
 	if err != nil {
 		return err
 	}
 
 	e.indentLevel++
 
-	for i := 0; i < rv.NumField(); i++ {
-		rt := rv.Type()
-		f := rt.Field(i)
-		name := f.Tag.Get("plist")
-		if name == "-" {
-			continue
+	for i := range d {
+		_, err = e.bw.WriteString(e.indent() + "<key>")
+		if err != nil {
+			return err
 		}
-		if name == "" {
-			name = f.Name
+		err = e.writeEscaped(d[i].Key)
+		if err != nil {
+			return err
 		}
+		_, err = e.bw.WriteString("</key>" + e.newline())
+		if err != nil {
+			return err
+		}
+
+		err = e.encodeAny(reflect.ValueOf(&d[i].Value).Elem())
+		if err != nil {
+			return err
+		}
+	}
 
+	e.indentLevel--
+
+	return e.writeString("</dict>" + e.newline())
+}
+
+// encodeStruct encodes a struct to an XML plist dict.
+func (e *Encoder) encodeStruct(rv reflect.Value) error {
+	keys, vals := plistreflect.StructEntries(rv)
+	if e.selfClosing && len(keys) == 0 {
+		return e.writeString("<dict/>" + e.newline())
+	}
+
+	err := e.writeString("<dict>" + e.newline())
+	if err != nil {
+		return err
+	}
+
+	e.indentLevel++
+
+	for i, name := range keys {
 		_, err = e.bw.WriteString(e.indent() + "<key>")
 		if err != nil {
 			return err
 		}
-		xml.Escape(e.bw, []byte(name))
-		_, err = e.bw.WriteString("</key>\n")
+		err = e.writeEscaped(name)
+		if err != nil {
+			return err
+		}
+		_, err = e.bw.WriteString("</key>" + e.newline())
 		if err != nil {
 			return err
 		}
 
-		err = e.encodeAny(rv.Field(i))
+		err = e.encodeAny(vals[i])
 		if err != nil {
 			return err
 		}
@@ -308,7 +588,7 @@ func (e *Encoder) encodeStruct(rv reflect.Value) error {
 
 	e.indentLevel--
 
-	err = e.writeString("</dict>\n")
+	err = e.writeString("</dict>" + e.newline())
 	if err != nil {
 		return err
 	}
@@ -321,7 +601,7 @@ func (e *Encoder) encodeDate(rv reflect.Value) error {
 	t := rv.Interface().(time.Time)
 	str := t.UTC().Format(time.RFC3339)
 
-	err := e.writeString("<date>" + str + "</date>\n")
+	err := e.writeString("<date>" + str + "</date>" + e.newline())
 	if err != nil {
 		return err
 	}
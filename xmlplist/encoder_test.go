@@ -3,6 +3,7 @@ package xmlplist
 import (
 	"bytes"
 	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 )
@@ -80,4 +81,128 @@ func TestEncoder(t *testing.T) {
 			t.Fatalf("test mismatch for file: %v", test.GoldenFile)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestEncoderStyles(t *testing.T) {
+	v := map[string]interface{}{
+		"a": []interface{}{},
+		"b": map[string]interface{}{},
+		"c": 0.1,
+		"d": []interface{}{int64(1)},
+	}
+	header := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n"
+	tests := []struct {
+		name     string
+		setup    func(e *Encoder)
+		expected string
+	}{
+		{"apple", func(e *Encoder) { e.SetAppleStyle() }, header +
+			"<plist version=\"1.0\">\n<dict>\n\t<key>a</key>\n\t<array/>\n\t<key>b</key>\n\t<dict/>\n" +
+			"\t<key>c</key>\n\t<real>0.10000000000000001</real>\n\t<key>d</key>\n\t<array>\n\t\t<integer>1</integer>\n\t</array>\n</dict>\n</plist>\n"},
+		{"indent", func(e *Encoder) { e.SetIndent(" ", "  ") }, strings.Replace(header, "\n", "\n ", 1) +
+			" <plist version=\"1.0\">\n <dict>\n   <key>a</key>\n   <array>\n   </array>\n   <key>b</key>\n   <dict>\n   </dict>\n" +
+			"   <key>c</key>\n   <real>0.1</real>\n   <key>d</key>\n   <array>\n     <integer>1</integer>\n   </array>\n </dict>\n </plist>\n"},
+		{"compact", func(e *Encoder) {
+			e.SetCompact(true)
+			e.SetSelfClosing(true)
+			e.SetOmitDocType(true)
+		}, `<?xml version="1.0" encoding="UTF-8"?><plist version="1.0"><dict><key>a</key><array/><key>b</key><dict/>` +
+			"<key>c</key><real>0.1</real><key>d</key><array><integer>1</integer></array></dict></plist>\n"},
+		{"crlf", func(e *Encoder) { e.SetCRLF(true) }, strings.Replace(header, "\n", "\r\n", -1) +
+			"<plist version=\"1.0\">\r\n<dict>\r\n\t<key>a</key>\r\n\t<array>\r\n\t</array>\r\n\t<key>b</key>\r\n\t<dict>\r\n\t</dict>\r\n" +
+			"\t<key>c</key>\r\n\t<real>0.1</real>\r\n\t<key>d</key>\r\n\t<array>\r\n\t\t<integer>1</integer>\r\n\t</array>\r\n</dict>\r\n</plist>\r\n"},
+	}
+	for _, test := range tests {
+		bw := new(bytes.Buffer)
+		e := NewEncoder(bw)
+		test.setup(e)
+		err := e.Encode(v)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if bw.String() != test.expected {
+			t.Errorf("%s: got\n%s", test.name, bw.String())
+		}
+
+		var decoded map[string]interface{}
+		err = Unmarshal(bw.Bytes(), &decoded)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if decoded["c"] != 0.1 {
+			t.Errorf("%s: decoded %v", test.name, decoded)
+		}
+	}
+}
+
+func TestEncoderEscaping(t *testing.T) {
+	str := "\"quoted\" & 'single'\t<tab>\nline\r\n"
+	buf, err := Marshal(map[string]interface{}{"a&b": str})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := "\t<key>a&amp;b</key>\n\t<string>\"quoted\" &amp; 'single'\t&lt;tab&gt;\nline&#13;\n</string>\n"
+	if !strings.Contains(string(buf), expected) {
+		t.Fatalf("unexpected output:\n%s", buf)
+	}
+
+	var decoded map[string]interface{}
+	err = Unmarshal(buf, &decoded)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if decoded["a&b"] != str {
+		t.Fatalf("got %q, expected %q", decoded["a&b"], str)
+	}
+
+	for _, bad := range []string{"bell\a", "\xff", "\ufffe"} {
+		_, err = Marshal([]string{bad})
+		if err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestEncoderDataWrapping(t *testing.T) {
+	data := make([]byte, 100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	v := map[string]interface{}{"a": map[string]interface{}{"b": data}}
+	buf, err := Marshal(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	expected := "\t\t<data>\n" +
+		"\t\tAAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8gISIjJCUmJygpKiss\n" +
+		"\t\tLS4vMDEyMzQ1Njc4OTo7PD0+P0BBQkNERUZHSElKS0xNTk9QUVJTVFVWV1hZ\n" +
+		"\t\tWltcXV5fYGFiYw==\n" +
+		"\t\t</data>\n"
+	if !strings.Contains(string(buf), expected) {
+		t.Fatalf("unexpected output:\n%s", buf)
+	}
+
+	var decoded map[string]map[string]interface{}
+	err = Unmarshal(buf, &decoded)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !bytes.Equal(decoded["a"]["b"].([]byte), data) {
+		t.Fatalf("got %v", decoded["a"]["b"])
+	}
+}
+
+func TestEncoderFailureWritesNothing(t *testing.T) {
+	bad := []interface{}{"ok", map[string]interface{}{"ch": make(chan int)}}
+	for _, v := range []interface{}{"root string", int64(1), bad} {
+		buf := new(bytes.Buffer)
+		err := NewEncoder(buf).Encode(v)
+		if err == nil {
+			t.Fatalf("expected error for %#v", v)
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("wrote %q for %#v", buf.String(), v)
+		}
+	}
+}